@@ -0,0 +1,292 @@
+// Package admincli реализует команду admin: она обращается к административному
+// API запущенного сервиса, чтобы не править базу данных вручную.
+package admincli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// requestTimeout — сколько ждать ответа сервиса на одну команду.
+const requestTimeout = 30 * time.Second
+
+// Options — куда и с каким токеном обращаться.
+type Options struct {
+	// URL — адрес сервиса, например http://localhost:3000.
+	URL string
+	// Token — административный токен, как в ADMIN_TOKEN сервиса.
+	Token string
+}
+
+// command — подкоманда admin.
+type command struct {
+	name  string
+	args  string
+	help  string
+	build func(fs *flag.FlagSet) func(args []string) (*request, error)
+}
+
+// request — запрос к административному API.
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	body   any
+}
+
+var commands = []command{
+	{
+		name: "rooms", args: "[-after ID] [-limit N]",
+		help: "список комнат из базы с числом подключённых клиентов",
+		build: func(fs *flag.FlagSet) func([]string) (*request, error) {
+			after := fs.Int("after", 0, "ID комнаты, после которой начать")
+			limit := fs.Int("limit", 0, "сколько комнат вывести")
+			return func([]string) (*request, error) {
+				query := url.Values{}
+				if *after > 0 {
+					query.Set("after", strconv.Itoa(*after))
+				}
+				if *limit > 0 {
+					query.Set("limit", strconv.Itoa(*limit))
+				}
+				return &request{method: http.MethodGet, path: "/admin/rooms", query: query}, nil
+			}
+		},
+	},
+	{
+		name: "room", args: "KEY",
+		help: "комната из базы и её клиенты в хабе",
+		build: func(fs *flag.FlagSet) func([]string) (*request, error) {
+			return func(args []string) (*request, error) {
+				if len(args) != 1 {
+					return nil, errUsage
+				}
+				return &request{method: http.MethodGet, path: "/admin/room", query: url.Values{"key": {args[0]}}}, nil
+			}
+		},
+	},
+	{
+		name: "close", args: "[-reason TEXT] KEY",
+		help: "закрыть комнату в хабе, отключив всех клиентов",
+		build: func(fs *flag.FlagSet) func([]string) (*request, error) {
+			reason := fs.String("reason", "", "причина, которую увидят клиенты")
+			return func(args []string) (*request, error) {
+				if len(args) != 1 {
+					return nil, errUsage
+				}
+				return &request{
+					method: http.MethodPost,
+					path:   "/admin/room/close",
+					query:  url.Values{"key": {args[0]}},
+					body:   map[string]string{"reason": *reason},
+				}, nil
+			}
+		},
+	},
+	{
+		name: "announce", args: "MESSAGE",
+		help: "разослать объявление во все открытые комнаты",
+		build: func(fs *flag.FlagSet) func([]string) (*request, error) {
+			return func(args []string) (*request, error) {
+				if len(args) == 0 {
+					return nil, errUsage
+				}
+				return &request{
+					method: http.MethodPost,
+					path:   "/admin/announce",
+					body:   map[string]string{"message": strings.Join(args, " ")},
+				}, nil
+			}
+		},
+	},
+	{
+		name: "delete-user", args: "ID",
+		help: "удалить пользователя с его комнатами и аватаром",
+		build: func(fs *flag.FlagSet) func([]string) (*request, error) {
+			return func(args []string) (*request, error) {
+				if len(args) != 1 {
+					return nil, errUsage
+				}
+				if _, err := strconv.Atoi(args[0]); err != nil {
+					return nil, fmt.Errorf("некорректный ID пользователя %q", args[0])
+				}
+				return &request{method: http.MethodDelete, path: "/admin/user", query: url.Values{"id": {args[0]}}}, nil
+			}
+		},
+	},
+	{
+		name: "purge-memberships",
+		help: "удалить записи об участии в удалённых комнатах и удалённых пользователей",
+		build: func(fs *flag.FlagSet) func([]string) (*request, error) {
+			return func(args []string) (*request, error) {
+				if len(args) != 0 {
+					return nil, errUsage
+				}
+				return &request{method: http.MethodPost, path: "/admin/memberships/purge"}, nil
+			}
+		},
+	},
+	{
+		name: "audit", args: "[-key KEY | -room ID] [-actor ID] [-action A,B] [-since T] [-until T] [-before ID] [-limit N]",
+		help: "журнал аудита, начиная с последних событий; время в RFC 3339",
+		build: func(fs *flag.FlagSet) func([]string) (*request, error) {
+			params := []string{"key", "room_id", "actor_id", "action", "since", "until", "before", "limit"}
+			names := map[string]string{"room_id": "room", "actor_id": "actor"}
+			values := make(map[string]*string, len(params))
+			for _, param := range params {
+				name := param
+				if short, ok := names[param]; ok {
+					name = short
+				}
+				values[param] = fs.String(name, "", "фильтр "+param)
+			}
+			return func(args []string) (*request, error) {
+				if len(args) != 0 {
+					return nil, errUsage
+				}
+				query := url.Values{}
+				for param, value := range values {
+					if *value != "" {
+						query.Set(param, *value)
+					}
+				}
+				return &request{method: http.MethodGet, path: "/admin/audit", query: query}, nil
+			}
+		},
+	},
+	{
+		name: "storage", args: "[-top N]",
+		help: "сколько места занимают видео, блобы и субтитры",
+		build: func(fs *flag.FlagSet) func([]string) (*request, error) {
+			top := fs.Int("top", 0, "сколько пользователей с самыми большими библиотеками вывести")
+			return func(args []string) (*request, error) {
+				query := url.Values{}
+				if *top > 0 {
+					query.Set("top", strconv.Itoa(*top))
+				}
+				return &request{method: http.MethodGet, path: "/admin/storage", query: query}, nil
+			}
+		},
+	},
+}
+
+// errUsage — аргументы команды не подходят, нужно вывести справку.
+var errUsage = errors.New("неверные аргументы")
+
+// Run выполняет подкоманду admin с аргументами args и возвращает код выхода:
+// 0 — успех, 1 — ошибка запроса, 2 — неверные аргументы. Ответ сервиса выводится в stdout.
+func Run(ctx context.Context, opts Options, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(stderr)
+		return 2
+	}
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == args[0] {
+			cmd = &commands[i]
+			break
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(stderr, "неизвестная команда %q\n\n", args[0])
+		usage(stderr)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("admin "+cmd.name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Использование: admin %s %s\n", cmd.name, cmd.args)
+		fs.PrintDefaults()
+	}
+	build := cmd.build(fs)
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	req, err := build(fs.Args())
+	if err != nil {
+		if !errors.Is(err, errUsage) {
+			fmt.Fprintln(stderr, err)
+		}
+		fs.Usage()
+		return 2
+	}
+
+	if opts.Token == "" {
+		fmt.Fprintln(stderr, "не задан ADMIN_TOKEN")
+		return 1
+	}
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	if err := do(ctx, opts, req, stdout); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Использование: admin <команда> [аргументы]")
+	fmt.Fprintln(w, "Адрес сервиса берётся из ADMIN_URL или PUBLIC_URL, токен — из ADMIN_TOKEN.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Команды:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-40s %s\n", strings.TrimSpace(cmd.name+" "+cmd.args), cmd.help)
+	}
+}
+
+// do отправляет запрос и выводит ответ сервиса в out с отступами.
+func do(ctx context.Context, opts Options, req *request, out io.Writer) error {
+	target := strings.TrimRight(opts.URL, "/") + req.path
+	if len(req.query) > 0 {
+		target += "?" + req.query.Encode()
+	}
+	var body io.Reader
+	if req.body != nil {
+		data, err := json.Marshal(req.body)
+		if err != nil {
+			return fmt.Errorf("не удалось закодировать запрос: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, target, body)
+	if err != nil {
+		return fmt.Errorf("некорректный адрес сервиса: %w", err)
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+opts.Token)
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("сервис недоступен: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("не удалось прочитать ответ: %w", err)
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("сервис ответил %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, data, "", "  "); err != nil {
+		_, err = out.Write(data)
+		return err
+	}
+	_, err = fmt.Fprintln(out, strings.TrimSpace(pretty.String()))
+	return err
+}
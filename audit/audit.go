@@ -0,0 +1,155 @@
+// Package audit записывает журнал действий в комнатах и администрирования.
+package audit
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"room/database"
+	"room/logging"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Действия, попадающие в журнал аудита.
+const (
+	ActionKick     = "kick"
+	ActionBan      = "ban"
+	ActionMute     = "mute"
+	ActionLiftBan  = "lift_ban"
+	ActionLiftMute = "lift_mute"
+
+	ActionRoomCreated   = "room_created"
+	ActionRoomUpdated   = "room_updated"
+	ActionAccessChanged = "access_changed"
+	ActionRoleGranted   = "role_granted"
+	ActionVideoChanged  = "video_changed"
+	ActionPlay          = "play"
+	ActionPause         = "pause"
+	ActionSeek          = "seek"
+
+	ActionUserDeleted       = "user_deleted"
+	ActionRoomClosed        = "room_closed"
+	ActionAnnouncement      = "announcement"
+	ActionLimitsChanged     = "limits_changed"
+	ActionMembershipsPurged = "memberships_purged"
+)
+
+// Event — запись журнала аудита.
+type Event struct {
+	// Action — что произошло.
+	Action string
+	// ActorID — кто совершил действие; 0 — система, администратор или анонимный пользователь.
+	ActorID int
+	// RoomID — в какой комнате; 0 — действие вне комнаты.
+	RoomID int
+	// Payload — подробности действия.
+	Payload map[string]any
+}
+
+// Store сохраняет события журнала аудита.
+type Store interface {
+	InsertAuditEvent(ctx context.Context, e database.AuditEvent) error
+}
+
+// Record записывает событие в журнал аудита и в лог. Ошибка записи не отменяет
+// само действие, поэтому только попадает в лог.
+func Record(ctx context.Context, store Store, e Event) {
+	attrs := []any{
+		"audit", true,
+		"action", e.Action,
+		"actor_id", e.ActorID,
+		logging.KeyRoomID, e.RoomID,
+	}
+	if len(e.Payload) > 0 {
+		attrs = append(attrs, "payload", e.Payload)
+	}
+	log := logging.FromContext(ctx)
+	log.Info("Событие аудита", attrs...)
+
+	err := store.InsertAuditEvent(ctx, database.AuditEvent{
+		Action:  e.Action,
+		ActorID: optionalID(e.ActorID),
+		RoomID:  optionalID(e.RoomID),
+		Payload: e.Payload,
+	})
+	if err != nil {
+		log.Error("Не удалось сохранить событие аудита", append(attrs, logging.KeyError, err)...)
+	}
+}
+
+// optionalID возвращает nil вместо 0.
+func optionalID(id int) *int {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
+
+// Параметры постраничной выдачи журнала.
+const (
+	defaultLimit = 50
+	maxLimit     = 500
+)
+
+// ParseFilter разбирает параметры выборки журнала: action — действия через запятую,
+// actor_id, since и until в RFC 3339, before — ID, с которого продолжить, и limit.
+// Комнату вызывающий задаёт сам.
+func ParseFilter(query url.Values) (database.AuditFilter, error) {
+	filter := database.AuditFilter{Limit: defaultLimit}
+	for _, action := range strings.Split(query.Get("action"), ",") {
+		if action = strings.TrimSpace(action); action != "" {
+			filter.Actions = append(filter.Actions, action)
+		}
+	}
+	if s := query.Get("actor_id"); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil || id <= 0 {
+			return filter, fmt.Errorf("некорректный actor_id %q", s)
+		}
+		filter.ActorID = id
+	}
+	for name, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if s := query.Get(name); s != "" {
+			parsed, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return filter, fmt.Errorf("некорректный %s %q", name, s)
+			}
+			*t = parsed
+		}
+	}
+	if s := query.Get("before"); s != "" {
+		before, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || before <= 0 {
+			return filter, fmt.Errorf("некорректный before %q", s)
+		}
+		filter.Before = before
+	}
+	if s := query.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit <= 0 {
+			return filter, fmt.Errorf("некорректный limit %q", s)
+		}
+		filter.Limit = min(limit, maxLimit)
+	}
+	return filter, nil
+}
+
+// Page — страница журнала. NextBefore равен 0 на последней странице.
+type Page struct {
+	Events     []database.AuditEvent `json:"events"`
+	NextBefore int64                 `json:"next_before,omitempty"`
+}
+
+// NewPage собирает страницу из событий, выбранных по filter.
+func NewPage(events []database.AuditEvent, filter database.AuditFilter) Page {
+	page := Page{Events: events}
+	if page.Events == nil {
+		page.Events = []database.AuditEvent{}
+	}
+	if len(events) == filter.Limit {
+		page.NextBefore = events[len(events)-1].ID
+	}
+	return page
+}
@@ -0,0 +1,35 @@
+// Package auth проверяет права доступа к HTTP-обработчикам.
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"room/logging"
+	"strings"
+)
+
+// bearerToken возвращает токен из заголовка Authorization: Bearer <token>.
+func bearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// Admin пропускает только запросы с административным токеном.
+// Если токен не задан в настройках, доступ закрыт для всех.
+func Admin(adminToken string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := bearerToken(r)
+			if adminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+				logging.FromContext(r.Context()).Warn("Отказано в административном доступе")
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
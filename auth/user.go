@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"room/database"
+	"room/logging"
+)
+
+type userKey struct{}
+
+// UserFromContext возвращает пользователя, определённого middleware User.
+func UserFromContext(ctx context.Context) (*database.User, bool) {
+	user, ok := ctx.Value(userKey{}).(*database.User)
+	return user, ok
+}
+
+// token возвращает токен пользователя из заголовка Authorization или параметра token.
+// Параметр нужен для WebSocket: браузер не может передать заголовки при подключении.
+func token(r *http.Request) string {
+	if token := bearerToken(r); token != "" {
+		return token
+	}
+	return r.URL.Query().Get("token")
+}
+
+// User определяет пользователя по токену и кладёт его в контекст запроса.
+// Запросы без токена пропускаются анонимно, с неверным токеном — отклоняются.
+func User(db *database.DB) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := token(r)
+			if token == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			user, err := db.GetUserByToken(r.Context(), token)
+			if err != nil {
+				logging.FromContext(r.Context()).Warn("Неверный токен пользователя", logging.KeyError, err)
+				http.Error(w, "Invalid token", http.StatusUnauthorized)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), userKey{}, user)
+			ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With(logging.KeyUserID, user.ID))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireUser отклоняет запросы без пользователя.
+func RequireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := UserFromContext(r.Context()); !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
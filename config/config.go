@@ -0,0 +1,253 @@
+// Package config загружает настройки сервиса из переменных окружения.
+package config
+
+import (
+	"fmt"
+	"os"
+	"room/ratelimit"
+	"room/signing"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config — настройки сервиса.
+type Config struct {
+	// Addr — адрес, на котором слушает HTTP-сервер.
+	Addr string
+	// DBPath — путь к файлу базы данных SQLite.
+	DBPath string
+	// MediaDir — каталог для медиафайлов.
+	MediaDir string
+	// PublicURL — внешний адрес сервиса, из которого строятся ссылки.
+	PublicURL string
+	// AdminToken — токен для административных и диагностических обработчиков.
+	// Пустое значение закрывает к ним доступ.
+	AdminToken string
+	// AdminURL — адрес сервиса, к которому обращается команда admin; по умолчанию PublicURL.
+	AdminURL string
+
+	// LogFormat — формат логов: "text" или "json".
+	LogFormat string
+	// LogLevel — минимальный уровень логов: "debug", "info", "warn" или "error".
+	LogLevel string
+
+	// TracingExporter — куда отправлять спаны: "none", "otlp" или "stdout".
+	TracingExporter string
+	// TracingEndpoint — адрес OTLP/HTTP коллектора (host:port).
+	TracingEndpoint string
+	// TracingInsecure отключает TLS при отправке спанов в коллектор.
+	TracingInsecure bool
+	// TracingSampleRatio — доля трассируемых запросов от 0 до 1.
+	TracingSampleRatio float64
+	// ServiceName — имя сервиса в трассировке.
+	ServiceName string
+
+	// WSClientLimits — лимиты частоты команд WebSocket одного клиента.
+	WSClientLimits map[string]ratelimit.Limit
+	// WSRoomLimits — лимиты частоты команд WebSocket всей комнаты.
+	WSRoomLimits map[string]ratelimit.Limit
+	// WSMaxViolations — после скольких превышений лимита клиент отключается.
+	WSMaxViolations int
+	// AllowedOrigins — источники, с которых разрешены запросы и подключения WebSocket.
+	// Пустой список — только тот же хост; любой источник разрешает явно заданный "*".
+	AllowedOrigins []string
+	// CORSAllowCredentials разрешает браузеру передавать учётные данные.
+	CORSAllowCredentials bool
+	// CORSMaxAge — время кэширования ответа на preflight в секундах.
+	CORSMaxAge int
+
+	// HTTPCreateLimit — лимит частоты создания комнат, гостей, пользователей и входа по приглашению
+	// с одного IP; у каждого маршрута своя корзина.
+	HTTPCreateLimit ratelimit.Limit
+
+	// InviteTTL — срок действия приглашения по умолчанию; 0 — бессрочно.
+	InviteTTL time.Duration
+
+	// RoomTTL — через сколько после последней активности комната обрабатывается очисткой.
+	// 0 — комнаты не удаляются.
+	RoomTTL time.Duration
+	// RoomExpiryAction — что делать с неактивной комнатой: "archive" или "delete".
+	RoomExpiryAction string
+	// RoomGracePeriod — сколько опустевшая комната ждёт переподключений перед закрытием в хабе.
+	RoomGracePeriod time.Duration
+	// JanitorInterval — как часто искать неактивные комнаты и гостей с истёкшим сроком.
+	JanitorInterval time.Duration
+	// GuestTTL — срок действия гостевого входа.
+	GuestTTL time.Duration
+
+	// FFmpegPath — путь к ffmpeg для перекодирования видео.
+	FFmpegPath string
+	// FFprobePath — путь к ffprobe для определения параметров видео.
+	FFprobePath string
+	// TranscodeWorkers — сколько видео перекодировать одновременно.
+	TranscodeWorkers int
+	// TranscodeTempDir — каталог временных файлов перекодирования; пустой — системный.
+	TranscodeTempDir string
+	// ThumbnailInterval — через сколько секунд видео брать кадры для миниатюр перемотки;
+	// 0 — не создавать постер и миниатюры.
+	ThumbnailInterval time.Duration
+	// ThumbnailWidth — ширина миниатюры в пикселях.
+	ThumbnailWidth int
+	// VideoMaxSize — наибольший размер загружаемого видео в байтах.
+	VideoMaxSize int64
+	// UserStorageQuota — сколько байт могут занимать видео одного пользователя; 0 — без ограничения.
+	UserStorageQuota int64
+	// RoomMaxVideos — сколько видео можно загрузить в одну комнату; 0 — без ограничения.
+	RoomMaxVideos int
+	// VideoAllowedTypes — MIME-типы, которые можно загружать; тип определяется по содержимому файла.
+	VideoAllowedTypes []string
+	// VideoURLTimeout — сколько ждать ответа при проверке ссылки на внешнее видео.
+	VideoURLTimeout time.Duration
+	// VideoURLAllowPrivate разрешает ссылки на внутренние адреса, например для локальной разработки.
+	VideoURLAllowPrivate bool
+
+	// MediaSigningKeys — ключи подписи ссылок на медиафайлы. Подписывает первый,
+	// проверяют все, поэтому при смене ключа новый ставят первым, а старый
+	// оставляют на MediaURLTTL. Пустой список — случайный ключ на время работы сервиса.
+	MediaSigningKeys []signing.Key
+	// MediaURLTTL — сколько действует подписанная ссылка на медиафайлы.
+	MediaURLTTL time.Duration
+}
+
+// Load читает настройки из переменных окружения, подставляя значения по умолчанию.
+func Load() (Config, error) {
+	cfg := Config{
+		Addr:       getEnv("ADDR", ":3000"),
+		DBPath:     getEnv("DB_PATH", "./sqlite.db"),
+		MediaDir:   getEnv("MEDIA_DIR", "./media"),
+		PublicURL:  getEnv("PUBLIC_URL", "http://localhost:3000"),
+		AdminToken: getEnv("ADMIN_TOKEN", ""),
+		AdminURL:   getEnv("ADMIN_URL", ""),
+		LogFormat:  getEnv("LOG_FORMAT", "text"),
+		LogLevel:   getEnv("LOG_LEVEL", "info"),
+
+		TracingExporter:    getEnv("TRACING_EXPORTER", "none"),
+		TracingEndpoint:    getEnv("TRACING_OTLP_ENDPOINT", ""),
+		TracingInsecure:    getEnvBool("TRACING_OTLP_INSECURE", false),
+		TracingSampleRatio: getEnvFloat("TRACING_SAMPLE_RATIO", 1),
+		ServiceName:        getEnv("SERVICE_NAME", "video-party-room-service"),
+
+		WSMaxViolations: getEnvInt("WS_MAX_VIOLATIONS", 10),
+
+		AllowedOrigins:       getEnvList("CORS_ALLOWED_ORIGINS", nil),
+		CORSAllowCredentials: getEnvBool("CORS_ALLOW_CREDENTIALS", false),
+		CORSMaxAge:           getEnvInt("CORS_MAX_AGE", 600),
+
+		InviteTTL: getEnvDuration("INVITE_TTL", 24*time.Hour),
+
+		RoomTTL:          getEnvDuration("ROOM_TTL", 30*24*time.Hour),
+		RoomExpiryAction: getEnv("ROOM_EXPIRY_ACTION", "archive"),
+		RoomGracePeriod:  getEnvDuration("ROOM_GRACE_PERIOD", 30*time.Second),
+		JanitorInterval:  getEnvDuration("JANITOR_INTERVAL", 10*time.Minute),
+		GuestTTL:         getEnvDuration("GUEST_TTL", 24*time.Hour),
+
+		FFmpegPath:        getEnv("FFMPEG_PATH", "ffmpeg"),
+		FFprobePath:       getEnv("FFPROBE_PATH", "ffprobe"),
+		TranscodeWorkers:  getEnvInt("TRANSCODE_WORKERS", 1),
+		TranscodeTempDir:  getEnv("TRANSCODE_TMP_DIR", ""),
+		ThumbnailInterval: getEnvDuration("THUMBNAIL_INTERVAL", 10*time.Second),
+		ThumbnailWidth:    getEnvInt("THUMBNAIL_WIDTH", 160),
+		VideoMaxSize:      int64(getEnvInt("VIDEO_MAX_SIZE", 4<<30)),
+		UserStorageQuota:  int64(getEnvInt("USER_STORAGE_QUOTA", 20<<30)),
+		RoomMaxVideos:     getEnvInt("ROOM_MAX_VIDEOS", 50),
+		VideoAllowedTypes: getEnvList("VIDEO_ALLOWED_TYPES", []string{
+			"video/mp4", "video/webm", "video/x-matroska", "video/quicktime",
+			"video/avi", "video/x-msvideo", "video/mpeg", "video/mp2t", "video/ogg", "video/3gpp",
+		}),
+
+		VideoURLTimeout:      getEnvDuration("VIDEO_URL_TIMEOUT", 10*time.Second),
+		VideoURLAllowPrivate: getEnvBool("VIDEO_URL_ALLOW_PRIVATE", false),
+
+		MediaURLTTL: getEnvDuration("MEDIA_URL_TTL", 4*time.Hour),
+	}
+
+	var err error
+	// Лимиты задаются как command=rate:burst, где rate — команд в секунду
+	cfg.WSClientLimits, err = ratelimit.ParseLimits(getEnv("WS_CLIENT_RATE_LIMITS", "default=5:10,seek=2:5"))
+	if err != nil {
+		return Config{}, fmt.Errorf("WS_CLIENT_RATE_LIMITS: %w", err)
+	}
+	cfg.WSRoomLimits, err = ratelimit.ParseLimits(getEnv("WS_ROOM_RATE_LIMITS", "default=20:40"))
+	if err != nil {
+		return Config{}, fmt.Errorf("WS_ROOM_RATE_LIMITS: %w", err)
+	}
+	cfg.HTTPCreateLimit, err = ratelimit.ParseLimit(getEnv("HTTP_CREATE_RATE_LIMIT", "0.2:5"))
+	if err != nil {
+		return Config{}, fmt.Errorf("HTTP_CREATE_RATE_LIMIT: %w", err)
+	}
+
+	// Ключи задаются как id:secret через запятую, подписывает первый
+	cfg.MediaSigningKeys, err = signing.ParseKeys(getEnv("MEDIA_SIGNING_KEYS", ""))
+	if err != nil {
+		return Config{}, fmt.Errorf("MEDIA_SIGNING_KEYS: %w", err)
+	}
+
+	if cfg.AdminURL == "" {
+		cfg.AdminURL = cfg.PublicURL
+	}
+	// Со звёздочкой и учётными данными любой сайт мог бы делать запросы от имени пользователя
+	if cfg.CORSAllowCredentials && slices.Contains(cfg.AllowedOrigins, "*") {
+		return Config{}, fmt.Errorf("CORS_ALLOW_CREDENTIALS: нельзя разрешать учётные данные при CORS_ALLOWED_ORIGINS=*")
+	}
+	if cfg.VideoMaxSize <= 0 {
+		return Config{}, fmt.Errorf("VIDEO_MAX_SIZE: размер должен быть положительным")
+	}
+
+	return cfg, nil
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getEnvList читает список значений, разделённых запятыми.
+func getEnvList(key string, fallback []string) []string {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(getEnv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func getEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(getEnv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(getEnv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func getEnvFloat(key string, fallback float64) float64 {
+	value, err := strconv.ParseFloat(getEnv(key, ""), 64)
+	if err != nil {
+		return fallback
+	}
+	return value
+}
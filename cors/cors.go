@@ -0,0 +1,128 @@
+// Package cors задаёт единую политику источников (Origin) для HTTP-обработчиков
+// и WebSocket-подключений.
+package cors
+
+import (
+	"net/http"
+	"net/url"
+	"room/logging"
+	"room/metrics"
+	"strconv"
+	"strings"
+)
+
+// Policy — политика допустимых источников.
+type Policy struct {
+	// AllowedOrigins — разрешённые источники, например "https://player.example.com".
+	// "*" разрешает любой источник, "https://*.example.com" — любой поддомен.
+	// Запросы с того же хоста разрешены всегда, поэтому пустой список пускает только их.
+	AllowedOrigins []string
+	// AllowCredentials разрешает браузеру передавать cookie и заголовок Authorization.
+	AllowCredentials bool
+	// AllowedMethods — методы, разрешённые в ответе на preflight.
+	AllowedMethods []string
+	// AllowedHeaders — заголовки, разрешённые в ответе на preflight.
+	AllowedHeaders []string
+	// MaxAge — сколько секунд браузер может кэшировать ответ на preflight.
+	MaxAge int
+}
+
+// Allowed сообщает, разрешён ли источник origin.
+func (p *Policy) Allowed(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, allowed := range p.AllowedOrigins {
+		allowed = strings.ToLower(allowed)
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+		// Шаблон поддоменов: https://*.example.com
+		if prefix, suffix, ok := strings.Cut(allowed, "*"); ok {
+			if strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) &&
+				len(origin) > len(prefix)+len(suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Middleware применяет политику к HTTP-запросам: отвечает на preflight,
+// добавляет заголовки CORS и отклоняет запросы с чужих источников.
+// Запросы без заголовка Origin (не из браузера) и с того же хоста пропускаются.
+func (p *Policy) Middleware(next http.Handler) http.Handler {
+	methods := strings.Join(p.AllowedMethods, ", ")
+	headers := strings.Join(p.AllowedHeaders, ", ")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		w.Header().Add("Vary", "Origin")
+		if origin == "" || sameHost(origin, r.Host) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if !p.Allowed(origin) {
+			p.reject(r, origin, "http")
+			http.Error(w, "Origin not allowed", http.StatusForbidden)
+			return
+		}
+
+		p.setOrigin(w, origin)
+		if preflight {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+			w.Header().Set("Access-Control-Allow-Methods", methods)
+			w.Header().Set("Access-Control-Allow-Headers", headers)
+			if p.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(p.MaxAge))
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CheckOrigin проверяет источник запроса на подключение WebSocket.
+// Подходит для websocket.Upgrader.CheckOrigin.
+func (p *Policy) CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || p.Allowed(origin) || sameHost(origin, r.Host) {
+		return true
+	}
+	p.reject(r, origin, "websocket")
+	return false
+}
+
+func (p *Policy) setOrigin(w http.ResponseWriter, origin string) {
+	// Любому источнику учётные данные не разрешаются, даже если это включено в настройках
+	if p.Allowed("*") {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	// С учётными данными браузер не принимает "*", поэтому возвращаем сам источник
+	if p.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+}
+
+func (p *Policy) reject(r *http.Request, origin, source string) {
+	metrics.OriginRejected.WithLabelValues(source).Inc()
+	logging.FromContext(r.Context()).Warn("Источник запроса не разрешён",
+		"origin", origin,
+		"source", source,
+	)
+}
+
+func sameHost(origin, host string) bool {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, host)
+}
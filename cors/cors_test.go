@@ -0,0 +1,191 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAllowed(t *testing.T) {
+	p := &Policy{AllowedOrigins: []string{"https://player.example.com", "https://*.example.org"}}
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://player.example.com", true},
+		{"HTTPS://Player.Example.com", true},
+		{"http://player.example.com", false},
+		{"https://other.example.com", false},
+		{"https://a.example.org", true},
+		{"https://a.b.example.org", true},
+		{"https://example.org", false},
+		{"https://.example.org", false},
+		{"http://a.example.org", false},
+		{"https://evilexample.org", false},
+		{"https://a.example.org.evil.com", false},
+	}
+	for _, tt := range tests {
+		if got := p.Allowed(tt.origin); got != tt.want {
+			t.Errorf("Allowed(%s) = %v, ожидалось %v", tt.origin, got, tt.want)
+		}
+	}
+
+	if (&Policy{}).Allowed("https://player.example.com") {
+		t.Error("пустой список разрешает чужой источник")
+	}
+	if !(&Policy{AllowedOrigins: []string{"*"}}).Allowed("https://any.example.net") {
+		t.Error("\"*\" не разрешает любой источник")
+	}
+}
+
+// serve пропускает запрос через политику и сообщает, дошёл ли он до обработчика.
+func serve(p *Policy, r *http.Request) (*httptest.ResponseRecorder, bool) {
+	called := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})
+	w := httptest.NewRecorder()
+	p.Middleware(next).ServeHTTP(w, r)
+	return w, called
+}
+
+func request(method, origin string) *http.Request {
+	r := httptest.NewRequest(method, "http://room.example.com/rooms", nil)
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+	return r
+}
+
+func TestMiddlewareDefaultSameHost(t *testing.T) {
+	// Политика по умолчанию пускает только запросы с того же хоста и не из браузера
+	p := &Policy{}
+	tests := []struct {
+		origin string
+		want   int
+		called bool
+	}{
+		{"", http.StatusOK, true},
+		{"http://room.example.com", http.StatusOK, true},
+		{"https://ROOM.example.com", http.StatusOK, true},
+		{"https://evil.example.com", http.StatusForbidden, false},
+		{"http://room.example.com:8080", http.StatusForbidden, false},
+	}
+	for _, tt := range tests {
+		w, called := serve(p, request(http.MethodGet, tt.origin))
+		if w.Code != tt.want || called != tt.called {
+			t.Errorf("Origin %q: код %d, обработчик вызван %v; ожидалось %d и %v", tt.origin, w.Code, called, tt.want, tt.called)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("Origin %q: Access-Control-Allow-Origin = %q, ожидался пустой", tt.origin, got)
+		}
+	}
+}
+
+func TestMiddlewareHeaders(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      *Policy
+		origin      string
+		wantOrigin  string
+		credentials bool
+	}{
+		{
+			name:       "разрешённый источник",
+			policy:     &Policy{AllowedOrigins: []string{"https://*.example.org"}},
+			origin:     "https://app.example.org",
+			wantOrigin: "https://app.example.org",
+		},
+		{
+			name:        "с учётными данными",
+			policy:      &Policy{AllowedOrigins: []string{"https://app.example.org"}, AllowCredentials: true},
+			origin:      "https://app.example.org",
+			wantOrigin:  "https://app.example.org",
+			credentials: true,
+		},
+		{
+			name:       "любой источник без учётных данных",
+			policy:     &Policy{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+			origin:     "https://app.example.org",
+			wantOrigin: "*",
+		},
+	}
+	for _, tt := range tests {
+		w, called := serve(tt.policy, request(http.MethodGet, tt.origin))
+		if w.Code != http.StatusOK || !called {
+			t.Errorf("%s: код %d, обработчик вызван %v", tt.name, w.Code, called)
+			continue
+		}
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+			t.Errorf("%s: Access-Control-Allow-Origin = %q, ожидалось %q", tt.name, got, tt.wantOrigin)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Credentials") == "true"; got != tt.credentials {
+			t.Errorf("%s: учётные данные разрешены = %v, ожидалось %v", tt.name, got, tt.credentials)
+		}
+	}
+}
+
+func TestMiddlewarePreflight(t *testing.T) {
+	p := &Policy{
+		AllowedOrigins: []string{"https://app.example.org"},
+		AllowedMethods: []string{http.MethodGet, http.MethodPost},
+		AllowedHeaders: []string{"Authorization", "Content-Type"},
+		MaxAge:         600,
+	}
+
+	r := request(http.MethodOptions, "https://app.example.org")
+	r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	w, called := serve(p, r)
+	if w.Code != http.StatusNoContent || called {
+		t.Fatalf("preflight: код %d, обработчик вызван %v; ожидалось %d без вызова", w.Code, called, http.StatusNoContent)
+	}
+	for header, want := range map[string]string{
+		"Access-Control-Allow-Origin":  "https://app.example.org",
+		"Access-Control-Allow-Methods": "GET, POST",
+		"Access-Control-Allow-Headers": "Authorization, Content-Type",
+		"Access-Control-Max-Age":       "600",
+	} {
+		if got := w.Header().Get(header); got != want {
+			t.Errorf("%s = %q, ожидалось %q", header, got, want)
+		}
+	}
+
+	r = request(http.MethodOptions, "https://evil.example.org")
+	r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	if w, called := serve(p, r); w.Code != http.StatusForbidden || called {
+		t.Errorf("preflight с чужого источника: код %d, обработчик вызван %v", w.Code, called)
+	}
+
+	// OPTIONS без Access-Control-Request-Method — обычный запрос, а не preflight
+	if w, called := serve(p, request(http.MethodOptions, "https://app.example.org")); !called {
+		t.Errorf("OPTIONS без preflight не дошёл до обработчика, код %d", w.Code)
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		origins []string
+		origin  string
+		want    bool
+	}{
+		{"без Origin", nil, "", true},
+		{"тот же хост", nil, "https://room.example.com", true},
+		{"чужой источник по умолчанию", nil, "https://evil.example.com", false},
+		{"поддомен из списка", []string{"https://*.example.org"}, "https://app.example.org", true},
+		{"источник не из списка", []string{"https://*.example.org"}, "https://app.example.net", false},
+		{"любой источник", []string{"*"}, "https://app.example.net", true},
+	}
+	for _, tt := range tests {
+		p := &Policy{AllowedOrigins: tt.origins}
+		r := httptest.NewRequest(http.MethodGet, "http://room.example.com/room/ws?key=abc", nil)
+		r.Header.Set("Connection", "Upgrade")
+		r.Header.Set("Upgrade", "websocket")
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if got := p.CheckOrigin(r); got != tt.want {
+			t.Errorf("%s: CheckOrigin = %v, ожидалось %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// ListRooms возвращает все комнаты, включая приватные и архивные, по возрастанию ID,
+// начиная с комнаты после afterID. Участники комнат не загружаются.
+func (db *DB) ListRooms(ctx context.Context, afterID, limit int) ([]Room, error) {
+	rows, err := db.query(ctx, "list_rooms",
+		`SELECT `+roomColumns+` FROM rooms WHERE id > ? ORDER BY id LIMIT ?`, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения запроса: %w", err)
+	}
+	defer rows.Close()
+
+	var rooms []Room
+	for rows.Next() {
+		room, err := scanRoom(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования строки: %w", err)
+		}
+		rooms = append(rooms, *room)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по строкам: %w", err)
+	}
+	return rooms, nil
+}
+
+// PurgeOrphanMemberships удаляет записи об участии в комнатах, которых уже нет,
+// и записи удалённых пользователей. Возвращает число удалённых записей.
+func (db *DB) PurgeOrphanMemberships(ctx context.Context) (int64, error) {
+	result, err := db.exec(ctx, "purge_orphan_memberships",
+		`DELETE FROM users_in_room
+		WHERE room_id NOT IN (SELECT id FROM rooms) OR user_id NOT IN (SELECT id FROM users)`)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка удаления записей об участии: %w", err)
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("ошибка проверки затронутых строк: %w", err)
+	}
+	if purged > 0 {
+		db.log(ctx).Info("Удалены записи об участии в несуществующих комнатах", "count", purged)
+	}
+	return purged, nil
+}
+
+// StorageUsage — сколько места в хранилище занимают файлы сервиса.
+type StorageUsage struct {
+	// Blobs и BlobBytes — перекодированные загрузки; одинаковые файлы хранятся один раз.
+	Blobs     int   `json:"blobs"`
+	BlobBytes int64 `json:"blob_bytes"`
+	// Videos и VideoBytes — видео в библиотеках. Видео с общим блобом учитываются
+	// каждое отдельно, поэтому VideoBytes может быть больше BlobBytes.
+	Videos     int   `json:"videos"`
+	VideoBytes int64 `json:"video_bytes"`
+	// PendingUploads и PendingBytes — исходные файлы, которые ждут перекодирования.
+	PendingUploads int   `json:"pending_uploads"`
+	PendingBytes   int64 `json:"pending_bytes"`
+	// SubtitleTracks — число загруженных дорожек субтитров.
+	SubtitleTracks int `json:"subtitle_tracks"`
+	// TopUsers — пользователи, чьи видео занимают больше всего места.
+	TopUsers []UserUsage `json:"top_users"`
+}
+
+// UserUsage — сколько места занимают видео пользователя.
+type UserUsage struct {
+	UserID int    `json:"user_id"`
+	Name   string `json:"name"`
+	Videos int    `json:"videos"`
+	Bytes  int64  `json:"bytes"`
+}
+
+// GetStorageUsage подсчитывает место в хранилище по таблицам видео, блобов,
+// заданий перекодирования и субтитров и возвращает top пользователей с самыми большими библиотеками.
+func (db *DB) GetStorageUsage(ctx context.Context, top int) (*StorageUsage, error) {
+	usage := StorageUsage{TopUsers: []UserUsage{}}
+	err := db.queryRow(ctx, "get_storage_usage",
+		`SELECT
+			(SELECT COUNT(*) FROM blobs WHERE ready = 1),
+			(SELECT COALESCE(SUM(size), 0) FROM blobs WHERE ready = 1),
+			(SELECT COUNT(*) FROM videos WHERE source = ?),
+			(SELECT COALESCE(SUM(size), 0) FROM videos WHERE source = ?),
+			(SELECT COUNT(*) FROM transcode_jobs WHERE status IN (?, ?)),
+			(SELECT COALESCE(SUM(source_size), 0) FROM transcode_jobs WHERE status IN (?, ?)),
+			(SELECT COUNT(*) FROM subtitle_tracks)`,
+		SourceUpload, SourceUpload,
+		TranscodeQueued, TranscodeRunning, TranscodeQueued, TranscodeRunning).
+		Scan(&usage.Blobs, &usage.BlobBytes, &usage.Videos, &usage.VideoBytes,
+			&usage.PendingUploads, &usage.PendingBytes, &usage.SubtitleTracks)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка подсчёта места в хранилище: %w", err)
+	}
+
+	rows, err := db.query(ctx, "get_top_storage_users",
+		`SELECT users.id, users.name, COUNT(*), COALESCE(SUM(videos.size), 0)
+		FROM videos JOIN users ON users.id = videos.owner
+		WHERE videos.source = ?
+		GROUP BY users.id ORDER BY 4 DESC, users.id LIMIT ?`, SourceUpload, top)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения запроса: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var u UserUsage
+		if err := rows.Scan(&u.UserID, &u.Name, &u.Videos, &u.Bytes); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования строки: %w", err)
+		}
+		usage.TopUsers = append(usage.TopUsers, u)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по строкам: %w", err)
+	}
+	return &usage, nil
+}
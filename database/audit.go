@@ -0,0 +1,152 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+func (db *DB) CreateAuditEventsTable(ctx context.Context) error {
+	// Журнал только пополняется: триггеры запрещают менять и удалять записи
+	createTablesSQL := `
+	CREATE TABLE IF NOT EXISTS audit_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		action TEXT NOT NULL,
+		actor_id INTEGER NULL,
+		room_id INTEGER NULL,
+		payload TEXT NOT NULL DEFAULT '{}',
+		created_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS audit_events_room ON audit_events (room_id, id);
+	CREATE INDEX IF NOT EXISTS audit_events_actor ON audit_events (actor_id, id);
+	CREATE TRIGGER IF NOT EXISTS audit_events_no_update BEFORE UPDATE ON audit_events
+	BEGIN
+		SELECT RAISE(ABORT, 'audit_events is append-only');
+	END;
+	CREATE TRIGGER IF NOT EXISTS audit_events_no_delete BEFORE DELETE ON audit_events
+	BEGIN
+		SELECT RAISE(ABORT, 'audit_events is append-only');
+	END;
+	`
+	_, err := db.exec(ctx, "create_audit_events_table", createTablesSQL)
+	if err != nil {
+		return fmt.Errorf("ошибка создания таблиц: %w", err)
+	}
+	db.log(ctx).Info("Таблица 'audit_events' готова")
+	return nil
+}
+
+// AuditEvent — запись журнала аудита.
+type AuditEvent struct {
+	ID int64 `json:"id"`
+	// Action — что произошло.
+	Action string `json:"action"`
+	// ActorID — кто совершил действие; nil — система, администратор или анонимный участник.
+	ActorID *int `json:"actor_id"`
+	// RoomID — в какой комнате; nil — действие вне комнаты.
+	RoomID *int `json:"room_id"`
+	// Payload — подробности действия.
+	Payload   map[string]any `json:"payload"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// AuditFilter — условия выборки журнала аудита. Нулевые значения не ограничивают выборку.
+type AuditFilter struct {
+	RoomID  int
+	ActorID int
+	// Actions — только эти действия.
+	Actions []string
+	// Since и Until — только события в промежутке [Since, Until).
+	Since time.Time
+	Until time.Time
+	// Before — только события с ID меньше этого, для постраничной выдачи.
+	Before int64
+	// Limit — сколько событий вернуть.
+	Limit int
+}
+
+// InsertAuditEvent добавляет событие в журнал аудита. ID и CreatedAt события не используются.
+func (db *DB) InsertAuditEvent(ctx context.Context, e AuditEvent) error {
+	payload := e.Payload
+	if payload == nil {
+		payload = map[string]any{}
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("ошибка кодирования события аудита: %w", err)
+	}
+	_, err = db.exec(ctx, "insert_audit_event",
+		`INSERT INTO audit_events (action, actor_id, room_id, payload, created_at) VALUES (?, ?, ?, ?, ?)`,
+		e.Action, e.ActorID, e.RoomID, string(data), time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("ошибка записи события аудита: %w", err)
+	}
+	return nil
+}
+
+// ListAuditEvents возвращает события журнала аудита, начиная с последних.
+func (db *DB) ListAuditEvents(ctx context.Context, filter AuditFilter) ([]AuditEvent, error) {
+	var where []string
+	var args []any
+	if filter.RoomID != 0 {
+		where = append(where, "room_id = ?")
+		args = append(args, filter.RoomID)
+	}
+	if filter.ActorID != 0 {
+		where = append(where, "actor_id = ?")
+		args = append(args, filter.ActorID)
+	}
+	if len(filter.Actions) > 0 {
+		where = append(where, "action IN (?"+strings.Repeat(", ?", len(filter.Actions)-1)+")")
+		for _, action := range filter.Actions {
+			args = append(args, action)
+		}
+	}
+	if !filter.Since.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, filter.Until.UTC())
+	}
+	if filter.Before > 0 {
+		where = append(where, "id < ?")
+		args = append(args, filter.Before)
+	}
+	querySQL := `SELECT id, action, actor_id, room_id, payload, created_at FROM audit_events`
+	if len(where) > 0 {
+		querySQL += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	querySQL += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, filter.Limit)
+
+	rows, err := db.query(ctx, "list_audit_events", querySQL, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения запроса: %w", err)
+	}
+	defer rows.Close()
+
+	var events []AuditEvent
+	for rows.Next() {
+		var e AuditEvent
+		var actorID, roomID sql.NullInt64
+		var payload string
+		if err := rows.Scan(&e.ID, &e.Action, &actorID, &roomID, &payload, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования строки: %w", err)
+		}
+		if err := json.Unmarshal([]byte(payload), &e.Payload); err != nil {
+			return nil, fmt.Errorf("ошибка чтения события аудита %d: %w", e.ID, err)
+		}
+		e.ActorID = nullIntPtr(actorID)
+		e.RoomID = nullIntPtr(roomID)
+		events = append(events, e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по строкам: %w", err)
+	}
+	return events, nil
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+func (db *DB) CreateBlobsTable(ctx context.Context) error {
+	createTablesSQL := `
+	CREATE TABLE IF NOT EXISTS blobs (
+		hash TEXT PRIMARY KEY,
+		refs INTEGER NOT NULL DEFAULT 0,
+		ready INTEGER NOT NULL DEFAULT 0,
+		size INTEGER NOT NULL DEFAULT 0,
+		info TEXT NOT NULL DEFAULT '{}',
+		created_at DATETIME NOT NULL
+	)`
+	_, err := db.exec(ctx, "create_blobs_table", createTablesSQL)
+	if err != nil {
+		return fmt.Errorf("ошибка создания таблиц: %w", err)
+	}
+	db.log(ctx).Info("Таблица 'blobs' готова")
+	return nil
+}
+
+// Blob — перекодированное содержимое загруженного файла. Одинаковые загрузки,
+// то есть файлы с одним хешем SHA-256, перекодируются один раз и делят один блоб.
+type Blob struct {
+	// Hash — SHA-256 исходного файла в шестнадцатеричном виде.
+	Hash string `json:"hash"`
+	// Refs — сколько заданий перекодирования, кроме неудачных, ссылаются на блоб.
+	Refs int `json:"refs"`
+	// Ready сообщает, что файлы HLS загружены в хранилище.
+	Ready bool `json:"ready"`
+	// Size — сколько байт блоб занимает в хранилище.
+	Size      int64     `json:"size"`
+	Info      VideoInfo `json:"info"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BlobDir возвращает каталог хранилища с файлами блоба hash.
+func BlobDir(hash string) string {
+	return "blobs/" + hash
+}
+
+// GetBlob возвращает блоб по хешу.
+func (db *DB) GetBlob(ctx context.Context, hash string) (*Blob, error) {
+	var blob Blob
+	var info string
+	err := db.queryRow(ctx, "get_blob",
+		`SELECT hash, refs, ready, size, info, created_at FROM blobs WHERE hash = ?`, hash).
+		Scan(&blob.Hash, &blob.Refs, &blob.Ready, &blob.Size, &info, &blob.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения блоба: %w", err)
+	}
+	if err := json.Unmarshal([]byte(info), &blob.Info); err != nil {
+		return nil, fmt.Errorf("ошибка чтения параметров блоба %s: %w", hash, err)
+	}
+	return &blob, nil
+}
+
+// CompleteBlob отмечает, что файлы блоба загружены, и сохраняет их размер и параметры видео.
+func (db *DB) CompleteBlob(ctx context.Context, hash string, size int64, info VideoInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("ошибка кодирования параметров блоба: %w", err)
+	}
+	_, err = db.exec(ctx, "complete_blob",
+		`UPDATE blobs SET ready = 1, size = ?, info = ? WHERE hash = ?`, size, string(data), hash)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения блоба: %w", err)
+	}
+	return nil
+}
+
+// acquireBlob добавляет ссылку на блоб hash, создавая его при первой ссылке.
+func acquireBlob(ctx context.Context, s session, hash string) error {
+	_, err := s.exec(ctx, "acquire_blob",
+		`INSERT INTO blobs (hash, refs, created_at) VALUES (?, 1, ?)
+		ON CONFLICT (hash) DO UPDATE SET refs = refs + 1`, hash, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("ошибка добавления ссылки на блоб: %w", err)
+	}
+	return nil
+}
+
+// releaseBlob убирает ссылку на блоб hash. Блоб без ссылок удаляется, и возвращается
+// каталог его файлов, чтобы вызывающий удалил их; иначе возвращается пустая строка.
+func releaseBlob(ctx context.Context, s session, hash string) (string, error) {
+	var refs int
+	err := s.queryRow(ctx, "release_blob",
+		`UPDATE blobs SET refs = refs - 1 WHERE hash = ? RETURNING refs`, hash).Scan(&refs)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("ошибка удаления ссылки на блоб: %w", err)
+	}
+	if refs > 0 {
+		return "", nil
+	}
+	if _, err := s.exec(ctx, "delete_blob", `DELETE FROM blobs WHERE hash = ?`, hash); err != nil {
+		return "", fmt.Errorf("ошибка удаления блоба: %w", err)
+	}
+	return BlobDir(hash), nil
+}
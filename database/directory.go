@@ -0,0 +1,82 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RoomCursor — позиция в списке комнат, отсортированном по активности.
+type RoomCursor struct {
+	LastActiveAt time.Time
+	ID           int
+}
+
+// RoomFilter — условия выборки публичных комнат.
+type RoomFilter struct {
+	// Query — подстрока названия без учёта регистра.
+	Query string
+	// HasVideo — только комнаты с видео (true) или без него (false); nil — любые.
+	HasVideo *bool
+	// After — вернуть комнаты после этой позиции; nil — с начала.
+	After *RoomCursor
+	// Limit — сколько комнат вернуть.
+	Limit int
+}
+
+// Cursor возвращает позицию комнаты в списке.
+func (r *Room) Cursor() RoomCursor {
+	return RoomCursor{LastActiveAt: r.LastActiveAt, ID: r.ID}
+}
+
+// likeEscaper экранирует спецсимволы LIKE в пользовательском тексте.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// ListPublicRooms возвращает неархивные публичные комнаты, начиная с самых активных.
+// Участники комнат не загружаются.
+func (db *DB) ListPublicRooms(ctx context.Context, filter RoomFilter) ([]Room, error) {
+	where := []string{"visibility = ?", "archived_at IS NULL"}
+	args := []any{VisibilityPublic}
+
+	if filter.Query != "" {
+		where = append(where, `title LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+likeEscaper.Replace(filter.Query)+"%")
+	}
+	if filter.HasVideo != nil {
+		if *filter.HasVideo {
+			where = append(where, "video IS NOT NULL AND video != ''")
+		} else {
+			where = append(where, "(video IS NULL OR video = '')")
+		}
+	}
+	if filter.After != nil {
+		after := filter.After.LastActiveAt.UTC()
+		where = append(where, "(last_active_at < ? OR (last_active_at = ? AND id < ?))")
+		args = append(args, after, after, filter.After.ID)
+	}
+	args = append(args, filter.Limit)
+
+	querySQL := `SELECT ` + roomColumns + ` FROM rooms WHERE ` + strings.Join(where, " AND ") +
+		` ORDER BY last_active_at DESC, id DESC LIMIT ?`
+	rows, err := db.query(ctx, "list_public_rooms", querySQL, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения запроса: %w", err)
+	}
+	defer rows.Close()
+
+	var rooms []Room
+	for rows.Next() {
+		room, err := scanRoom(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования строки: %w", err)
+		}
+		rooms = append(rooms, *room)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по строкам: %w", err)
+	}
+
+	return rooms, nil
+}
@@ -0,0 +1,23 @@
+package database
+
+import "errors"
+
+// Ошибки, по которым обработчики выбирают код ответа.
+var (
+	ErrNotMember        = errors.New("пользователь не состоит в комнате")
+	ErrUserNotFound     = errors.New("пользователь не найден")
+	ErrInviteNotFound   = errors.New("приглашение не найдено")
+	ErrInviteRevoked    = errors.New("приглашение отозвано")
+	ErrInviteExpired    = errors.New("срок действия приглашения истёк")
+	ErrInviteExhausted  = errors.New("приглашение использовано максимальное число раз")
+	ErrSanctionNotFound = errors.New("санкция не найдена")
+	ErrBanned           = errors.New("пользователь забанен в комнате")
+	ErrNameTaken        = errors.New("имя пользователя уже занято")
+	ErrNotGuest         = errors.New("пользователь не является гостем")
+	ErrGuestScope       = errors.New("гостю доступна только его комната")
+	ErrSubtitleNotFound = errors.New("субтитры не найдены")
+	ErrVideoNotFound    = errors.New("видео не найдено")
+	ErrVideoInUse       = errors.New("видео установлено в комнатах")
+	ErrBlobNotFound     = errors.New("блоб не найден")
+	ErrRoomActive       = errors.New("комната снова активна")
+)
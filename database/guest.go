@@ -0,0 +1,176 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"room/logging"
+	"time"
+)
+
+// guestName придумывает имя гостя. Имена гостей могут повторяться.
+func guestName() string {
+	n, err := rand.Int(rand.Reader, big.NewInt(10000))
+	if err != nil {
+		return "Guest"
+	}
+	return fmt.Sprintf("Guest %04d", n.Int64())
+}
+
+// CreateGuest создаёт временного гостя комнаты roomID со сроком действия ttl
+// и добавляет его в участники комнаты. Возвращает гостя с токеном.
+func (db *DB) CreateGuest(ctx context.Context, roomID int, ttl time.Duration) (*User, error) {
+	var user *User
+	err := db.withTx(ctx, func(s session) error {
+		var err error
+		user, err = insertGuest(ctx, s, roomID, ttl)
+		if err != nil {
+			return err
+		}
+
+		_, err = s.exec(ctx, "add_user_in_room",
+			`INSERT INTO users_in_room (room_id, user_id, role) VALUES (?, ?, ?)`,
+			roomID, user.ID, RoleMember)
+		if err != nil {
+			return fmt.Errorf("ошибка добавления гостя в комнату: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	db.log(ctx).Info("Гость добавлен", logging.KeyUserID, user.ID, logging.KeyRoomID, roomID)
+	return user, nil
+}
+
+// RedeemInviteAsGuest создаёт гостя комнаты приглашения и добавляет его в комнату
+// по правилам RedeemInvite. Если приглашение не подходит, гость не создаётся.
+// Возвращает комнату, гостя с токеном и его роль.
+func (db *DB) RedeemInviteAsGuest(ctx context.Context, token string, ttl time.Duration) (*Room, *User, string, error) {
+	var user *User
+	var roomID int
+	var role string
+
+	err := db.withTx(ctx, func(s session) error {
+		err := s.queryRow(ctx, "get_invite_room",
+			`SELECT room_id FROM room_invites WHERE token_hash = ?`, hashToken(token)).Scan(&roomID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInviteNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("ошибка получения приглашения: %w", err)
+		}
+
+		user, err = insertGuest(ctx, s, roomID, ttl)
+		if err != nil {
+			return err
+		}
+		_, role, _, err = redeemInvite(ctx, s, token, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	room, err := db.GetRoomByID(ctx, roomID)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	db.log(ctx).Info("Гость вошёл по приглашению",
+		logging.KeyRoomID, roomID,
+		logging.KeyUserID, user.ID,
+		"role", role,
+	)
+	return room, user, role, nil
+}
+
+// insertGuest добавляет гостя комнаты roomID в транзакции s.
+func insertGuest(ctx context.Context, s session, roomID int, ttl time.Duration) (*User, error) {
+	token, tokenHash := newToken()
+	expiresAt := time.Now().UTC().Add(ttl)
+	user := &User{
+		Name:        guestName(),
+		Token:       token,
+		Guest:       true,
+		GuestRoomID: roomID,
+		ExpiresAt:   &expiresAt,
+	}
+
+	result, err := s.exec(ctx, "create_guest",
+		`INSERT INTO users (name, token_hash, guest_room_id, expires_at) VALUES (?, ?, ?, ?)`,
+		user.Name, tokenHash, roomID, expiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка добавления гостя: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения ID нового гостя: %w", err)
+	}
+	user.ID = int(id)
+	return user, nil
+}
+
+// UpgradeGuest превращает гостя в зарегистрированного пользователя с именем name.
+// ID, токен и участие в комнатах сохраняются.
+func (db *DB) UpgradeGuest(ctx context.Context, id int, name string) error {
+	existingUser, _ := db.GetUserByName(ctx, name)
+	if existingUser != nil {
+		return fmt.Errorf("%w: '%s'", ErrNameTaken, name)
+	}
+
+	result, err := db.exec(ctx, "upgrade_guest",
+		`UPDATE users SET name = ?, guest_room_id = NULL, expires_at = NULL
+		WHERE id = ? AND guest_room_id IS NOT NULL`, name, id)
+	if err != nil {
+		return fmt.Errorf("ошибка повышения гостя: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("ошибка проверки затронутых строк: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrNotGuest
+	}
+
+	db.log(ctx).Info("Гость зарегистрирован", logging.KeyUserID, id, "name", name)
+	return nil
+}
+
+// DeleteExpiredGuests удаляет гостей, срок которых истёк до now, вместе с их участием в комнатах.
+// Возвращает число удалённых гостей.
+func (db *DB) DeleteExpiredGuests(ctx context.Context, now time.Time) (int, error) {
+	var deleted int64
+	err := db.withTx(ctx, func(s session) error {
+		_, err := s.exec(ctx, "delete_expired_guest_memberships",
+			`DELETE FROM users_in_room WHERE user_id IN
+			(SELECT id FROM users WHERE expires_at IS NOT NULL AND expires_at <= ?)`, now.UTC())
+		if err != nil {
+			return fmt.Errorf("ошибка удаления гостей из комнат: %w", err)
+		}
+
+		result, err := s.exec(ctx, "delete_expired_guests",
+			`DELETE FROM users WHERE expires_at IS NOT NULL AND expires_at <= ?`, now.UTC())
+		if err != nil {
+			return fmt.Errorf("ошибка удаления гостей: %w", err)
+		}
+		deleted, err = result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("ошибка проверки затронутых строк: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if deleted > 0 {
+		db.log(ctx).Info("Удалены гости с истёкшим сроком", "count", deleted)
+	}
+	return int(deleted), nil
+}
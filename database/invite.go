@@ -0,0 +1,290 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"room/logging"
+	"time"
+)
+
+func (db *DB) CreateInvitesTable(ctx context.Context) error {
+	createTablesSQL := `
+	CREATE TABLE IF NOT EXISTS room_invites (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		room_id INTEGER NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		role TEXT NOT NULL DEFAULT 'member',
+		max_uses INTEGER NOT NULL DEFAULT 0,
+		uses INTEGER NOT NULL DEFAULT 0,
+		expires_at DATETIME NULL,
+		revoked_at DATETIME NULL,
+		created_by INTEGER NOT NULL,
+		created_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS room_invites_room_id ON room_invites (room_id);
+	`
+	_, err := db.exec(ctx, "create_invites_table", createTablesSQL)
+	if err != nil {
+		return fmt.Errorf("ошибка создания таблиц: %w", err)
+	}
+	db.log(ctx).Info("Таблица 'room_invites' готова")
+	return nil
+}
+
+// Invite — приглашение в комнату.
+type Invite struct {
+	ID        int        `json:"id"`
+	RoomID    int        `json:"room_id"`
+	Role      string     `json:"role"`
+	MaxUses   int        `json:"max_uses"`
+	Uses      int        `json:"uses"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedBy int        `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	// Token — секрет приглашения; заполняется только при создании.
+	Token string `json:"token,omitempty"`
+}
+
+// CreateInvite создаёт приглашение в комнату с ролью role.
+// expiresAt == nil — бессрочное приглашение, maxUses == 0 — без ограничения числа использований.
+func (db *DB) CreateInvite(ctx context.Context, roomID, createdBy int, role string, expiresAt *time.Time, maxUses int) (*Invite, error) {
+	if !ValidRole(role) || role == RoleOwner {
+		return nil, fmt.Errorf("приглашение не может выдавать роль '%s'", role)
+	}
+
+	token, tokenHash := newToken()
+	invite := &Invite{
+		RoomID:    roomID,
+		Role:      role,
+		MaxUses:   maxUses,
+		ExpiresAt: expiresAt,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now().UTC(),
+		Token:     token,
+	}
+
+	insertSQL := `INSERT INTO room_invites (room_id, token_hash, role, max_uses, expires_at, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`
+	result, err := db.exec(ctx, "create_invite", insertSQL,
+		roomID, tokenHash, role, maxUses, expiresAt, createdBy, invite.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания приглашения: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения id приглашения: %w", err)
+	}
+	invite.ID = int(id)
+
+	db.log(ctx).Info("Создано приглашение",
+		"invite_id", invite.ID,
+		logging.KeyRoomID, roomID,
+		logging.KeyUserID, createdBy,
+		"role", role,
+	)
+	return invite, nil
+}
+
+// GetRoomInvites возвращает все приглашения комнаты, включая отозванные.
+func (db *DB) GetRoomInvites(ctx context.Context, roomID int) ([]Invite, error) {
+	querySQL := `SELECT id, room_id, role, max_uses, uses, expires_at, revoked_at, created_by, created_at
+		FROM room_invites WHERE room_id = ? ORDER BY id`
+	rows, err := db.query(ctx, "get_room_invites", querySQL, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения запроса: %w", err)
+	}
+	defer rows.Close()
+
+	var invites []Invite
+	for rows.Next() {
+		invite, err := scanInvite(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования строки: %w", err)
+		}
+		invites = append(invites, *invite)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по строкам: %w", err)
+	}
+
+	return invites, nil
+}
+
+// RevokeInvite отзывает приглашение комнаты.
+func (db *DB) RevokeInvite(ctx context.Context, roomID, inviteID int) error {
+	result, err := db.exec(ctx, "revoke_invite",
+		`UPDATE room_invites SET revoked_at = ? WHERE id = ? AND room_id = ? AND revoked_at IS NULL`,
+		time.Now().UTC(), inviteID, roomID,
+	)
+	if err != nil {
+		return fmt.Errorf("ошибка отзыва приглашения: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("ошибка проверки затронутых строк: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrInviteNotFound
+	}
+
+	db.log(ctx).Info("Приглашение отозвано", "invite_id", inviteID, logging.KeyRoomID, roomID)
+	return nil
+}
+
+// RedeemInvite добавляет пользователя в комнату по приглашению и возвращает комнату,
+// роль пользователя в ней и то, изменились ли его участие или роль. Если пользователь
+// уже состоит в комнате, использование не засчитывается, а роль повышается только
+// если приглашение даёт больше прав. Гость может использовать только приглашение в свою комнату.
+func (db *DB) RedeemInvite(ctx context.Context, token string, userID int) (*Room, string, bool, error) {
+	var roomID int
+	var role string
+	var changed bool
+
+	err := db.withTx(ctx, func(s session) error {
+		var err error
+		roomID, role, changed, err = redeemInvite(ctx, s, token, userID)
+		return err
+	})
+	if err != nil {
+		return nil, "", false, err
+	}
+
+	room, err := db.GetRoomByID(ctx, roomID)
+	if err != nil {
+		return nil, "", false, err
+	}
+
+	db.log(ctx).Info("Приглашение использовано",
+		logging.KeyRoomID, roomID,
+		logging.KeyUserID, userID,
+		"role", role,
+		"changed", changed,
+	)
+	return room, role, changed, nil
+}
+
+// redeemInvite выполняет RedeemInvite в транзакции s и возвращает ID комнаты, роль
+// и то, изменились ли участие или роль пользователя.
+func redeemInvite(ctx context.Context, s session, token string, userID int) (int, string, bool, error) {
+	row := s.queryRow(ctx, "get_invite_by_token",
+		`SELECT id, room_id, role, max_uses, uses, expires_at, revoked_at, created_by, created_at
+		FROM room_invites WHERE token_hash = ?`, hashToken(token))
+	invite, err := scanInvite(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, "", false, ErrInviteNotFound
+	}
+	if err != nil {
+		return 0, "", false, fmt.Errorf("ошибка получения приглашения: %w", err)
+	}
+	switch {
+	case invite.RevokedAt != nil:
+		return 0, "", false, ErrInviteRevoked
+	case invite.ExpiresAt != nil && time.Now().After(*invite.ExpiresAt):
+		return 0, "", false, ErrInviteExpired
+	}
+	roomID := invite.RoomID
+	role := invite.Role
+
+	var guestRoomID sql.NullInt64
+	err = s.queryRow(ctx, "get_user_guest_room",
+		`SELECT guest_room_id FROM users WHERE id = ?`, userID).Scan(&guestRoomID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, "", false, fmt.Errorf("ошибка получения пользователя: %w", err)
+	}
+	if guestRoomID.Valid && int(guestRoomID.Int64) != roomID {
+		return 0, "", false, ErrGuestScope
+	}
+
+	var banned int
+	err = s.queryRow(ctx, "find_active_ban",
+		`SELECT COUNT(*) FROM room_sanctions WHERE room_id = ? AND kind = ? AND user_id = ? AND `+activeSanction,
+		roomID, SanctionBan, userID, time.Now().UTC()).Scan(&banned)
+	if err != nil {
+		return 0, "", false, fmt.Errorf("ошибка проверки бана: %w", err)
+	}
+	if banned > 0 {
+		return 0, "", false, ErrBanned
+	}
+
+	var current string
+	err = s.queryRow(ctx, "get_user_role",
+		`SELECT role FROM users_in_room WHERE user_id = ? AND room_id = ? LIMIT 1`,
+		userID, roomID).Scan(&current)
+	if err == nil {
+		if roleRank(invite.Role) <= roleRank(current) {
+			return roomID, current, false, nil
+		}
+		// Повышение роли тоже расходует приглашение
+		if err := useInvite(ctx, s, invite.ID); err != nil {
+			return 0, "", false, err
+		}
+		_, err = s.exec(ctx, "set_user_role",
+			`UPDATE users_in_room SET role = ? WHERE user_id = ? AND room_id = ?`,
+			invite.Role, userID, roomID)
+		if err != nil {
+			return 0, "", false, fmt.Errorf("ошибка обновления роли: %w", err)
+		}
+		return roomID, role, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, "", false, fmt.Errorf("ошибка получения роли пользователя: %w", err)
+	}
+
+	if err := useInvite(ctx, s, invite.ID); err != nil {
+		return 0, "", false, err
+	}
+	_, err = s.exec(ctx, "add_user_in_room",
+		`INSERT INTO users_in_room (room_id, user_id, role) VALUES (?, ?, ?)`,
+		roomID, userID, invite.Role)
+	if err != nil {
+		return 0, "", false, fmt.Errorf("ошибка добавления пользователя в комнату: %w", err)
+	}
+	return roomID, role, true, nil
+}
+
+// useInvite засчитывает одно использование приглашения id.
+// Если лимит использований исчерпан, возвращает ErrInviteExhausted.
+func useInvite(ctx context.Context, s session, id int) error {
+	// Условие в UPDATE не даёт превысить лимит при одновременных переходах по ссылке
+	result, err := s.exec(ctx, "use_invite",
+		`UPDATE room_invites SET uses = uses + 1 WHERE id = ? AND (max_uses = 0 OR uses < max_uses)`,
+		id)
+	if err != nil {
+		return fmt.Errorf("ошибка использования приглашения: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("ошибка проверки затронутых строк: %w", err)
+	} else if n == 0 {
+		return ErrInviteExhausted
+	}
+	return nil
+}
+
+// rowScanner — общее у *sql.Row и *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanInvite(row rowScanner) (*Invite, error) {
+	var invite Invite
+	var expiresAt, revokedAt sql.NullTime
+	err := row.Scan(
+		&invite.ID, &invite.RoomID, &invite.Role, &invite.MaxUses, &invite.Uses,
+		&expiresAt, &revokedAt, &invite.CreatedBy, &invite.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		invite.ExpiresAt = &expiresAt.Time
+	}
+	if revokedAt.Valid {
+		invite.RevokedAt = &revokedAt.Time
+	}
+	return &invite, nil
+}
@@ -0,0 +1,164 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"room/logging"
+	"time"
+)
+
+// TouchRoom отмечает комнату активной в текущий момент.
+func (db *DB) TouchRoom(ctx context.Context, roomID int) error {
+	_, err := db.exec(ctx, "touch_room",
+		`UPDATE rooms SET last_active_at = ? WHERE id = ?`, time.Now().UTC(), roomID)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления активности комнаты: %w", err)
+	}
+	return nil
+}
+
+// GetIdleRooms возвращает до limit неархивных комнат, неактивных с момента before,
+// начиная с самых давних.
+func (db *DB) GetIdleRooms(ctx context.Context, before time.Time, limit int) ([]Room, error) {
+	rows, err := db.query(ctx, "get_idle_rooms",
+		`SELECT `+roomColumns+` FROM rooms
+		WHERE archived_at IS NULL AND last_active_at < ?
+		ORDER BY last_active_at LIMIT ?`,
+		before.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения запроса: %w", err)
+	}
+	defer rows.Close()
+
+	var rooms []Room
+	for rows.Next() {
+		room, err := scanRoom(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования строки: %w", err)
+		}
+		rooms = append(rooms, *room)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по строкам: %w", err)
+	}
+
+	return rooms, nil
+}
+
+// idleRoom — условие, при котором комната считается заброшенной. Его повторно
+// проверяет сам запрос, архивирующий или удаляющий комнату, поэтому комнату,
+// которая ожила после выборки GetIdleRooms, очистка не затрагивает.
+const idleRoom = `archived_at IS NULL AND last_active_at < ?`
+
+// ArchiveIdleRoom помечает комнату, неактивную с момента before, архивной, отвязывает
+// от неё видео и удаляет её субтитры. Возвращает файлы удалённых субтитров или
+// ErrRoomActive, если комната с тех пор была активна. Участники, приглашения
+// и санкции сохраняются.
+func (db *DB) ArchiveIdleRoom(ctx context.Context, roomID int, before time.Time) ([]string, error) {
+	var files []string
+	err := db.withTx(ctx, func(s session) error {
+		result, err := s.exec(ctx, "archive_room",
+			`UPDATE rooms SET archived_at = ?, video = NULL, video_id = NULL WHERE id = ? AND `+idleRoom,
+			time.Now().UTC(), roomID, before.UTC())
+		if err != nil {
+			return fmt.Errorf("ошибка архивации комнаты: %w", err)
+		}
+		if err := checkIdle(result); err != nil {
+			return err
+		}
+		files, err = deleteRoomSubtitles(ctx, s, roomID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	db.log(ctx).Info("Комната перенесена в архив", logging.KeyRoomID, roomID)
+	return files, nil
+}
+
+// DeleteIdleRoom удаляет комнату, неактивную с момента before, вместе с участниками,
+// приглашениями, санкциями и субтитрами. Возвращает файлы удалённых субтитров или
+// ErrRoomActive, если комната с тех пор была активна.
+func (db *DB) DeleteIdleRoom(ctx context.Context, roomID int, before time.Time) ([]string, error) {
+	var files []string
+	err := db.withTx(ctx, func(s session) error {
+		result, err := s.exec(ctx, "delete_idle_room",
+			`DELETE FROM rooms WHERE id = ? AND `+idleRoom, roomID, before.UTC())
+		if err != nil {
+			return fmt.Errorf("ошибка удаления комнаты: %w", err)
+		}
+		if err := checkIdle(result); err != nil {
+			return err
+		}
+		files, err = deleteRoomSubtitles(ctx, s, roomID)
+		if err != nil {
+			return err
+		}
+		return deleteRoomRecords(ctx, s, roomID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	db.log(ctx).Info("Комната удалена", logging.KeyRoomID, roomID)
+	return files, nil
+}
+
+// checkIdle возвращает ErrRoomActive, если запрос очистки не затронул комнату.
+func checkIdle(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("ошибка проверки затронутых строк: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrRoomActive
+	}
+	return nil
+}
+
+// deleteRoom удаляет комнату вместе с участниками, приглашениями, санкциями
+// и субтитрами в транзакции s.
+func deleteRoom(ctx context.Context, s session, roomID int) error {
+	if err := deleteRoomRecords(ctx, s, roomID); err != nil {
+		return err
+	}
+
+	result, err := s.exec(ctx, "delete_room", `DELETE FROM rooms WHERE id = ?`, roomID)
+	if err != nil {
+		return fmt.Errorf("ошибка удаления комнаты: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("ошибка проверки затронутых строк: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("комната с ID %d не найдена", roomID)
+	}
+	return nil
+}
+
+// deleteRoomRecords удаляет участников, приглашения, санкции и субтитры комнаты.
+func deleteRoomRecords(ctx context.Context, s session, roomID int) error {
+	for _, table := range []string{"users_in_room", "room_invites", "room_sanctions", "subtitle_tracks"} {
+		_, err := s.exec(ctx, "delete_room_"+table,
+			`DELETE FROM `+table+` WHERE room_id = ?`, roomID)
+		if err != nil {
+			return fmt.Errorf("ошибка удаления записей %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// VideoInUse сообщает, указано ли видео хотя бы в одной комнате.
+func (db *DB) VideoInUse(ctx context.Context, video string) (bool, error) {
+	var count int
+	err := db.queryRow(ctx, "video_in_use",
+		`SELECT COUNT(*) FROM rooms WHERE video = ?`, video).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("ошибка проверки использования видео: %w", err)
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"room/logging"
+)
+
+// UserLimits — ограничения загрузки, назначенные пользователю администратором.
+// nil — действует значение из настроек сервиса.
+type UserLimits struct {
+	// MaxFileSize — наибольший размер одного файла в байтах.
+	MaxFileSize *int64 `json:"max_file_size"`
+	// StorageQuota — сколько байт могут занимать все видео пользователя; 0 — без ограничения.
+	StorageQuota *int64 `json:"storage_quota"`
+}
+
+// RoomLimits — ограничения комнаты, назначенные администратором.
+// nil — действует значение из настроек сервиса.
+type RoomLimits struct {
+	// MaxVideos — сколько видео можно загрузить в комнату; 0 — без ограничения.
+	MaxVideos *int `json:"max_videos"`
+}
+
+// GetUserLimits возвращает ограничения загрузки, назначенные пользователю.
+func (db *DB) GetUserLimits(ctx context.Context, userID int) (*UserLimits, error) {
+	var maxFileSize, storageQuota sql.NullInt64
+	err := db.queryRow(ctx, "get_user_limits",
+		`SELECT max_upload_size, storage_quota FROM users WHERE id = ?`, userID).Scan(&maxFileSize, &storageQuota)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения ограничений пользователя: %w", err)
+	}
+	return &UserLimits{MaxFileSize: nullInt64Ptr(maxFileSize), StorageQuota: nullInt64Ptr(storageQuota)}, nil
+}
+
+// SetUserLimits назначает пользователю ограничения загрузки.
+func (db *DB) SetUserLimits(ctx context.Context, userID int, limits UserLimits) error {
+	result, err := db.exec(ctx, "set_user_limits",
+		`UPDATE users SET max_upload_size = ?, storage_quota = ? WHERE id = ?`,
+		limits.MaxFileSize, limits.StorageQuota, userID)
+	if err != nil {
+		return fmt.Errorf("ошибка изменения ограничений пользователя: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("ошибка проверки затронутых строк: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	db.log(ctx).Info("Изменены ограничения загрузки пользователя", logging.KeyUserID, userID)
+	return nil
+}
+
+// GetRoomLimits возвращает ограничения, назначенные комнате.
+func (db *DB) GetRoomLimits(ctx context.Context, roomID int) (*RoomLimits, error) {
+	var maxVideos sql.NullInt64
+	err := db.queryRow(ctx, "get_room_limits",
+		`SELECT max_videos FROM rooms WHERE id = ?`, roomID).Scan(&maxVideos)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения ограничений комнаты: %w", err)
+	}
+	return &RoomLimits{MaxVideos: nullIntPtr(maxVideos)}, nil
+}
+
+// SetRoomLimits назначает комнате ограничения.
+func (db *DB) SetRoomLimits(ctx context.Context, roomID int, limits RoomLimits) error {
+	result, err := db.exec(ctx, "set_room_limits",
+		`UPDATE rooms SET max_videos = ? WHERE id = ?`, limits.MaxVideos, roomID)
+	if err != nil {
+		return fmt.Errorf("ошибка изменения ограничений комнаты: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("ошибка проверки затронутых строк: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("комната с ID %d не найдена", roomID)
+	}
+
+	db.log(ctx).Info("Изменены ограничения комнаты", logging.KeyRoomID, roomID)
+	return nil
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"room/logging"
+	"time"
+)
+
+func (db *DB) CreateMediaRevocationsTable(ctx context.Context) error {
+	createTablesSQL := `
+	CREATE TABLE IF NOT EXISTS media_revocations (
+		room_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		revoked_at DATETIME NOT NULL,
+		PRIMARY KEY (room_id, user_id)
+	)`
+	_, err := db.exec(ctx, "create_media_revocations_table", createTablesSQL)
+	if err != nil {
+		return fmt.Errorf("ошибка создания таблиц: %w", err)
+	}
+	db.log(ctx).Info("Таблица 'media_revocations' готова")
+	return nil
+}
+
+// RevokeMedia отзывает ссылки на медиафайлы, выданные пользователю в комнате до этого момента.
+func (db *DB) RevokeMedia(ctx context.Context, roomID, userID int) error {
+	if err := revokeMedia(ctx, session{db.conn}, roomID, userID); err != nil {
+		return err
+	}
+	db.log(ctx).Info("Ссылки на медиафайлы отозваны", logging.KeyRoomID, roomID, logging.KeyUserID, userID)
+	return nil
+}
+
+func revokeMedia(ctx context.Context, s session, roomID, userID int) error {
+	_, err := s.exec(ctx, "revoke_media",
+		`INSERT INTO media_revocations (room_id, user_id, revoked_at) VALUES (?, ?, ?)
+		ON CONFLICT (room_id, user_id) DO UPDATE SET revoked_at = excluded.revoked_at`,
+		roomID, userID, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("ошибка отзыва ссылок на медиафайлы: %w", err)
+	}
+	return nil
+}
+
+// MediaRevokedAt возвращает, когда ссылки пользователя в комнате отозваны в последний раз,
+// или нулевое время, если они не отзывались.
+func (db *DB) MediaRevokedAt(ctx context.Context, roomID, userID int) (time.Time, error) {
+	var revokedAt time.Time
+	err := db.queryRow(ctx, "get_media_revocation",
+		`SELECT revoked_at FROM media_revocations WHERE room_id = ? AND user_id = ?`, roomID, userID).Scan(&revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("ошибка проверки отзыва ссылок: %w", err)
+	}
+	return revokedAt, nil
+}
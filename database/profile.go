@@ -0,0 +1,144 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"room/logging"
+	"time"
+)
+
+// UserPreferences — настройки пользователя, которые клиент применяет во всех комнатах.
+type UserPreferences struct {
+	// SubtitleLanguage — предпочитаемый язык субтитров (код BCP 47); пусто — без субтитров.
+	SubtitleLanguage string `json:"subtitle_language"`
+	// DefaultVolume — громкость по умолчанию от 0 до 1.
+	DefaultVolume float64 `json:"default_volume"`
+}
+
+// DefaultUserPreferences возвращает настройки нового пользователя.
+func DefaultUserPreferences() UserPreferences {
+	return UserPreferences{DefaultVolume: 1}
+}
+
+// Profile — пользователь со всеми данными, которые видит он сам.
+type Profile struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// Avatar — имя файла аватара в хранилище; пусто, если аватара нет.
+	Avatar      string          `json:"-"`
+	Preferences UserPreferences `json:"preferences"`
+}
+
+// MemberRoom — комната, в которой состоит пользователь, с его ролью.
+type MemberRoom struct {
+	Key          string     `json:"key"`
+	Title        string     `json:"title"`
+	Role         string     `json:"role"`
+	Visibility   string     `json:"visibility"`
+	LastActiveAt time.Time  `json:"last_active_at"`
+	ArchivedAt   *time.Time `json:"archived_at,omitempty"`
+}
+
+// GetProfile возвращает профиль пользователя.
+func (db *DB) GetProfile(ctx context.Context, id int) (*Profile, error) {
+	row := db.queryRow(ctx, "get_profile",
+		`SELECT id, name, avatar, preferences FROM users WHERE id = ?`, id)
+
+	var p Profile
+	var avatar sql.NullString
+	var preferences string
+	if err := row.Scan(&p.ID, &p.Name, &avatar, &preferences); err != nil {
+		return nil, fmt.Errorf("ошибка получения профиля: %w", err)
+	}
+	p.Avatar = avatar.String
+	p.Preferences = DefaultUserPreferences()
+	if err := json.Unmarshal([]byte(preferences), &p.Preferences); err != nil {
+		return nil, fmt.Errorf("ошибка чтения настроек пользователя %d: %w", id, err)
+	}
+	return &p, nil
+}
+
+// SetUserPreferences сохраняет настройки пользователя.
+func (db *DB) SetUserPreferences(ctx context.Context, id int, prefs UserPreferences) error {
+	data, err := json.Marshal(prefs)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации настроек пользователя: %w", err)
+	}
+	result, err := db.exec(ctx, "set_user_preferences",
+		`UPDATE users SET preferences = ? WHERE id = ?`, string(data), id)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения настроек пользователя: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("ошибка проверки затронутых строк: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("пользователь с ID %d не найден", id)
+	}
+
+	db.log(ctx).Info("Настройки пользователя обновлены", logging.KeyUserID, id)
+	return nil
+}
+
+// SetUserAvatar сохраняет имя файла аватара и возвращает имя предыдущего,
+// чтобы вызывающий мог удалить его из хранилища. Пустое avatar удаляет аватар.
+func (db *DB) SetUserAvatar(ctx context.Context, id int, avatar string) (string, error) {
+	var previous sql.NullString
+	err := db.withTx(ctx, func(s session) error {
+		err := s.queryRow(ctx, "get_user_avatar", `SELECT avatar FROM users WHERE id = ?`, id).Scan(&previous)
+		if err != nil {
+			return fmt.Errorf("ошибка получения аватара: %w", err)
+		}
+
+		value := sql.NullString{String: avatar, Valid: avatar != ""}
+		_, err = s.exec(ctx, "set_user_avatar", `UPDATE users SET avatar = ? WHERE id = ?`, value, id)
+		if err != nil {
+			return fmt.Errorf("ошибка сохранения аватара: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	db.log(ctx).Info("Аватар пользователя обновлён", logging.KeyUserID, id)
+	return previous.String, nil
+}
+
+// GetUserRooms возвращает комнаты, в которых состоит пользователь, начиная с самых активных.
+func (db *DB) GetUserRooms(ctx context.Context, userID int) ([]MemberRoom, error) {
+	rows, err := db.query(ctx, "get_user_rooms",
+		`SELECT r.key, r.title, m.role, r.visibility, r.last_active_at, r.archived_at
+		FROM users_in_room m JOIN rooms r ON r.id = m.room_id
+		WHERE m.user_id = ?
+		ORDER BY r.last_active_at DESC, r.id DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения запроса: %w", err)
+	}
+	defer rows.Close()
+
+	var rooms []MemberRoom
+	for rows.Next() {
+		var room MemberRoom
+		var lastActiveAt, archivedAt sql.NullTime
+		err := rows.Scan(&room.Key, &room.Title, &room.Role, &room.Visibility, &lastActiveAt, &archivedAt)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования строки: %w", err)
+		}
+		room.LastActiveAt = lastActiveAt.Time
+		if archivedAt.Valid {
+			room.ArchivedAt = &archivedAt.Time
+		}
+		rooms = append(rooms, room)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по строкам: %w", err)
+	}
+
+	return rooms, nil
+}
@@ -1,94 +1,374 @@
 package database
 
 import (
+	"context"
 	"crypto/rand"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"room/logging"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
 )
+
 // RoomStorage — интерфейс для работы с хранилищем комнат и пользователей.
 type RoomStorage interface {
-	CreateRoomsTables() error
+	CreateRoomsTables(ctx context.Context) error
+
+	CreateRoom(ctx context.Context, ownerID int) (*Room, error)
+	GetRoomByID(ctx context.Context, id int) (*Room, error)
+	GetRoomByKey(ctx context.Context, key string) (*Room, error)
+
+	SetRoomVideo(ctx context.Context, roomID int, video string) error
+	SetRoomVideoByKey(ctx context.Context, key string, video string) error
+
+	AddUserInRoom(ctx context.Context, userID, roomID int) error
+	RemoveUserFromRoom(ctx context.Context, userID, roomID int) error
+	GetUsersInRoom(ctx context.Context, roomID int) ([]User, error)
+	GetUserRole(ctx context.Context, userID, roomID int) (string, error)
+
+	CreateInvite(ctx context.Context, roomID, createdBy int, role string, expiresAt *time.Time, maxUses int) (*Invite, error)
+	GetRoomInvites(ctx context.Context, roomID int) ([]Invite, error)
+	RevokeInvite(ctx context.Context, roomID, inviteID int) error
+	RedeemInvite(ctx context.Context, token string, userID int) (*Room, string, bool, error)
+
+	TouchRoom(ctx context.Context, roomID int) error
+	GetIdleRooms(ctx context.Context, before time.Time, limit int) ([]Room, error)
+	ArchiveIdleRoom(ctx context.Context, roomID int, before time.Time) ([]string, error)
+	DeleteIdleRoom(ctx context.Context, roomID int, before time.Time) ([]string, error)
+
+	SetRoomAccess(ctx context.Context, roomID int, visibility string, password *string) error
+	UpdateRoomMetadata(ctx context.Context, roomID int, meta RoomMetadata) error
+	ListPublicRooms(ctx context.Context, filter RoomFilter) ([]Room, error)
+
+	CreateSubtitleTrack(ctx context.Context, roomID int, video, language, label, file string, createdBy int) (*SubtitleTrack, error)
+	GetSubtitleTracks(ctx context.Context, roomID int, video string) ([]SubtitleTrack, error)
+	GetSubtitleTrack(ctx context.Context, roomID, trackID int) (*SubtitleTrack, error)
+	DeleteSubtitleTrack(ctx context.Context, roomID, trackID int) (*SubtitleTrack, error)
+	DeleteRoomSubtitles(ctx context.Context, roomID int) ([]string, error)
+	SetRoomSubtitles(ctx context.Context, roomID int, state SubtitleState) error
+}
+
+// Видимость комнаты.
+const (
+	// VisibilityPublic — комната видна всем и открыта по ключу.
+	VisibilityPublic = "public"
+	// VisibilityUnlisted — комната открыта по ключу, но не показывается в списках.
+	VisibilityUnlisted = "unlisted"
+	// VisibilityPrivate — комната доступна только участникам.
+	VisibilityPrivate = "private"
+)
 
-	CreateRoom() (*Room, error)
-	GetRoomByID(id int) (*Room, error)
-	GetRoomByKey(key string) (*Room, error)
+// ValidVisibility сообщает, существует ли видимость.
+func ValidVisibility(visibility string) bool {
+	switch visibility {
+	case VisibilityPublic, VisibilityUnlisted, VisibilityPrivate:
+		return true
+	}
+	return false
+}
 
-	SetRoomVideo(roomID int, video string) error
-	SetRoomVideoByKey(key string, video string) error
+// Роли участников комнаты.
+const (
+	RoleOwner     = "owner"
+	RoleModerator = "moderator"
+	RoleMember    = "member"
+)
 
-	AddUserInRoom(userID, roomID int) error
-	RemoveUserFromRoom(userID, roomID int) error
-	GetUsersInRoom(roomID int) ([]User, error)
+// ValidRole сообщает, существует ли роль.
+func ValidRole(role string) bool {
+	switch role {
+	case RoleOwner, RoleModerator, RoleMember:
+		return true
+	}
+	return false
+}
+
+// roleRank упорядочивает роли по правам: чем больше, тем больше прав.
+func roleRank(role string) int {
+	switch role {
+	case RoleOwner:
+		return 3
+	case RoleModerator:
+		return 2
+	case RoleMember:
+		return 1
+	}
+	return 0
 }
-func (db *DB) CreateRoomsTables() error {
+
+func (db *DB) CreateRoomsTables(ctx context.Context) error {
 	createTablesSQL := `
 	CREATE TABLE IF NOT EXISTS rooms (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		key TEXT UNIQUE,
-		video TEXT NULL
+		video TEXT NULL,
+		owner INTEGER NULL,
+		password_hash TEXT NULL,
+		visibility TEXT NOT NULL DEFAULT 'public',
+		created_at DATETIME NULL,
+		last_active_at DATETIME NULL,
+		archived_at DATETIME NULL,
+		title TEXT NOT NULL DEFAULT '',
+		description TEXT NOT NULL DEFAULT '',
+		cover_image TEXT NOT NULL DEFAULT '',
+		max_participants INTEGER NOT NULL DEFAULT 0,
+		playback_rate REAL NOT NULL DEFAULT 1,
+		settings TEXT NOT NULL DEFAULT '{}',
+		subtitle_track_id INTEGER NULL,
+		subtitle_offset REAL NOT NULL DEFAULT 0,
+		video_id INTEGER NULL,
+		max_videos INTEGER NULL
 	);
 	CREATE TABLE IF NOT EXISTS users_in_room (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		user_id INTEGER NOT NULL,
-		room_id INTEGER NOT NULL
+		room_id INTEGER NOT NULL,
+		role TEXT NOT NULL DEFAULT 'member'
 	)`
-	_, err := db.conn.Exec(createTablesSQL)
+	_, err := db.exec(ctx, "create_rooms_tables", createTablesSQL)
 	if err != nil {
 		return fmt.Errorf("ошибка создания таблиц: %w", err)
 	}
-	fmt.Println("Таблица 'rooms' и 'users_in_room' готова.")
+	if err := db.addColumn(ctx, "rooms", "owner", "INTEGER NULL"); err != nil {
+		return err
+	}
+	if err := db.addColumn(ctx, "rooms", "password_hash", "TEXT NULL"); err != nil {
+		return err
+	}
+	if err := db.addColumn(ctx, "rooms", "visibility", "TEXT NOT NULL DEFAULT 'public'"); err != nil {
+		return err
+	}
+	if err := db.addColumn(ctx, "users_in_room", "role", "TEXT NOT NULL DEFAULT 'member'"); err != nil {
+		return err
+	}
+	for _, column := range []string{"created_at", "last_active_at", "archived_at"} {
+		if err := db.addColumn(ctx, "rooms", column, "DATETIME NULL"); err != nil {
+			return err
+		}
+	}
+	metadataColumns := []struct{ name, definition string }{
+		{"title", "TEXT NOT NULL DEFAULT ''"},
+		{"description", "TEXT NOT NULL DEFAULT ''"},
+		{"cover_image", "TEXT NOT NULL DEFAULT ''"},
+		{"max_participants", "INTEGER NOT NULL DEFAULT 0"},
+		{"playback_rate", "REAL NOT NULL DEFAULT 1"},
+		{"settings", "TEXT NOT NULL DEFAULT '{}'"},
+		{"subtitle_track_id", "INTEGER NULL"},
+		{"subtitle_offset", "REAL NOT NULL DEFAULT 0"},
+		{"video_id", "INTEGER NULL"},
+		{"max_videos", "INTEGER NULL"},
+	}
+	for _, column := range metadataColumns {
+		if err := db.addColumn(ctx, "rooms", column.name, column.definition); err != nil {
+			return err
+		}
+	}
+	// Комнатам, созданным до появления времени активности, отсчитываем срок с момента миграции
+	now := time.Now().UTC()
+	_, err = db.exec(ctx, "backfill_room_times",
+		`UPDATE rooms SET created_at = COALESCE(created_at, ?), last_active_at = COALESCE(last_active_at, created_at, ?)
+		WHERE created_at IS NULL OR last_active_at IS NULL`, now, now)
+	if err != nil {
+		return fmt.Errorf("ошибка заполнения времени создания комнат: %w", err)
+	}
+	_, err = db.exec(ctx, "create_rooms_indexes",
+		`CREATE INDEX IF NOT EXISTS rooms_last_active_at ON rooms (archived_at, last_active_at);
+		CREATE INDEX IF NOT EXISTS rooms_directory ON rooms (visibility, archived_at, last_active_at DESC, id DESC)`)
+	if err != nil {
+		return fmt.Errorf("ошибка создания индексов: %w", err)
+	}
+	db.log(ctx).Info("Таблицы 'rooms' и 'users_in_room' готовы")
 	return nil
 }
 
+// RoomSettings — настройки поведения комнаты.
+type RoomSettings struct {
+	// AnyoneCanControl разрешает управлять воспроизведением всем участникам,
+	// а не только владельцу и модераторам.
+	AnyoneCanControl bool `json:"anyone_can_control"`
+	// AutoPauseOnBuffering просит клиентов ставить всех на паузу, пока кто-то буферизует видео.
+	AutoPauseOnBuffering bool `json:"auto_pause_on_buffering"`
+}
+
+// DefaultRoomSettings возвращает настройки новой комнаты.
+func DefaultRoomSettings() RoomSettings {
+	return RoomSettings{AnyoneCanControl: true}
+}
+
+// RoomMetadata — описание, настройки и состояние комнаты, которые рассылаются клиентам.
+type RoomMetadata struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	CoverImage  string `json:"cover_image"`
+	// MaxParticipants — предел одновременных подключений; 0 — без ограничений.
+	MaxParticipants int `json:"max_participants"`
+	// PlaybackRate — скорость воспроизведения по умолчанию.
+	PlaybackRate float64      `json:"playback_rate"`
+	Settings     RoomSettings `json:"settings"`
+	// Subtitles меняется командами WebSocket, а не вместе с описанием.
+	Subtitles SubtitleState `json:"subtitles"`
+}
+
+// DefaultRoomMetadata возвращает описание новой комнаты.
+func DefaultRoomMetadata() RoomMetadata {
+	return RoomMetadata{PlaybackRate: 1, Settings: DefaultRoomSettings()}
+}
+
 type Room struct {
-	ID    int            `json:"id"`
-	Key   string         `json:"key"`
-	Video sql.NullString `json:"video"`
-	Users []User         `json:"users"`
+	ID          int            `json:"id"`
+	Key         string         `json:"key"`
+	Video       sql.NullString `json:"video"`
+	Owner       *int           `json:"owner,omitempty"`
+	Visibility  string         `json:"visibility"`
+	HasPassword bool           `json:"has_password"`
+	Users       []User         `json:"users"`
+
+	// VideoInfo — параметры видео комнаты; nil, если они неизвестны.
+	VideoInfo *Video `json:"video_info,omitempty"`
+
+	CreatedAt    time.Time  `json:"created_at"`
+	LastActiveAt time.Time  `json:"last_active_at"`
+	ArchivedAt   *time.Time `json:"archived_at,omitempty"`
+
+	RoomMetadata
+
+	passwordHash string
+	videoID      *int
 }
 
-// CreateRoom создает новую комнату
-func (db *DB) CreateRoom() (*Room, error) {
-	insertSQL := `INSERT INTO rooms (key) VALUES (?)`
+// roomColumns — столбцы, которые читает scanRoom.
+const roomColumns = `id, key, video, owner, visibility, password_hash, created_at, last_active_at, archived_at,
+	title, description, cover_image, max_participants, playback_rate, settings,
+	subtitle_track_id, subtitle_offset, video_id`
+
+func scanRoom(row rowScanner) (*Room, error) {
+	var room Room
+	var owner sql.NullInt64
+	var passwordHash sql.NullString
+	var createdAt, lastActiveAt, archivedAt sql.NullTime
+	var settings string
+	var subtitleTrackID, videoID sql.NullInt64
+
+	err := row.Scan(&room.ID, &room.Key, &room.Video, &owner, &room.Visibility, &passwordHash,
+		&createdAt, &lastActiveAt, &archivedAt,
+		&room.Title, &room.Description, &room.CoverImage, &room.MaxParticipants, &room.PlaybackRate, &settings,
+		&subtitleTrackID, &room.Subtitles.Offset, &videoID)
+	if err != nil {
+		return nil, err
+	}
+	// Ключи, которых нет в сохранённых настройках, получают значения по умолчанию
+	room.Settings = DefaultRoomSettings()
+	if err := json.Unmarshal([]byte(settings), &room.Settings); err != nil {
+		return nil, fmt.Errorf("ошибка чтения настроек комнаты %d: %w", room.ID, err)
+	}
+	room.CreatedAt = createdAt.Time
+	room.LastActiveAt = lastActiveAt.Time
+	if archivedAt.Valid {
+		room.ArchivedAt = &archivedAt.Time
+	}
+	room.Owner = nullIntPtr(owner)
+	room.Subtitles.TrackID = nullIntPtr(subtitleTrackID)
+	room.videoID = nullIntPtr(videoID)
+	room.passwordHash = passwordHash.String
+	room.HasPassword = passwordHash.Valid && passwordHash.String != ""
+	return &room, nil
+}
 
-	key := rand.Text() // Предполагается, что у вас есть такая функция
-	row, err := db.conn.Exec(insertSQL, key)
-	
+// loadVideoInfo подставляет в комнату параметры её видео. Ошибка не мешает
+// получить комнату, поэтому только записывается в лог.
+func (db *DB) loadVideoInfo(ctx context.Context, room *Room) {
+	if room.videoID == nil {
+		return
+	}
+	video, err := db.GetVideo(ctx, *room.videoID)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка вставки комнаты: %w", err)
+		db.log(ctx).Warn("Не удалось получить параметры видео комнаты", logging.KeyRoomID, room.ID, logging.KeyError, err)
+		return
+	}
+	room.VideoInfo = video
+}
+
+// CheckPassword сообщает, подходит ли пароль к комнате.
+// Комната без пароля принимает любой.
+func (r *Room) CheckPassword(password string) bool {
+	if !r.HasPassword {
+		return true
 	}
-	roomID, err := row.LastInsertId()
+	return bcrypt.CompareHashAndPassword([]byte(r.passwordHash), []byte(password)) == nil
+}
+
+// CreateRoom создает новую комнату.
+// Если ownerID больше нуля, пользователь становится владельцем и участником комнаты.
+func (db *DB) CreateRoom(ctx context.Context, ownerID int) (*Room, error) {
+	insertSQL := `INSERT INTO rooms (key, owner, created_at, last_active_at) VALUES (?, ?, ?, ?)`
+
+	key := rand.Text()
+	now := time.Now().UTC()
+	var owner sql.NullInt64
+	if ownerID > 0 {
+		owner = sql.NullInt64{Int64: int64(ownerID), Valid: true}
+	}
+
+	var roomID int64
+	err := db.withTx(ctx, func(s session) error {
+		row, err := s.exec(ctx, "create_room", insertSQL, key, owner, now, now)
+		if err != nil {
+			return fmt.Errorf("ошибка вставки комнаты: %w", err)
+		}
+		roomID, err = row.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("ошибка получения id созданной комнаты: %w", err)
+		}
+		if ownerID > 0 {
+			_, err = s.exec(ctx, "add_user_in_room",
+				`INSERT INTO users_in_room (room_id, user_id, role) VALUES (?, ?, ?)`,
+				roomID, ownerID, RoleOwner)
+			if err != nil {
+				return fmt.Errorf("ошибка добавления владельца в комнату: %w", err)
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("ошибка получения id созданной комнаты: %w", err)
+		return nil, err
 	}
 	room := Room{
-		ID:  int(roomID),
-		Key: key,
+		ID:           int(roomID),
+		Key:          key,
+		Visibility:   VisibilityPublic,
+		CreatedAt:    now,
+		LastActiveAt: now,
+		RoomMetadata: DefaultRoomMetadata(),
+	}
+	if ownerID > 0 {
+		room.Owner = &ownerID
 	}
 
-	fmt.Printf("Созданна комната: key - '%s'\n", key)
+	db.log(ctx).Info("Создана комната", logging.KeyRoomID, roomID, logging.KeyRoomKey, key)
 	return &room, nil
 }
 
-func (db *DB) GetRoomByID(id int) (*Room, error) {
-	querySQL := `SELECT id, key, video FROM rooms WHERE id = ?`
-	row := db.conn.QueryRow(querySQL, id)
-
-	var room Room
+func (db *DB) GetRoomByID(ctx context.Context, id int) (*Room, error) {
+	querySQL := `SELECT ` + roomColumns + ` FROM rooms WHERE id = ?`
+	row := db.queryRow(ctx, "get_room_by_id", querySQL, id)
 
-	err := row.Scan(&room.ID, &room.Key, &room.Video)
+	room, err := scanRoom(row)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка получения комнаты по ID: %w", err)
 	}
 
-	room.Users, _ = db.GetUsersInRoom(room.ID)
+	room.Users, _ = db.GetUsersInRoom(ctx, room.ID)
+	db.loadVideoInfo(ctx, room)
 
-	return &room, nil
+	return room, nil
 }
 
-func (db *DB) GetUsersInRoom(roomID int) ([]User, error) {
+func (db *DB) GetUsersInRoom(ctx context.Context, roomID int) ([]User, error) {
 	querySQL := `SELECT user_id FROM users_in_room WHERE room_id = ?`
-	rows, err := db.conn.Query(querySQL, roomID)
+	rows, err := db.query(ctx, "get_users_in_room", querySQL, roomID)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка выполнения запроса: %w", err)
 	}
@@ -102,7 +382,7 @@ func (db *DB) GetUsersInRoom(roomID int) ([]User, error) {
 			return nil, fmt.Errorf("ошибка сканирования строки: %w", err)
 		}
 
-		user, err := db.GetUserByID(userID)
+		user, err := db.GetUserByID(ctx, userID)
 		if err != nil {
 			return nil, fmt.Errorf("ошибка поиска пользователя %d: %w", userID, err)
 		}
@@ -117,19 +397,33 @@ func (db *DB) GetUsersInRoom(roomID int) ([]User, error) {
 	return users, nil
 }
 
-func (db *DB) AddUserInRoom(userID, roomID int) error {
+func (db *DB) AddUserInRoom(ctx context.Context, userID, roomID int) error {
 	insertSQL := `INSERT INTO users_in_room (room_id, user_id) VALUES (?, ?)`
-	_, err := db.conn.Exec(insertSQL, roomID, userID)
+	_, err := db.exec(ctx, "add_user_in_room", insertSQL, roomID, userID)
 	if err != nil {
 		return fmt.Errorf("ошибка добавления пользователя в комнату(room_id: '%d', user_id: '%d'): %w", roomID, userID, err)
 	}
-	fmt.Printf("Пользователь добавлен: пользователь: '%d' -> комната: '%d'\n", userID, roomID)
+	db.log(ctx).Info("Пользователь добавлен в комнату", logging.KeyUserID, userID, logging.KeyRoomID, roomID)
 	return nil
 }
 
+// GetUserRole возвращает роль пользователя в комнате или ErrNotMember.
+func (db *DB) GetUserRole(ctx context.Context, userID, roomID int) (string, error) {
+	querySQL := `SELECT role FROM users_in_room WHERE user_id = ? AND room_id = ? LIMIT 1`
+	var role string
+	err := db.queryRow(ctx, "get_user_role", querySQL, userID, roomID).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotMember
+	}
+	if err != nil {
+		return "", fmt.Errorf("ошибка получения роли пользователя: %w", err)
+	}
+	return role, nil
+}
+
 // RemoveUserFromRoom удаляет пользователя из комнаты.
-func (db *DB) RemoveUserFromRoom(userID, roomID int) error {
-	result, err := db.conn.Exec(
+func (db *DB) RemoveUserFromRoom(ctx context.Context, userID, roomID int) error {
+	result, err := db.exec(ctx, "remove_user_from_room",
 		"DELETE FROM users_in_room WHERE user_id = ? AND room_id = ?",
 		userID, roomID,
 	)
@@ -145,36 +439,53 @@ func (db *DB) RemoveUserFromRoom(userID, roomID int) error {
 		return fmt.Errorf("пользователь %d не найден в комнате %d", userID, roomID)
 	}
 
-	fmt.Printf("Пользователь %d удалён из комнаты %d\n", userID, roomID)
+	db.log(ctx).Info("Пользователь удалён из комнаты", logging.KeyUserID, userID, logging.KeyRoomID, roomID)
 	return nil
 }
 
 // GetRoomByKey получает комнату по её уникальному ключу.
-func (db *DB) GetRoomByKey(key string) (*Room, error) {
-	querySQL := `SELECT id, key, video FROM rooms WHERE key = ?`
-	row := db.conn.QueryRow(querySQL, key)
-
-	var room Room
+func (db *DB) GetRoomByKey(ctx context.Context, key string) (*Room, error) {
+	querySQL := `SELECT ` + roomColumns + ` FROM rooms WHERE key = ?`
+	row := db.queryRow(ctx, "get_room_by_key", querySQL, key)
 
-	err := row.Scan(&room.ID, &room.Key, &room.Video)
+	room, err := scanRoom(row)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка получения комнаты по ключу: %w", err)
 	}
 
-	// Загружаем пользователей в комнате
-	room.Users, _ = db.GetUsersInRoom(room.ID)
+	// Загружаем пользователей в комнате и параметры видео
+	room.Users, _ = db.GetUsersInRoom(ctx, room.ID)
+	db.loadVideoInfo(ctx, room)
 
-	return &room, nil
+	return room, nil
 }
 
-// SetRoomVideo устанавливает видео для комнаты.
-func (db *DB) SetRoomVideo(roomID int, video string) error {
-	var query string
-	query = "UPDATE rooms SET video = ? WHERE id = ?"
+// SetRoomAccess меняет видимость комнаты и пароль.
+// password == nil оставляет пароль без изменений, пустая строка снимает пароль.
+func (db *DB) SetRoomAccess(ctx context.Context, roomID int, visibility string, password *string) error {
+	if !ValidVisibility(visibility) {
+		return fmt.Errorf("неизвестная видимость комнаты '%s'", visibility)
+	}
 
-	result, err := db.conn.Exec(query, video, roomID)
+	var result sql.Result
+	var err error
+	if password == nil {
+		result, err = db.exec(ctx, "set_room_access",
+			`UPDATE rooms SET visibility = ? WHERE id = ?`, visibility, roomID)
+	} else {
+		var passwordHash sql.NullString
+		if *password != "" {
+			hash, err := bcrypt.GenerateFromPassword([]byte(*password), bcrypt.DefaultCost)
+			if err != nil {
+				return fmt.Errorf("ошибка хеширования пароля: %w", err)
+			}
+			passwordHash = sql.NullString{String: string(hash), Valid: true}
+		}
+		result, err = db.exec(ctx, "set_room_access",
+			`UPDATE rooms SET visibility = ?, password_hash = ? WHERE id = ?`, visibility, passwordHash, roomID)
+	}
 	if err != nil {
-		return fmt.Errorf("ошибка установки видео для комнаты: %w", err)
+		return fmt.Errorf("ошибка изменения доступа к комнате: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
@@ -185,21 +496,75 @@ func (db *DB) SetRoomVideo(roomID int, video string) error {
 		return fmt.Errorf("комната с ID %d не найдена", roomID)
 	}
 
-	fmt.Printf("Видео %s установлено для комнаты %d\n", video, roomID)
+	db.log(ctx).Info("Изменён доступ к комнате",
+		logging.KeyRoomID, roomID,
+		"visibility", visibility,
+		"password_changed", password != nil,
+	)
 	return nil
 }
-func (db *DB) SetRoomVideoByKey(key string, video string) error {
-	
-	room, err := db.GetRoomByKey(key)
+
+// UpdateRoomMetadata сохраняет описание и настройки комнаты. Субтитры сохраняет SetRoomSubtitles.
+func (db *DB) UpdateRoomMetadata(ctx context.Context, roomID int, meta RoomMetadata) error {
+	settings, err := json.Marshal(meta.Settings)
 	if err != nil {
-		return fmt.Errorf("ошибка поиска комнаты в установке видео для комнаты: %w", err)
+		return fmt.Errorf("ошибка сериализации настроек комнаты: %w", err)
 	}
 
-	var query string
+	result, err := db.exec(ctx, "update_room_metadata",
+		`UPDATE rooms SET title = ?, description = ?, cover_image = ?, max_participants = ?,
+		playback_rate = ?, settings = ? WHERE id = ?`,
+		meta.Title, meta.Description, meta.CoverImage, meta.MaxParticipants,
+		meta.PlaybackRate, string(settings), roomID)
+	if err != nil {
+		return fmt.Errorf("ошибка изменения описания комнаты: %w", err)
+	}
 
-	query = "UPDATE rooms SET video = ? WHERE id = ?"
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("ошибка проверки затронутых строк: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("комната с ID %d не найдена", roomID)
+	}
 
-	result, err := db.conn.Exec(query, video, room.ID)
+	db.log(ctx).Info("Изменено описание комнаты", logging.KeyRoomID, roomID)
+	return nil
+}
+
+// setRoomVideoSQL меняет видео комнаты. Дорожки субтитров привязаны к видео,
+// поэтому при смене видео выбор дорожки и сдвиг сбрасываются.
+const setRoomVideoSQL = `UPDATE rooms SET
+	subtitle_track_id = CASE WHEN video IS ? THEN subtitle_track_id ELSE NULL END,
+	subtitle_offset = CASE WHEN video IS ? THEN subtitle_offset ELSE 0 END,
+	video = ?, video_id = (SELECT id FROM videos WHERE name = ?), last_active_at = ? WHERE id = ?`
+
+// SetRoomVideo устанавливает видео для комнаты.
+func (db *DB) SetRoomVideo(ctx context.Context, roomID int, video string) error {
+	result, err := db.exec(ctx, "set_room_video", setRoomVideoSQL, video, video, video, video, time.Now().UTC(), roomID)
+	if err != nil {
+		return fmt.Errorf("ошибка установки видео для комнаты: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("ошибка проверки затронутых строк: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("комната с ID %d не найдена", roomID)
+	}
+
+	db.log(ctx).Info("Видео установлено для комнаты", "video", video, logging.KeyRoomID, roomID)
+	return nil
+}
+func (db *DB) SetRoomVideoByKey(ctx context.Context, key string, video string) error {
+
+	room, err := db.GetRoomByKey(ctx, key)
+	if err != nil {
+		return fmt.Errorf("ошибка поиска комнаты в установке видео для комнаты: %w", err)
+	}
+
+	result, err := db.exec(ctx, "set_room_video", setRoomVideoSQL, video, video, video, video, time.Now().UTC(), room.ID)
 	if err != nil {
 		return fmt.Errorf("ошибка установки видео для комнаты: %w", err)
 	}
@@ -212,7 +577,7 @@ func (db *DB) SetRoomVideoByKey(key string, video string) error {
 		return fmt.Errorf("комната с ID %d не найдена", room.ID)
 	}
 
-	fmt.Printf("Видео %s установлено для комнаты %d\n", video, room.ID)
+	db.log(ctx).Info("Видео установлено для комнаты", "video", video, logging.KeyRoomID, room.ID)
 	return nil
 }
 
@@ -230,3 +595,18 @@ func (db *DB) removeDuplicateRooms(rooms []Room) []Room {
 
 	return result
 }
+
+func nullIntPtr(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	i := int(v.Int64)
+	return &i
+}
+
+func nullInt64Ptr(v sql.NullInt64) *int64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Int64
+}
@@ -0,0 +1,254 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"room/logging"
+	"time"
+)
+
+// Виды санкций.
+const (
+	SanctionBan  = "ban"
+	SanctionMute = "mute"
+)
+
+// Области действия мьюта.
+const (
+	MuteScopeControl = "control"
+	MuteScopeChat    = "chat"
+	MuteScopeAll     = "all"
+)
+
+// ValidMuteScope сообщает, существует ли область действия мьюта.
+func ValidMuteScope(scope string) bool {
+	switch scope {
+	case MuteScopeControl, MuteScopeChat, MuteScopeAll:
+		return true
+	}
+	return false
+}
+
+func (db *DB) CreateSanctionsTable(ctx context.Context) error {
+	createTablesSQL := `
+	CREATE TABLE IF NOT EXISTS room_sanctions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		room_id INTEGER NOT NULL,
+		kind TEXT NOT NULL,
+		user_id INTEGER NULL,
+		ip TEXT NULL,
+		scope TEXT NOT NULL DEFAULT '',
+		reason TEXT NOT NULL DEFAULT '',
+		expires_at DATETIME NULL,
+		lifted_at DATETIME NULL,
+		created_by INTEGER NOT NULL,
+		created_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS room_sanctions_room_id ON room_sanctions (room_id, kind);
+	`
+	_, err := db.exec(ctx, "create_sanctions_table", createTablesSQL)
+	if err != nil {
+		return fmt.Errorf("ошибка создания таблиц: %w", err)
+	}
+	db.log(ctx).Info("Таблица 'room_sanctions' готова")
+	return nil
+}
+
+// Sanction — бан или мьют пользователя в комнате.
+// Бан может быть выдан по пользователю или по IP-адресу.
+type Sanction struct {
+	ID        int        `json:"id"`
+	RoomID    int        `json:"room_id"`
+	Kind      string     `json:"kind"`
+	UserID    *int       `json:"user_id,omitempty"`
+	IP        string     `json:"ip,omitempty"`
+	Scope     string     `json:"scope,omitempty"`
+	Reason    string     `json:"reason"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	LiftedAt  *time.Time `json:"lifted_at,omitempty"`
+	CreatedBy int        `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Active сообщает, действует ли санкция сейчас.
+func (s *Sanction) Active(now time.Time) bool {
+	return s.LiftedAt == nil && (s.ExpiresAt == nil || now.Before(*s.ExpiresAt))
+}
+
+const sanctionColumns = `id, room_id, kind, user_id, ip, scope, reason, expires_at, lifted_at, created_by, created_at`
+
+// activeSanction — условие на действующую санкцию, параметр — текущее время.
+const activeSanction = `lifted_at IS NULL AND (expires_at IS NULL OR expires_at > ?)`
+
+func scanSanction(row rowScanner) (*Sanction, error) {
+	var s Sanction
+	var userID sql.NullInt64
+	var ip sql.NullString
+	var expiresAt, liftedAt sql.NullTime
+	err := row.Scan(&s.ID, &s.RoomID, &s.Kind, &userID, &ip, &s.Scope, &s.Reason,
+		&expiresAt, &liftedAt, &s.CreatedBy, &s.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	s.UserID = nullIntPtr(userID)
+	s.IP = ip.String
+	if expiresAt.Valid {
+		s.ExpiresAt = &expiresAt.Time
+	}
+	if liftedAt.Valid {
+		s.LiftedAt = &liftedAt.Time
+	}
+	return &s, nil
+}
+
+// CreateSanction сохраняет санкцию и заполняет её ID и время создания.
+// Бан пользователя также удаляет его из участников комнаты и отзывает его ссылки на медиафайлы.
+func (db *DB) CreateSanction(ctx context.Context, s *Sanction) error {
+	if s.UserID == nil && s.IP == "" {
+		return fmt.Errorf("санкция должна указывать пользователя или IP-адрес")
+	}
+	s.CreatedAt = time.Now().UTC()
+
+	var userID sql.NullInt64
+	if s.UserID != nil {
+		userID = sql.NullInt64{Int64: int64(*s.UserID), Valid: true}
+	}
+	var ip sql.NullString
+	if s.IP != "" {
+		ip = sql.NullString{String: s.IP, Valid: true}
+	}
+
+	err := db.withTx(ctx, func(tx session) error {
+		result, err := tx.exec(ctx, "create_sanction",
+			`INSERT INTO room_sanctions (room_id, kind, user_id, ip, scope, reason, expires_at, created_by, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			s.RoomID, s.Kind, userID, ip, s.Scope, s.Reason, s.ExpiresAt, s.CreatedBy, s.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("ошибка сохранения санкции: %w", err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("ошибка получения id санкции: %w", err)
+		}
+		s.ID = int(id)
+
+		if s.Kind == SanctionBan && s.UserID != nil {
+			_, err = tx.exec(ctx, "remove_user_from_room",
+				`DELETE FROM users_in_room WHERE user_id = ? AND room_id = ?`, *s.UserID, s.RoomID)
+			if err != nil {
+				return fmt.Errorf("ошибка удаления пользователя из комнаты: %w", err)
+			}
+			if err := revokeMedia(ctx, tx, s.RoomID, *s.UserID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	db.log(ctx).Info("Санкция сохранена",
+		"sanction_id", s.ID,
+		"kind", s.Kind,
+		logging.KeyRoomID, s.RoomID,
+	)
+	return nil
+}
+
+// LiftSanction досрочно снимает санкцию в комнате.
+func (db *DB) LiftSanction(ctx context.Context, roomID, id int) (*Sanction, error) {
+	now := time.Now().UTC()
+	result, err := db.exec(ctx, "lift_sanction",
+		`UPDATE room_sanctions SET lifted_at = ? WHERE id = ? AND room_id = ? AND `+activeSanction,
+		now, id, roomID, now)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка снятия санкции: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка проверки затронутых строк: %w", err)
+	}
+	if rowsAffected == 0 {
+		return nil, ErrSanctionNotFound
+	}
+
+	row := db.queryRow(ctx, "get_sanction", `SELECT `+sanctionColumns+` FROM room_sanctions WHERE id = ?`, id)
+	s, err := scanSanction(row)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения санкции: %w", err)
+	}
+
+	db.log(ctx).Info("Санкция снята", "sanction_id", id, logging.KeyRoomID, roomID)
+	return s, nil
+}
+
+// GetActiveSanctions возвращает действующие санкции комнаты.
+func (db *DB) GetActiveSanctions(ctx context.Context, roomID int) ([]Sanction, error) {
+	rows, err := db.query(ctx, "get_active_sanctions",
+		`SELECT `+sanctionColumns+` FROM room_sanctions WHERE room_id = ? AND `+activeSanction+` ORDER BY id`,
+		roomID, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения запроса: %w", err)
+	}
+	defer rows.Close()
+
+	var sanctions []Sanction
+	for rows.Next() {
+		s, err := scanSanction(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования строки: %w", err)
+		}
+		sanctions = append(sanctions, *s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по строкам: %w", err)
+	}
+
+	return sanctions, nil
+}
+
+// FindActiveBan возвращает действующий бан пользователя или IP-адреса в комнате,
+// либо nil, если бана нет. userID == 0 — анонимный пользователь.
+// Бан по IP не действует на владельца комнаты и на модераторов, если их забанил не владелец:
+// автор бана не может модерировать их сам, а общий адрес не должен этого позволять.
+func (db *DB) FindActiveBan(ctx context.Context, roomID, userID int, ip string) (*Sanction, error) {
+	row := db.queryRow(ctx, "find_active_ban",
+		`SELECT `+sanctionColumns+` FROM room_sanctions
+		WHERE room_id = ? AND kind = ? AND `+activeSanction+` AND (user_id = ? OR (ip = ? AND NOT (
+			COALESCE((SELECT role FROM users_in_room target WHERE target.user_id = ? AND target.room_id = room_sanctions.room_id), '') = ?
+			OR (COALESCE((SELECT role FROM users_in_room target WHERE target.user_id = ? AND target.room_id = room_sanctions.room_id), '') = ?
+				AND COALESCE((SELECT role FROM users_in_room author WHERE author.user_id = room_sanctions.created_by AND author.room_id = room_sanctions.room_id), '') != ?)
+		)))
+		ORDER BY id DESC LIMIT 1`,
+		roomID, SanctionBan, time.Now().UTC(), userID, ip,
+		userID, RoleOwner, userID, RoleModerator, RoleOwner)
+	s, err := scanSanction(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка проверки бана: %w", err)
+	}
+	return s, nil
+}
+
+// FindActiveMute возвращает действующий мьют пользователя в комнате или nil.
+func (db *DB) FindActiveMute(ctx context.Context, roomID, userID int) (*Sanction, error) {
+	row := db.queryRow(ctx, "find_active_mute",
+		`SELECT `+sanctionColumns+` FROM room_sanctions
+		WHERE room_id = ? AND kind = ? AND user_id = ? AND `+activeSanction+`
+		ORDER BY id DESC LIMIT 1`,
+		roomID, SanctionMute, userID, time.Now().UTC())
+	s, err := scanSanction(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка проверки мьюта: %w", err)
+	}
+	return s, nil
+}
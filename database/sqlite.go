@@ -2,8 +2,19 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"log/slog"
+	"room/logging"
+	"room/metrics"
+	"room/tracing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 
 	// Импортируем драйвер SQLite. Пустой импорт _ регистрирует драйвер.
 	_ "github.com/mattn/go-sqlite3"
@@ -11,13 +22,15 @@ import (
 
 // DB - структура, инкапсулирующая соединение с базой данных.
 type DB struct {
-	conn *sql.DB
+	conn   *sql.DB
+	logger *slog.Logger
 }
 
 // New создает новое подключение к базе данных SQLite.
 // filepath - путь к файлу базы данных (например, "./example.db").
+// logger используется для запросов, в контексте которых нет своего логгера.
 // Возвращает указатель на DB и ошибку, если подключение не удалось.
-func New(filepath string) (*DB, error) {
+func New(filepath string, logger *slog.Logger) (*DB, error) {
 	// Открываем соединение с базой данных
 	dbConn, err := sql.Open("sqlite3", filepath)
 	if err != nil {
@@ -31,16 +44,40 @@ func New(filepath string) (*DB, error) {
 		return nil, fmt.Errorf("не удалось подключиться к базе данных: %w", err)
 	}
 
-	fmt.Println("Успешное подключение к SQLite!")
-	return &DB{conn: dbConn}, nil
+	logger.Info("Успешное подключение к SQLite", "path", filepath)
+	return &DB{conn: dbConn, logger: logger}, nil
 }
-func (db *DB) CreateTable() error {
-	if err := db.CreateUsersTable(); err != nil {
+func (db *DB) CreateTable(ctx context.Context) error {
+	if err := db.CreateUsersTable(ctx); err != nil {
 		return fmt.Errorf("ошибка users: %w", err)
 	}
-	if err := db.CreateRoomsTables(); err != nil {
+	if err := db.CreateRoomsTables(ctx); err != nil {
 		return fmt.Errorf("ошибка rooms: %w", err)
 	}
+	if err := db.CreateInvitesTable(ctx); err != nil {
+		return fmt.Errorf("ошибка room_invites: %w", err)
+	}
+	if err := db.CreateSanctionsTable(ctx); err != nil {
+		return fmt.Errorf("ошибка room_sanctions: %w", err)
+	}
+	if err := db.CreateSubtitlesTable(ctx); err != nil {
+		return fmt.Errorf("ошибка subtitle_tracks: %w", err)
+	}
+	if err := db.CreateTranscodeJobsTable(ctx); err != nil {
+		return fmt.Errorf("ошибка transcode_jobs: %w", err)
+	}
+	if err := db.CreateVideosTable(ctx); err != nil {
+		return fmt.Errorf("ошибка videos: %w", err)
+	}
+	if err := db.CreateBlobsTable(ctx); err != nil {
+		return fmt.Errorf("ошибка blobs: %w", err)
+	}
+	if err := db.CreateMediaRevocationsTable(ctx); err != nil {
+		return fmt.Errorf("ошибка media_revocations: %w", err)
+	}
+	if err := db.CreateAuditEventsTable(ctx); err != nil {
+		return fmt.Errorf("ошибка audit_events: %w", err)
+	}
 	return nil
 }
 
@@ -51,3 +88,146 @@ func (db *DB) Close() error {
 	}
 	return nil
 }
+
+// Ping проверяет, что соединение с базой данных живо.
+func (db *DB) Ping(ctx context.Context) error {
+	if err := db.conn.PingContext(ctx); err != nil {
+		return fmt.Errorf("база данных недоступна: %w", err)
+	}
+	return nil
+}
+
+// querier — общее у *sql.DB и *sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// session выполняет запросы через соединение или транзакцию,
+// записывая их длительность в метрики и создавая для них спаны трассировки.
+// name — короткое стабильное имя запроса для метки метрики и имени спана.
+type session struct {
+	q querier
+}
+
+func (s session) exec(ctx context.Context, name, query string, args ...any) (sql.Result, error) {
+	ctx, finish := observe(ctx, name, query)
+	result, err := s.q.ExecContext(ctx, query, args...)
+	finish(err)
+	return result, err
+}
+
+func (s session) query(ctx context.Context, name, query string, args ...any) (*sql.Rows, error) {
+	ctx, finish := observe(ctx, name, query)
+	rows, err := s.q.QueryContext(ctx, query, args...)
+	finish(err)
+	return rows, err
+}
+
+func (s session) queryRow(ctx context.Context, name, query string, args ...any) *sql.Row {
+	ctx, finish := observe(ctx, name, query)
+	row := s.q.QueryRowContext(ctx, query, args...)
+	err := row.Err()
+	if errors.Is(err, sql.ErrNoRows) {
+		err = nil
+	}
+	finish(err)
+	return row
+}
+
+// exec выполняет запрос без результата вне транзакции.
+func (db *DB) exec(ctx context.Context, name, query string, args ...any) (sql.Result, error) {
+	return session{db.conn}.exec(ctx, name, query, args...)
+}
+
+// query выполняет запрос, возвращающий строки, вне транзакции.
+func (db *DB) query(ctx context.Context, name, query string, args ...any) (*sql.Rows, error) {
+	return session{db.conn}.query(ctx, name, query, args...)
+}
+
+// queryRow выполняет запрос, возвращающий одну строку, вне транзакции.
+func (db *DB) queryRow(ctx context.Context, name, query string, args ...any) *sql.Row {
+	return session{db.conn}.queryRow(ctx, name, query, args...)
+}
+
+// withTx выполняет fn в транзакции. Если fn вернула ошибку, транзакция откатывается.
+func (db *DB) withTx(ctx context.Context, fn func(s session) error) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	if err := fn(session{tx}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+	return nil
+}
+
+// addColumn добавляет столбец в таблицу, если его ещё нет.
+// CREATE TABLE IF NOT EXISTS не меняет таблицы, созданные прежними версиями сервиса.
+func (db *DB) addColumn(ctx context.Context, table, column, definition string) error {
+	rows, err := db.query(ctx, "table_info", fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("ошибка чтения структуры таблицы %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, typ        string
+			defaultValue     sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &defaultValue, &pk); err != nil {
+			return fmt.Errorf("ошибка чтения структуры таблицы %s: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("ошибка чтения структуры таблицы %s: %w", table, err)
+	}
+	rows.Close()
+
+	alterSQL := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)
+	if _, err := db.exec(ctx, "add_column", alterSQL); err != nil {
+		return fmt.Errorf("ошибка добавления столбца %s.%s: %w", table, column, err)
+	}
+	db.log(ctx).Info("Добавлен столбец", "table", table, "column", column)
+	return nil
+}
+
+// log возвращает логгер запроса из ctx (с request_id) или логгер базы.
+func (db *DB) log(ctx context.Context) *slog.Logger {
+	return logging.FromContextOr(ctx, db.logger)
+}
+
+// observe начинает спан запроса и возвращает функцию, которая завершает его
+// и записывает длительность запроса в метрики.
+func observe(ctx context.Context, name, query string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := tracing.Tracer().Start(ctx, "db."+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNameSQLite,
+			semconv.DBOperationName(name),
+			semconv.DBQueryText(query),
+		),
+	)
+
+	return ctx, func(err error) {
+		status := "ok"
+		if err != nil {
+			status = "error"
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+		metrics.DBQueryDuration.WithLabelValues(name, status).Observe(time.Since(start).Seconds())
+	}
+}
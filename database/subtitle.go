@@ -0,0 +1,236 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"room/logging"
+	"time"
+)
+
+func (db *DB) CreateSubtitlesTable(ctx context.Context) error {
+	createTablesSQL := `
+	CREATE TABLE IF NOT EXISTS subtitle_tracks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		room_id INTEGER NOT NULL,
+		video TEXT NOT NULL,
+		language TEXT NOT NULL,
+		label TEXT NOT NULL DEFAULT '',
+		file TEXT NOT NULL,
+		created_by INTEGER NOT NULL,
+		created_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS subtitle_tracks_room_video ON subtitle_tracks (room_id, video);
+	`
+	_, err := db.exec(ctx, "create_subtitles_table", createTablesSQL)
+	if err != nil {
+		return fmt.Errorf("ошибка создания таблиц: %w", err)
+	}
+	db.log(ctx).Info("Таблица 'subtitle_tracks' готова")
+	return nil
+}
+
+// SubtitleTrack — дорожка субтитров к видео комнаты. Файл хранится в WebVTT.
+type SubtitleTrack struct {
+	ID        int       `json:"id"`
+	RoomID    int       `json:"room_id"`
+	Video     string    `json:"video"`
+	Language  string    `json:"language"`
+	Label     string    `json:"label"`
+	File      string    `json:"-"`
+	CreatedBy int       `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SubtitleState — выбранная в комнате дорожка и общий для всех сдвиг субтитров.
+type SubtitleState struct {
+	// TrackID — выбранная дорожка; nil — субтитры выключены.
+	TrackID *int `json:"track_id"`
+	// Offset — сдвиг субтитров относительно видео в секундах.
+	Offset float64 `json:"offset"`
+}
+
+const subtitleTrackColumns = `id, room_id, video, language, label, file, created_by, created_at`
+
+func scanSubtitleTrack(row rowScanner) (*SubtitleTrack, error) {
+	var track SubtitleTrack
+	err := row.Scan(&track.ID, &track.RoomID, &track.Video, &track.Language, &track.Label,
+		&track.File, &track.CreatedBy, &track.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &track, nil
+}
+
+// CreateSubtitleTrack добавляет дорожку субтитров к видео video комнаты roomID.
+func (db *DB) CreateSubtitleTrack(ctx context.Context, roomID int, video, language, label, file string, createdBy int) (*SubtitleTrack, error) {
+	track := &SubtitleTrack{
+		RoomID:    roomID,
+		Video:     video,
+		Language:  language,
+		Label:     label,
+		File:      file,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now().UTC(),
+	}
+	result, err := db.exec(ctx, "create_subtitle_track",
+		`INSERT INTO subtitle_tracks (room_id, video, language, label, file, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		roomID, video, language, label, file, createdBy, track.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка добавления субтитров: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения ID субтитров: %w", err)
+	}
+	track.ID = int(id)
+
+	db.log(ctx).Info("Субтитры добавлены",
+		"track_id", track.ID,
+		logging.KeyRoomID, roomID,
+		"language", language,
+	)
+	return track, nil
+}
+
+// GetSubtitleTracks возвращает дорожки субтитров к видео video комнаты roomID.
+func (db *DB) GetSubtitleTracks(ctx context.Context, roomID int, video string) ([]SubtitleTrack, error) {
+	rows, err := db.query(ctx, "get_subtitle_tracks",
+		`SELECT `+subtitleTrackColumns+` FROM subtitle_tracks
+		WHERE room_id = ? AND video = ? ORDER BY language, id`, roomID, video)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения субтитров: %w", err)
+	}
+	defer rows.Close()
+
+	tracks := []SubtitleTrack{}
+	for rows.Next() {
+		track, err := scanSubtitleTrack(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования строки: %w", err)
+		}
+		tracks = append(tracks, *track)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по строкам: %w", err)
+	}
+	return tracks, nil
+}
+
+// GetSubtitleTrack возвращает дорожку субтитров комнаты roomID.
+func (db *DB) GetSubtitleTrack(ctx context.Context, roomID, trackID int) (*SubtitleTrack, error) {
+	row := db.queryRow(ctx, "get_subtitle_track",
+		`SELECT `+subtitleTrackColumns+` FROM subtitle_tracks WHERE id = ? AND room_id = ?`, trackID, roomID)
+	track, err := scanSubtitleTrack(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSubtitleNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения субтитров: %w", err)
+	}
+	return track, nil
+}
+
+// DeleteSubtitleTrack удаляет дорожку субтитров комнаты roomID и снимает её выбор в комнате.
+// Возвращает удалённую дорожку, чтобы вызывающий удалил её файл.
+func (db *DB) DeleteSubtitleTrack(ctx context.Context, roomID, trackID int) (*SubtitleTrack, error) {
+	var track *SubtitleTrack
+	err := db.withTx(ctx, func(s session) error {
+		row := s.queryRow(ctx, "get_subtitle_track",
+			`SELECT `+subtitleTrackColumns+` FROM subtitle_tracks WHERE id = ? AND room_id = ?`, trackID, roomID)
+		var err error
+		track, err = scanSubtitleTrack(row)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrSubtitleNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("ошибка получения субтитров: %w", err)
+		}
+
+		_, err = s.exec(ctx, "unselect_subtitle_track",
+			`UPDATE rooms SET subtitle_track_id = NULL WHERE id = ? AND subtitle_track_id = ?`, roomID, trackID)
+		if err != nil {
+			return fmt.Errorf("ошибка снятия выбора субтитров: %w", err)
+		}
+		_, err = s.exec(ctx, "delete_subtitle_track", `DELETE FROM subtitle_tracks WHERE id = ?`, trackID)
+		if err != nil {
+			return fmt.Errorf("ошибка удаления субтитров: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	db.log(ctx).Info("Субтитры удалены", "track_id", trackID, logging.KeyRoomID, roomID)
+	return track, nil
+}
+
+// DeleteRoomSubtitles удаляет все дорожки субтитров комнаты и возвращает их файлы.
+func (db *DB) DeleteRoomSubtitles(ctx context.Context, roomID int) ([]string, error) {
+	var files []string
+	err := db.withTx(ctx, func(s session) error {
+		var err error
+		files, err = deleteRoomSubtitles(ctx, s, roomID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// deleteRoomSubtitles выполняет DeleteRoomSubtitles в транзакции s.
+func deleteRoomSubtitles(ctx context.Context, s session, roomID int) ([]string, error) {
+	rows, err := s.query(ctx, "get_room_subtitle_files",
+		`SELECT file FROM subtitle_tracks WHERE room_id = ?`, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения субтитров: %w", err)
+	}
+	defer rows.Close()
+	var files []string
+	for rows.Next() {
+		var file string
+		if err := rows.Scan(&file); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования строки: %w", err)
+		}
+		files = append(files, file)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по строкам: %w", err)
+	}
+	rows.Close()
+
+	_, err = s.exec(ctx, "delete_room_subtitles",
+		`DELETE FROM subtitle_tracks WHERE room_id = ?`, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка удаления субтитров: %w", err)
+	}
+	_, err = s.exec(ctx, "reset_room_subtitles",
+		`UPDATE rooms SET subtitle_track_id = NULL, subtitle_offset = 0 WHERE id = ?`, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сброса субтитров комнаты: %w", err)
+	}
+	return files, nil
+}
+
+// SetRoomSubtitles сохраняет выбранную дорожку и сдвиг субтитров комнаты.
+func (db *DB) SetRoomSubtitles(ctx context.Context, roomID int, state SubtitleState) error {
+	result, err := db.exec(ctx, "set_room_subtitles",
+		`UPDATE rooms SET subtitle_track_id = ?, subtitle_offset = ? WHERE id = ?`,
+		state.TrackID, state.Offset, roomID)
+	if err != nil {
+		return fmt.Errorf("ошибка изменения субтитров комнаты: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("ошибка проверки затронутых строк: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("комната с ID %d не найдена", roomID)
+	}
+	return nil
+}
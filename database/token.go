@@ -0,0 +1,20 @@
+package database
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// newToken создаёт случайный секретный токен и его хеш.
+// В базе хранится только хеш, сам токен отдаётся клиенту один раз.
+func newToken() (token, hash string) {
+	token = rand.Text()
+	return token, hashToken(token)
+}
+
+// hashToken возвращает хеш токена для поиска в базе.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
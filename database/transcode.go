@@ -0,0 +1,306 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"room/logging"
+	"time"
+)
+
+// Состояния задания перекодирования.
+const (
+	TranscodeQueued  = "queued"
+	TranscodeRunning = "running"
+	TranscodeFailed  = "failed"
+	TranscodeDone    = "done"
+)
+
+func (db *DB) CreateTranscodeJobsTable(ctx context.Context) error {
+	createTablesSQL := `
+	CREATE TABLE IF NOT EXISTS transcode_jobs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		video TEXT NOT NULL UNIQUE,
+		source TEXT NOT NULL,
+		title TEXT NOT NULL DEFAULT '',
+		source_size INTEGER NOT NULL DEFAULT 0,
+		blob TEXT NULL,
+		room_id INTEGER NULL,
+		status TEXT NOT NULL DEFAULT 'queued',
+		progress REAL NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		created_by INTEGER NOT NULL,
+		created_at DATETIME NOT NULL,
+		started_at DATETIME NULL,
+		finished_at DATETIME NULL
+	);
+	CREATE INDEX IF NOT EXISTS transcode_jobs_status ON transcode_jobs (status, id);
+	`
+	_, err := db.exec(ctx, "create_transcode_jobs_table", createTablesSQL)
+	if err != nil {
+		return fmt.Errorf("ошибка создания таблиц: %w", err)
+	}
+	if err := db.addColumn(ctx, "transcode_jobs", "title", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := db.addColumn(ctx, "transcode_jobs", "source_size", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := db.addColumn(ctx, "transcode_jobs", "blob", "TEXT NULL"); err != nil {
+		return err
+	}
+	db.log(ctx).Info("Таблица 'transcode_jobs' готова")
+	return nil
+}
+
+// TranscodeJob — задание перекодирования загруженного файла source в HLS-видео video.
+type TranscodeJob struct {
+	ID     int    `json:"id"`
+	Video  string `json:"video"`
+	Source string `json:"-"`
+	// Title — название видео в библиотеке, например имя загруженного файла.
+	Title string `json:"title"`
+	// SourceSize — размер исходного файла; он занимает место, пока видео перекодируется.
+	SourceSize int64 `json:"source_size"`
+	// Blob — хеш исходного файла; задания с одним хешем делят результат перекодирования.
+	// Пустой у заданий, созданных до появления блобов.
+	Blob string `json:"-"`
+	// RoomID — комната, из которой загружено видео; ей рассылается ход перекодирования.
+	RoomID *int   `json:"room_id,omitempty"`
+	Status string `json:"status"`
+	// Progress — доля выполненной работы от 0 до 1.
+	Progress   float64    `json:"progress"`
+	Error      string     `json:"error,omitempty"`
+	CreatedBy  int        `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Dir возвращает каталог хранилища с результатом перекодирования.
+func (j *TranscodeJob) Dir() string {
+	if j.Blob == "" {
+		return j.Video
+	}
+	return BlobDir(j.Blob)
+}
+
+// Playable сообщает, можно ли показывать видео задания.
+func (j *TranscodeJob) Playable() bool {
+	return j.Status == TranscodeDone
+}
+
+const transcodeJobColumns = `id, video, source, title, source_size, blob, room_id, status, progress, error, created_by, created_at, started_at, finished_at`
+
+func scanTranscodeJob(row rowScanner) (*TranscodeJob, error) {
+	var job TranscodeJob
+	var roomID sql.NullInt64
+	var blob sql.NullString
+	var startedAt, finishedAt sql.NullTime
+	err := row.Scan(&job.ID, &job.Video, &job.Source, &job.Title, &job.SourceSize, &blob, &roomID, &job.Status, &job.Progress, &job.Error,
+		&job.CreatedBy, &job.CreatedAt, &startedAt, &finishedAt)
+	if err != nil {
+		return nil, err
+	}
+	job.RoomID = nullIntPtr(roomID)
+	job.Blob = blob.String
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	return &job, nil
+}
+
+// CreateTranscodeJob ставит в очередь перекодирование файла job.Source в видео job.Video.
+// job.RoomID — комната, из которой загружен файл, или nil. Задание ссылается на блоб
+// job.Blob, пока не завершится неудачей или не будет удалено.
+func (db *DB) CreateTranscodeJob(ctx context.Context, job TranscodeJob) (*TranscodeJob, error) {
+	job.Status = TranscodeQueued
+	job.CreatedAt = time.Now().UTC()
+
+	err := db.withTx(ctx, func(s session) error {
+		var blob *string
+		if job.Blob != "" {
+			if err := acquireBlob(ctx, s, job.Blob); err != nil {
+				return err
+			}
+			blob = &job.Blob
+		}
+		result, err := s.exec(ctx, "create_transcode_job",
+			`INSERT INTO transcode_jobs (video, source, title, source_size, blob, room_id, status, created_by, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			job.Video, job.Source, job.Title, job.SourceSize, blob, job.RoomID, job.Status, job.CreatedBy, job.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("ошибка добавления задания перекодирования: %w", err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("ошибка получения ID задания перекодирования: %w", err)
+		}
+		job.ID = int(id)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	db.log(ctx).Info("Видео поставлено в очередь перекодирования",
+		"video", job.Video,
+		"job_id", job.ID,
+		logging.KeyUserID, job.CreatedBy,
+	)
+	return &job, nil
+}
+
+// GetTranscodeJob возвращает задание перекодирования видео video.
+func (db *DB) GetTranscodeJob(ctx context.Context, video string) (*TranscodeJob, error) {
+	row := db.queryRow(ctx, "get_transcode_job",
+		`SELECT `+transcodeJobColumns+` FROM transcode_jobs WHERE video = ?`, video)
+	job, err := scanTranscodeJob(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrVideoNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения задания перекодирования: %w", err)
+	}
+	return job, nil
+}
+
+// ClaimTranscodeJob переводит самое старое задание из очереди в работу и возвращает его.
+// Задание ждёт в очереди, пока перекодируется другое задание с тем же блобом.
+// Возвращает nil, если очередь пуста.
+func (db *DB) ClaimTranscodeJob(ctx context.Context) (*TranscodeJob, error) {
+	var job *TranscodeJob
+	err := db.withTx(ctx, func(s session) error {
+		row := s.queryRow(ctx, "next_transcode_job",
+			`SELECT `+transcodeJobColumns+` FROM transcode_jobs j WHERE status = ?
+				AND NOT EXISTS (SELECT 1 FROM transcode_jobs r WHERE r.status = ? AND r.blob = j.blob)
+			ORDER BY id LIMIT 1`,
+			TranscodeQueued, TranscodeRunning)
+		var err error
+		job, err = scanTranscodeJob(row)
+		if errors.Is(err, sql.ErrNoRows) {
+			job = nil
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("ошибка получения задания перекодирования: %w", err)
+		}
+
+		now := time.Now().UTC()
+		_, err = s.exec(ctx, "start_transcode_job",
+			`UPDATE transcode_jobs SET status = ?, progress = 0, started_at = ? WHERE id = ?`,
+			TranscodeRunning, now, job.ID)
+		if err != nil {
+			return fmt.Errorf("ошибка запуска задания перекодирования: %w", err)
+		}
+		job.Status = TranscodeRunning
+		job.Progress = 0
+		job.StartedAt = &now
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// SetTranscodeProgress сохраняет ход выполнения задания.
+func (db *DB) SetTranscodeProgress(ctx context.Context, jobID int, progress float64) error {
+	_, err := db.exec(ctx, "set_transcode_progress",
+		`UPDATE transcode_jobs SET progress = ? WHERE id = ? AND status = ?`,
+		progress, jobID, TranscodeRunning)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения хода перекодирования: %w", err)
+	}
+	return nil
+}
+
+// FinishTranscodeJob завершает задание: успешно, если jobErr пуста, иначе с ошибкой.
+// Неудачное задание освобождает свой блоб; если результат перекодирования больше
+// никому не нужен, возвращается его каталог, чтобы вызывающий удалил файлы.
+func (db *DB) FinishTranscodeJob(ctx context.Context, job *TranscodeJob, jobErr string) (string, error) {
+	now := time.Now().UTC()
+	job.Status = TranscodeDone
+	if jobErr != "" {
+		job.Status = TranscodeFailed
+	} else {
+		job.Progress = 1
+	}
+	job.Error = jobErr
+	job.FinishedAt = &now
+
+	var unused string
+	err := db.withTx(ctx, func(s session) error {
+		_, err := s.exec(ctx, "finish_transcode_job",
+			`UPDATE transcode_jobs SET status = ?, progress = ?, error = ?, finished_at = ? WHERE id = ?`,
+			job.Status, job.Progress, job.Error, now, job.ID)
+		if err != nil {
+			return fmt.Errorf("ошибка завершения задания перекодирования: %w", err)
+		}
+		switch {
+		case job.Status != TranscodeFailed:
+		case job.Blob != "":
+			unused, err = releaseBlob(ctx, s, job.Blob)
+		default:
+			unused = job.Video
+		}
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	db.log(ctx).Info("Перекодирование завершено", "video", job.Video, "status", job.Status)
+	return unused, nil
+}
+
+// deleteTranscodeJob удаляет задание перекодирования загруженного видео video
+// и освобождает его блоб. Возвращает каталог файлов видео, если они больше никому
+// не нужны, иначе пустую строку.
+func deleteTranscodeJob(ctx context.Context, s session, video string) (string, error) {
+	var blob sql.NullString
+	var status string
+	err := s.queryRow(ctx, "delete_transcode_job",
+		`DELETE FROM transcode_jobs WHERE video = ? RETURNING blob, status`, video).Scan(&blob, &status)
+	switch {
+	case errors.Is(err, sql.ErrNoRows), err == nil && !blob.Valid:
+		// Видео, загруженное до появления блобов, хранится под своим именем
+		return video, nil
+	case err != nil:
+		return "", fmt.Errorf("ошибка удаления задания перекодирования: %w", err)
+	case status == TranscodeFailed:
+		// Неудачное задание освободило блоб при завершении
+		return "", nil
+	}
+	return releaseBlob(ctx, s, blob.String)
+}
+
+// RequeueTranscodeJobs возвращает в очередь задания, прерванные остановкой сервиса.
+func (db *DB) RequeueTranscodeJobs(ctx context.Context) (int, error) {
+	result, err := db.exec(ctx, "requeue_transcode_jobs",
+		`UPDATE transcode_jobs SET status = ?, progress = 0, started_at = NULL WHERE status = ?`,
+		TranscodeQueued, TranscodeRunning)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка возврата заданий перекодирования в очередь: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("ошибка проверки затронутых строк: %w", err)
+	}
+	return int(n), nil
+}
+
+// CountRoomVideos возвращает, сколько видео загружено в комнату, не считая неудачных.
+func (db *DB) CountRoomVideos(ctx context.Context, roomID int) (int, error) {
+	var count int
+	err := db.queryRow(ctx, "count_room_videos",
+		`SELECT COUNT(*) FROM transcode_jobs WHERE room_id = ? AND status != ?`, roomID, TranscodeFailed).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка подсчёта видео комнаты: %w", err)
+	}
+	return count, nil
+}
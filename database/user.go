@@ -1,53 +1,126 @@
 package database
 
-import "fmt"
-
-func (db *DB) CreateUsersTable() error {
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"room/logging"
+	"time"
+)
+
+func (db *DB) CreateUsersTable(ctx context.Context) error {
 	createTablesSQL := `
 	CREATE TABLE IF NOT EXISTS users (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL
+		name TEXT NOT NULL,
+		token_hash TEXT NULL,
+		avatar TEXT NULL,
+		preferences TEXT NOT NULL DEFAULT '{}',
+		guest_room_id INTEGER NULL,
+		expires_at DATETIME NULL,
+		max_upload_size INTEGER NULL,
+		storage_quota INTEGER NULL
 	);
 	`
-	_, err := db.conn.Exec(createTablesSQL)
+	_, err := db.exec(ctx, "create_users_table", createTablesSQL)
 	if err != nil {
 		return fmt.Errorf("ошибка создания таблиц: %w", err)
 	}
-	fmt.Println("Таблица 'users' готова.")
+	if err := db.addColumn(ctx, "users", "token_hash", "TEXT NULL"); err != nil {
+		return err
+	}
+	if err := db.addColumn(ctx, "users", "avatar", "TEXT NULL"); err != nil {
+		return err
+	}
+	if err := db.addColumn(ctx, "users", "preferences", "TEXT NOT NULL DEFAULT '{}'"); err != nil {
+		return err
+	}
+	if err := db.addColumn(ctx, "users", "guest_room_id", "INTEGER NULL"); err != nil {
+		return err
+	}
+	if err := db.addColumn(ctx, "users", "expires_at", "DATETIME NULL"); err != nil {
+		return err
+	}
+	if err := db.addColumn(ctx, "users", "max_upload_size", "INTEGER NULL"); err != nil {
+		return err
+	}
+	if err := db.addColumn(ctx, "users", "storage_quota", "INTEGER NULL"); err != nil {
+		return err
+	}
+	_, err = db.exec(ctx, "create_users_indexes",
+		`CREATE UNIQUE INDEX IF NOT EXISTS users_token_hash ON users (token_hash);
+		CREATE INDEX IF NOT EXISTS users_expires_at ON users (expires_at) WHERE expires_at IS NOT NULL`)
+	if err != nil {
+		return fmt.Errorf("ошибка создания индексов: %w", err)
+	}
+	db.log(ctx).Info("Таблица 'users' готова")
 	return nil
 }
 
 type User struct {
 	ID   int    `json:"id"`
 	Name string `json:"name"`
+	// Token — токен доступа; заполняется только при создании пользователя.
+	Token string `json:"token,omitempty"`
+	// Guest — временный гость одной комнаты GuestRoomID до ExpiresAt.
+	Guest       bool       `json:"guest,omitempty"`
+	GuestRoomID int        `json:"-"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// InScope сообщает, может ли пользователь обращаться к комнате.
+// Гостю доступна только его комната.
+func (u *User) InScope(roomID int) bool {
+	return !u.Guest || u.GuestRoomID == roomID
 }
 
-func (db *DB) GetUserByID(id int) (*User, error) {
-	querySQL := `SELECT id, name FROM users WHERE id == ?`
-	row := db.conn.QueryRow(querySQL, id)
+// userColumns — столбцы, которые читает scanUser.
+const userColumns = `id, name, guest_room_id, expires_at`
 
+func scanUser(row rowScanner) (*User, error) {
 	var u User
-	err := row.Scan(&u.ID, &u.Name)
+	var guestRoomID sql.NullInt64
+	var expiresAt sql.NullTime
+	if err := row.Scan(&u.ID, &u.Name, &guestRoomID, &expiresAt); err != nil {
+		return nil, err
+	}
+	if guestRoomID.Valid {
+		u.Guest = true
+		u.GuestRoomID = int(guestRoomID.Int64)
+	}
+	if expiresAt.Valid {
+		u.ExpiresAt = &expiresAt.Time
+	}
+	return &u, nil
+}
+
+func (db *DB) GetUserByID(ctx context.Context, id int) (*User, error) {
+	querySQL := `SELECT ` + userColumns + ` FROM users WHERE id == ?`
+	row := db.queryRow(ctx, "get_user_by_id", querySQL, id)
+
+	u, err := scanUser(row)
 	if err != nil {
 
 		return nil, fmt.Errorf("ошибка получения пользователя по ID: %w", err)
 	}
 
 	// Видео найдено
-	return &u, nil
+	return u, nil
 }
 
 // CreateUser добавляет нового пользователя в базу данных.
 // Возвращает указатель на созданного пользователя и ошибку, если имя уже занято.
-func (db *DB) CreateUser(name string) (*User, error) {
+func (db *DB) CreateUser(ctx context.Context, name string) (*User, error) {
 	// Проверяем, существует ли уже пользователь с таким именем
-	existingUser, _ := db.GetUserByName(name)
+	existingUser, _ := db.GetUserByName(ctx, name)
 	if existingUser != nil {
-		return nil, fmt.Errorf("пользователь с именем '%s' уже существует", name)
+		return nil, fmt.Errorf("%w: '%s'", ErrNameTaken, name)
 	}
 
-	insertSQL := `INSERT INTO users (name) VALUES (?)`
-	result, err := db.conn.Exec(insertSQL, name)
+	token, tokenHash := newToken()
+	insertSQL := `INSERT INTO users (name, token_hash) VALUES (?, ?)`
+	result, err := db.exec(ctx, "create_user", insertSQL, name, tokenHash)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка добавления пользователя: %w", err)
 	}
@@ -58,18 +131,34 @@ func (db *DB) CreateUser(name string) (*User, error) {
 	}
 
 	user := &User{
-		ID:   int(id),
-		Name: name,
+		ID:    int(id),
+		Name:  name,
+		Token: token,
 	}
 
-	fmt.Printf("Пользователь добавлен: ID='%d', Имя='%s'\n", id, name)
+	db.log(ctx).Info("Пользователь добавлен", logging.KeyUserID, id, "name", name)
 	return user, nil
 }
 
+// GetUserByToken получает пользователя по токену доступа.
+// Токены гостей с истёкшим сроком не действуют.
+func (db *DB) GetUserByToken(ctx context.Context, token string) (*User, error) {
+	querySQL := `SELECT ` + userColumns + ` FROM users
+	WHERE token_hash = ? AND (expires_at IS NULL OR expires_at > ?)`
+	row := db.queryRow(ctx, "get_user_by_token", querySQL, hashToken(token), time.Now().UTC())
+
+	u, err := scanUser(row)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения пользователя по токену: %w", err)
+	}
+
+	return u, nil
+}
+
 // GetAllUsers получает всех пользователей из базы данных.
-func (db *DB) GetAllUsers() ([]User, error) {
+func (db *DB) GetAllUsers(ctx context.Context) ([]User, error) {
 	querySQL := `SELECT id, name FROM users`
-	rows, err := db.conn.Query(querySQL)
+	rows, err := db.query(ctx, "get_all_users", querySQL)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка выполнения запроса: %w", err)
 	}
@@ -92,11 +181,12 @@ func (db *DB) GetAllUsers() ([]User, error) {
 	return users, nil
 }
 
-// GetUserByName получает пользователя по его имени.
+// GetUserByName получает зарегистрированного пользователя по его имени.
+// Имена гостей не уникальны и не занимают имён зарегистрированных пользователей.
 // Возвращает nil, если пользователь не найден (без ошибки).
-func (db *DB) GetUserByName(name string) (*User, error) {
-	querySQL := `SELECT id, name FROM users WHERE name = ?`
-	row := db.conn.QueryRow(querySQL, name)
+func (db *DB) GetUserByName(ctx context.Context, name string) (*User, error) {
+	querySQL := `SELECT id, name FROM users WHERE name = ? AND guest_room_id IS NULL`
+	row := db.queryRow(ctx, "get_user_by_name", querySQL, name)
 
 	var u User
 	err := row.Scan(&u.ID, &u.Name)
@@ -108,21 +198,21 @@ func (db *DB) GetUserByName(name string) (*User, error) {
 }
 
 // UpdateUser обновляет имя пользователя по его ID.
-func (db *DB) UpdateUser(id int, newName string) error {
+func (db *DB) UpdateUser(ctx context.Context, id int, newName string) error {
 	// Проверяем, существует ли пользователь
-	_, err := db.GetUserByID(id)
+	_, err := db.GetUserByID(ctx, id)
 	if err != nil {
 		return fmt.Errorf("пользователь с ID %d не найден: %w", id, err)
 	}
 
 	// Проверяем, не существует ли уже пользователя с таким именем
-	existingUser, _ := db.GetUserByName(newName)
+	existingUser, _ := db.GetUserByName(ctx, newName)
 	if existingUser != nil && existingUser.ID != id {
-		return fmt.Errorf("пользователь с именем '%s' уже существует", newName)
+		return fmt.Errorf("%w: '%s'", ErrNameTaken, newName)
 	}
 
 	updateSQL := `UPDATE users SET name = ? WHERE id = ?`
-	result, err := db.conn.Exec(updateSQL, newName, id)
+	result, err := db.exec(ctx, "update_user", updateSQL, newName, id)
 	if err != nil {
 		return fmt.Errorf("ошибка обновления пользователя: %w", err)
 	}
@@ -135,53 +225,168 @@ func (db *DB) UpdateUser(id int, newName string) error {
 		return fmt.Errorf("пользователь с ID %d не найден", id)
 	}
 
-	fmt.Printf("Пользователь с ID %d обновлен: новое имя='%s'\n", id, newName)
+	db.log(ctx).Info("Пользователь обновлён", logging.KeyUserID, id, "name", newName)
 	return nil
 }
 
-// DeleteUser удаляет пользователя по ID.
-// Сначала удаляет пользователя из всех комнат, затем удаляет самого пользователя.
-func (db *DB) DeleteUser(id int) error {
-	// Проверяем, существует ли пользователь
-	_, err := db.GetUserByID(id)
+// DeletedUser — что осталось сделать после удаления пользователя из базы.
+type DeletedUser struct {
+	// Rooms — ключи удалённых комнат пользователя, которые нужно закрыть в хабе.
+	Rooms []string
+	// Files — аватар, субтитры удалённых комнат и файлы удалённых видео,
+	// которые нужно удалить из хранилища.
+	Files []string
+}
+
+// DeleteUser удаляет пользователя по ID в одной транзакции: его комнаты со всеми
+// связанными записями, его участие в чужих комнатах, его видео и его самого.
+// Видео, установленные в комнатах других пользователей, остаются в них без владельца.
+func (db *DB) DeleteUser(ctx context.Context, id int) (*DeletedUser, error) {
+	var deleted DeletedUser
+	err := db.withTx(ctx, func(s session) error {
+		var avatar sql.NullString
+		err := s.queryRow(ctx, "get_user_avatar", `SELECT avatar FROM users WHERE id = ?`, id).Scan(&avatar)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("ошибка получения пользователя: %w", err)
+		}
+		if avatar.Valid && avatar.String != "" {
+			deleted.Files = append(deleted.Files, avatar.String)
+		}
+
+		rooms, err := userRooms(ctx, s, id)
+		if err != nil {
+			return err
+		}
+		files, err := userRoomSubtitles(ctx, s, id)
+		if err != nil {
+			return err
+		}
+		deleted.Files = append(deleted.Files, files...)
+
+		// Комнаты пользователя удаляются так же, как при истечении срока
+		for roomID, key := range rooms {
+			if err := deleteRoom(ctx, s, roomID); err != nil {
+				return err
+			}
+			deleted.Rooms = append(deleted.Rooms, key)
+		}
+
+		// Удаляем пользователя из чужих комнат
+		_, err = s.exec(ctx, "delete_user_memberships", "DELETE FROM users_in_room WHERE user_id = ?", id)
+		if err != nil {
+			return fmt.Errorf("ошибка удаления пользователя из комнат: %w", err)
+		}
+
+		// Видео, которые не установлены ни в одной комнате, удаляются вместе с пользователем
+		videos, err := unusedUserVideos(ctx, s, id)
+		if err != nil {
+			return err
+		}
+		for _, video := range videos {
+			files, err := deleteVideo(ctx, s, &video)
+			if err != nil {
+				return err
+			}
+			deleted.Files = append(deleted.Files, files...)
+		}
+
+		// Остальные остаются без владельца, пока установлены в комнатах других пользователей
+		_, err = s.exec(ctx, "orphan_user_videos", "UPDATE videos SET owner = NULL WHERE owner = ?", id)
+		if err != nil {
+			return fmt.Errorf("ошибка отвязки видео пользователя: %w", err)
+		}
+
+		_, err = s.exec(ctx, "delete_user", "DELETE FROM users WHERE id = ?", id)
+		if err != nil {
+			return fmt.Errorf("ошибка удаления пользователя: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("пользователь с ID %d не найден: %w", id, err)
+		return nil, err
 	}
 
-	// Удаляем пользователя из всех комнат
-	_, err = db.conn.Exec("DELETE FROM users_in_room WHERE user_id = ?", id)
+	db.log(ctx).Info("Пользователь удалён", logging.KeyUserID, id, "rooms", len(deleted.Rooms))
+	return &deleted, nil
+}
+
+// userRooms возвращает ключи комнат владельца userID по их ID.
+func userRooms(ctx context.Context, s session, userID int) (map[int]string, error) {
+	rows, err := s.query(ctx, "get_owner_rooms", `SELECT id, key FROM rooms WHERE owner = ?`, userID)
 	if err != nil {
-		return fmt.Errorf("ошибка удаления пользователя из комнат: %w", err)
+		return nil, fmt.Errorf("ошибка получения комнат владельца: %w", err)
 	}
+	defer rows.Close()
 
-	// Удаляем комнаты, где пользователь является владельцем
-	_, err = db.conn.Exec("DELETE FROM rooms WHERE owner = ?", id)
-	if err != nil {
-		return fmt.Errorf("ошибка удаления комнат владельца: %w", err)
+	rooms := map[int]string{}
+	for rows.Next() {
+		var id int
+		var key string
+		if err := rows.Scan(&id, &key); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования строки: %w", err)
+		}
+		rooms[id] = key
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по строкам: %w", err)
+	}
+	return rooms, nil
+}
 
-	// Удаляем самого пользователя
-	result, err := db.conn.Exec("DELETE FROM users WHERE id = ?", id)
+// unusedUserVideos возвращает видео пользователя userID, не установленные ни в одной комнате.
+func unusedUserVideos(ctx context.Context, s session, userID int) ([]Video, error) {
+	rows, err := s.query(ctx, "get_unused_user_videos",
+		`SELECT `+videoColumns+` FROM videos WHERE owner = ?
+			AND NOT EXISTS (SELECT 1 FROM rooms WHERE rooms.video = videos.name OR rooms.video_id = videos.id)`, userID)
 	if err != nil {
-		return fmt.Errorf("ошибка удаления пользователя: %w", err)
+		return nil, fmt.Errorf("ошибка получения видео пользователя: %w", err)
 	}
+	defer rows.Close()
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("ошибка проверки затронутых строк: %w", err)
+	var videos []Video
+	for rows.Next() {
+		video, err := scanVideo(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования строки: %w", err)
+		}
+		videos = append(videos, *video)
 	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("пользователь с ID %d не найден", id)
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по строкам: %w", err)
 	}
+	return videos, nil
+}
 
-	fmt.Printf("Пользователь с ID %d удален\n", id)
-	return nil
+// userRoomSubtitles возвращает файлы субтитров в комнатах владельца userID.
+func userRoomSubtitles(ctx context.Context, s session, userID int) ([]string, error) {
+	rows, err := s.query(ctx, "get_owner_subtitle_files",
+		`SELECT file FROM subtitle_tracks WHERE room_id IN (SELECT id FROM rooms WHERE owner = ?)`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения субтитров: %w", err)
+	}
+	defer rows.Close()
+
+	var files []string
+	for rows.Next() {
+		var file string
+		if err := rows.Scan(&file); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования строки: %w", err)
+		}
+		files = append(files, file)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по строкам: %w", err)
+	}
+	return files, nil
 }
 
 // UserExists проверяет, существует ли пользователь с указанным ID.
-func (db *DB) UserExists(id int) (bool, error) {
+func (db *DB) UserExists(ctx context.Context, id int) (bool, error) {
 	var count int
-	err := db.conn.QueryRow("SELECT COUNT(*) FROM users WHERE id = ?", id).Scan(&count)
+	err := db.queryRow(ctx, "user_exists", "SELECT COUNT(*) FROM users WHERE id = ?", id).Scan(&count)
 	if err != nil {
 		return false, fmt.Errorf("ошибка проверки существования пользователя: %w", err)
 	}
@@ -189,9 +394,9 @@ func (db *DB) UserExists(id int) (bool, error) {
 }
 
 // IsUserInRoom проверяет, находится ли пользователь в указанной комнате.
-func (db *DB) IsUserInRoom(userID, roomID int) (bool, error) {
+func (db *DB) IsUserInRoom(ctx context.Context, userID, roomID int) (bool, error) {
 	var count int
-	err := db.conn.QueryRow(
+	err := db.queryRow(ctx, "is_user_in_room",
 		"SELECT COUNT(*) FROM users_in_room WHERE user_id = ? AND room_id = ?",
 		userID, roomID,
 	).Scan(&count)
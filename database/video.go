@@ -0,0 +1,405 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"room/logging"
+	"time"
+)
+
+func (db *DB) CreateVideosTable(ctx context.Context) error {
+	createTablesSQL := `
+	CREATE TABLE IF NOT EXISTS videos (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		source TEXT NOT NULL DEFAULT 'upload',
+		owner INTEGER NULL,
+		title TEXT NOT NULL DEFAULT '',
+		size INTEGER NOT NULL DEFAULT 0,
+		blob TEXT NULL,
+		duration REAL NOT NULL DEFAULT 0,
+		container TEXT NOT NULL DEFAULT '',
+		video_codec TEXT NOT NULL DEFAULT '',
+		width INTEGER NOT NULL DEFAULT 0,
+		height INTEGER NOT NULL DEFAULT 0,
+		audio_tracks TEXT NOT NULL DEFAULT '[]',
+		thumbnails INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS videos_owner ON videos (owner, created_at DESC)`
+	_, err := db.exec(ctx, "create_videos_table", createTablesSQL)
+	if err != nil {
+		return fmt.Errorf("ошибка создания таблиц: %w", err)
+	}
+	columns := []struct{ name, definition string }{
+		{"source", "TEXT NOT NULL DEFAULT 'upload'"},
+		{"owner", "INTEGER NULL"},
+		{"title", "TEXT NOT NULL DEFAULT ''"},
+		{"size", "INTEGER NOT NULL DEFAULT 0"},
+		{"blob", "TEXT NULL"},
+		{"thumbnails", "INTEGER NOT NULL DEFAULT 0"},
+	}
+	for _, column := range columns {
+		if err := db.addColumn(ctx, "videos", column.name, column.definition); err != nil {
+			return err
+		}
+	}
+	db.log(ctx).Info("Таблица 'videos' готова")
+	return nil
+}
+
+// Источники видео.
+const (
+	// SourceUpload — загруженный и перекодированный в HLS файл из хранилища.
+	SourceUpload = "upload"
+	// SourceURL — внешний видеофайл по ссылке.
+	SourceURL = "url"
+	// SourceHLS — внешний манифест HLS по ссылке.
+	SourceHLS = "hls"
+)
+
+// AudioTrack — звуковая дорожка исходного файла.
+type AudioTrack struct {
+	// Index — номер потока в файле.
+	Index    int    `json:"index"`
+	Codec    string `json:"codec"`
+	Language string `json:"language,omitempty"`
+	Channels int    `json:"channels"`
+}
+
+// VideoInfo — параметры видео, определённые при загрузке.
+type VideoInfo struct {
+	// Duration — длительность в секундах; 0 — неизвестна.
+	Duration float64 `json:"duration"`
+	// Container — формат исходного файла, например "matroska,webm".
+	Container   string       `json:"container"`
+	VideoCodec  string       `json:"video_codec"`
+	Width       int          `json:"width"`
+	Height      int          `json:"height"`
+	AudioTracks []AudioTrack `json:"audio_tracks"`
+	// Thumbnails сообщает, что у видео есть постер и дорожка миниатюр для перемотки.
+	Thumbnails bool `json:"thumbnails"`
+}
+
+// Video — видео, которое можно установить в комнату, и параметры его исходного файла.
+// Видео принадлежит библиотеке загрузившего его пользователя.
+type Video struct {
+	ID int `json:"id"`
+	// Name — имя каталога видео в хранилище или ссылка на внешнее видео.
+	Name   string `json:"name"`
+	Source string `json:"source"`
+	// Owner — владелец видео; nil, если его аккаунт удалён.
+	Owner *int   `json:"owner,omitempty"`
+	Title string `json:"title"`
+	// Size — сколько байт видео занимает в хранилище.
+	Size int64 `json:"size"`
+	// Blob — хеш исходного файла загруженного видео, файлы которого хранятся в блобе.
+	// Пустой у внешних видео и у видео, загруженных до появления блобов.
+	Blob string `json:"-"`
+	VideoInfo
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Dir возвращает каталог хранилища с файлами HLS загруженного видео.
+func (v *Video) Dir() string {
+	if v.Blob == "" {
+		return v.Name
+	}
+	return BlobDir(v.Blob)
+}
+
+const videoColumns = `id, name, source, owner, title, size, blob,
+	duration, container, video_codec, width, height, audio_tracks, thumbnails, created_at`
+
+func scanVideo(row rowScanner) (*Video, error) {
+	var video Video
+	var owner sql.NullInt64
+	var blob sql.NullString
+	var audioTracks string
+	err := row.Scan(&video.ID, &video.Name, &video.Source, &owner, &video.Title, &video.Size, &blob, &video.Duration, &video.Container, &video.VideoCodec,
+		&video.Width, &video.Height, &audioTracks, &video.Thumbnails, &video.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	video.Owner = nullIntPtr(owner)
+	video.Blob = blob.String
+	if err := json.Unmarshal([]byte(audioTracks), &video.AudioTracks); err != nil {
+		return nil, fmt.Errorf("ошибка чтения звуковых дорожек видео %d: %w", video.ID, err)
+	}
+	return &video, nil
+}
+
+// CreateVideo сохраняет видео. Повторный вызов для того же имени обновляет
+// источник, размер и параметры, но не владельца и название.
+func (db *DB) CreateVideo(ctx context.Context, video Video) (*Video, error) {
+	if video.AudioTracks == nil {
+		video.AudioTracks = []AudioTrack{}
+	}
+	audioTracks, err := json.Marshal(video.AudioTracks)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка кодирования звуковых дорожек: %w", err)
+	}
+
+	var blob *string
+	if video.Blob != "" {
+		blob = &video.Blob
+	}
+	row := db.queryRow(ctx, "create_video",
+		`INSERT INTO videos (name, source, owner, title, size, blob,
+			duration, container, video_codec, width, height, audio_tracks, thumbnails, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET source = excluded.source, size = excluded.size, blob = excluded.blob,
+			duration = excluded.duration, container = excluded.container,
+			video_codec = excluded.video_codec, width = excluded.width, height = excluded.height,
+			audio_tracks = excluded.audio_tracks, thumbnails = excluded.thumbnails
+		RETURNING `+videoColumns,
+		video.Name, video.Source, video.Owner, video.Title, video.Size, blob,
+		video.Duration, video.Container, video.VideoCodec, video.Width, video.Height, string(audioTracks), video.Thumbnails, time.Now().UTC())
+	saved, err := scanVideo(row)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сохранения видео: %w", err)
+	}
+
+	db.log(ctx).Info("Видео сохранено", "video", video.Name, "source", video.Source, "duration", video.Duration)
+	return saved, nil
+}
+
+// GetVideo возвращает видео по ID.
+func (db *DB) GetVideo(ctx context.Context, id int) (*Video, error) {
+	row := db.queryRow(ctx, "get_video", `SELECT `+videoColumns+` FROM videos WHERE id = ?`, id)
+	video, err := scanVideo(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrVideoNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения видео: %w", err)
+	}
+	return video, nil
+}
+
+// GetVideoByName возвращает видео по имени в хранилище.
+func (db *DB) GetVideoByName(ctx context.Context, name string) (*Video, error) {
+	row := db.queryRow(ctx, "get_video_by_name", `SELECT `+videoColumns+` FROM videos WHERE name = ?`, name)
+	video, err := scanVideo(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrVideoNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения видео: %w", err)
+	}
+	return video, nil
+}
+
+// GetRoomVideo возвращает видео name, которое пользователь userID может установить
+// в комнату roomID: из его библиотеки, загруженное в эту комнату или уже установленное в ней.
+func (db *DB) GetRoomVideo(ctx context.Context, roomID, userID int, name string) (*Video, error) {
+	row := db.queryRow(ctx, "get_room_video",
+		`SELECT `+videoColumns+` FROM videos WHERE name = ? AND (owner = ?
+			OR name IN (SELECT video FROM transcode_jobs WHERE room_id = ?)
+			OR name IN (SELECT video FROM rooms WHERE id = ?))`,
+		name, userID, roomID, roomID)
+	video, err := scanVideo(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrVideoNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения видео: %w", err)
+	}
+	return video, nil
+}
+
+// DeleteVideo удаляет внешнее видео и ссылки на него из комнат.
+func (db *DB) DeleteVideo(ctx context.Context, name string) error {
+	return db.withTx(ctx, func(s session) error {
+		_, err := s.exec(ctx, "unset_room_video_id",
+			`UPDATE rooms SET video_id = NULL WHERE video_id IN (SELECT id FROM videos WHERE name = ?)`, name)
+		if err != nil {
+			return fmt.Errorf("ошибка удаления ссылок на видео: %w", err)
+		}
+		_, err = s.exec(ctx, "delete_video", `DELETE FROM videos WHERE name = ?`, name)
+		if err != nil {
+			return fmt.Errorf("ошибка удаления видео: %w", err)
+		}
+		return nil
+	})
+}
+
+// DeleteUploadedVideo удаляет загруженное видео, его задание перекодирования и ссылки
+// на него из комнат. Возвращает каталог файлов видео, если они больше не нужны другим
+// видео с тем же содержимым, иначе пустую строку.
+func (db *DB) DeleteUploadedVideo(ctx context.Context, name string) (string, error) {
+	var unused string
+	err := db.withTx(ctx, func(s session) error {
+		_, err := s.exec(ctx, "unset_room_video_id",
+			`UPDATE rooms SET video_id = NULL WHERE video_id IN (SELECT id FROM videos WHERE name = ?)`, name)
+		if err != nil {
+			return fmt.Errorf("ошибка удаления ссылок на видео: %w", err)
+		}
+		if unused, err = deleteTranscodeJob(ctx, s, name); err != nil {
+			return err
+		}
+		_, err = s.exec(ctx, "delete_video", `DELETE FROM videos WHERE name = ?`, name)
+		if err != nil {
+			return fmt.Errorf("ошибка удаления видео: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return unused, nil
+}
+
+// GetUserVideos возвращает библиотеку видео пользователя, новые первыми.
+func (db *DB) GetUserVideos(ctx context.Context, userID int) ([]Video, error) {
+	rows, err := db.query(ctx, "get_user_videos",
+		`SELECT `+videoColumns+` FROM videos WHERE owner = ? ORDER BY created_at DESC, id DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения видео пользователя: %w", err)
+	}
+	defer rows.Close()
+
+	videos := []Video{}
+	for rows.Next() {
+		video, err := scanVideo(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования строки: %w", err)
+		}
+		videos = append(videos, *video)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по строкам: %w", err)
+	}
+	return videos, nil
+}
+
+// GetUserVideoUsage возвращает, сколько байт хранилища занимают видео пользователя,
+// включая исходные файлы, которые ещё перекодируются.
+func (db *DB) GetUserVideoUsage(ctx context.Context, userID int) (int64, error) {
+	var used int64
+	err := db.queryRow(ctx, "get_user_video_usage",
+		`SELECT (SELECT COALESCE(SUM(size), 0) FROM videos WHERE owner = ?)
+			+ (SELECT COALESCE(SUM(source_size), 0) FROM transcode_jobs WHERE created_by = ? AND status IN (?, ?))`,
+		userID, userID, TranscodeQueued, TranscodeRunning).Scan(&used)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка подсчёта места под видео: %w", err)
+	}
+	return used, nil
+}
+
+// GetUserVideo возвращает видео из библиотеки пользователя.
+func (db *DB) GetUserVideo(ctx context.Context, userID, id int) (*Video, error) {
+	row := db.queryRow(ctx, "get_user_video",
+		`SELECT `+videoColumns+` FROM videos WHERE id = ? AND owner = ?`, id, userID)
+	video, err := scanVideo(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrVideoNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения видео: %w", err)
+	}
+	return video, nil
+}
+
+// RenameVideo меняет название видео из библиотеки пользователя.
+func (db *DB) RenameVideo(ctx context.Context, userID, id int, title string) error {
+	result, err := db.exec(ctx, "rename_video",
+		`UPDATE videos SET title = ? WHERE id = ? AND owner = ?`, title, id, userID)
+	if err != nil {
+		return fmt.Errorf("ошибка переименования видео: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("ошибка проверки затронутых строк: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrVideoNotFound
+	}
+	return nil
+}
+
+// DeleteUserVideo удаляет видео из библиотеки пользователя вместе с его субтитрами
+// и заданием перекодирования. Видео, установленное в комнатах, не удаляется: возвращается
+// ErrVideoInUse. Возвращает удалённое видео и файлы, которые больше не нужны: субтитры
+// и каталог видео, если на его блоб не осталось ссылок, — чтобы вызывающий удалил их.
+func (db *DB) DeleteUserVideo(ctx context.Context, userID, id int) (*Video, []string, error) {
+	var video *Video
+	var files []string
+	err := db.withTx(ctx, func(s session) error {
+		row := s.queryRow(ctx, "get_user_video",
+			`SELECT `+videoColumns+` FROM videos WHERE id = ? AND owner = ?`, id, userID)
+		var err error
+		video, err = scanVideo(row)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrVideoNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("ошибка получения видео: %w", err)
+		}
+
+		var rooms int
+		err = s.queryRow(ctx, "video_in_use",
+			`SELECT COUNT(*) FROM rooms WHERE video = ? OR video_id = ?`, video.Name, video.ID).Scan(&rooms)
+		if err != nil {
+			return fmt.Errorf("ошибка проверки использования видео: %w", err)
+		}
+		if rooms > 0 {
+			return ErrVideoInUse
+		}
+
+		files, err = deleteVideo(ctx, s, video)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	db.log(ctx).Info("Видео удалено из библиотеки", "video", video.Name, logging.KeyUserID, userID)
+	return video, files, nil
+}
+
+// deleteVideo удаляет видео вместе с его субтитрами и заданием перекодирования.
+// Ссылки на видео из комнат должны быть уже сняты. Возвращает файлы, которые больше
+// не нужны: субтитры и каталог видео, если на его блоб не осталось ссылок.
+func deleteVideo(ctx context.Context, s session, video *Video) ([]string, error) {
+	rows, err := s.query(ctx, "get_video_subtitle_files",
+		`SELECT file FROM subtitle_tracks WHERE video = ?`, video.Name)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения субтитров: %w", err)
+	}
+	defer rows.Close()
+	var files []string
+	for rows.Next() {
+		var file string
+		if err := rows.Scan(&file); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования строки: %w", err)
+		}
+		files = append(files, file)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по строкам: %w", err)
+	}
+	rows.Close()
+
+	if video.Source == SourceUpload {
+		unused, err := deleteTranscodeJob(ctx, s, video.Name)
+		if err != nil {
+			return nil, err
+		}
+		if unused != "" {
+			files = append(files, unused)
+		}
+	}
+	for _, stmt := range []struct{ name, query string }{
+		{"delete_video_subtitles", `DELETE FROM subtitle_tracks WHERE video = ?`},
+		{"delete_video", `DELETE FROM videos WHERE name = ?`},
+	} {
+		if _, err := s.exec(ctx, stmt.name, stmt.query, video.Name); err != nil {
+			return nil, fmt.Errorf("ошибка удаления видео: %w", err)
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,61 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"room/audit"
+	"room/database"
+	"room/handlers/room"
+	"room/logging"
+	"strings"
+)
+
+// maxAnnouncementLength — наибольшая длина объявления в байтах.
+const maxAnnouncementLength = 1000
+
+// announceRequest — тело запроса на рассылку объявления.
+type announceRequest struct {
+	Message string `json:"message"`
+}
+
+// announceResponse — результат рассылки объявления.
+type announceResponse struct {
+	Status string `json:"status"`
+	Rooms  int    `json:"rooms"`
+}
+
+// Announce рассылает объявление, например о предстоящем обслуживании,
+// всем клиентам открытых комнат сообщением announcement.
+func Announce(db *database.DB, hub *room.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+
+		var req announceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Error("Некорректное тело запроса", logging.KeyError, err)
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		message := strings.TrimSpace(req.Message)
+		if message == "" {
+			http.Error(w, "message must not be empty", http.StatusBadRequest)
+			return
+		}
+		if len(message) > maxAnnouncementLength {
+			http.Error(w, "message is too long", http.StatusBadRequest)
+			return
+		}
+
+		rooms := hub.Announce(message)
+		audit.Record(r.Context(), db, audit.Event{
+			Action:  audit.ActionAnnouncement,
+			Payload: map[string]any{"message": message, "rooms": rooms, "admin": true},
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(announceResponse{Status: "success", Rooms: rooms}); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
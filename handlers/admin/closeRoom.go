@@ -0,0 +1,69 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"room/audit"
+	"room/database"
+	"room/handlers/room"
+	"room/logging"
+)
+
+// closeRoomRequest — необязательное тело запроса на закрытие комнаты.
+type closeRoomRequest struct {
+	// Reason — причина, которую клиенты получат в кадре закрытия.
+	Reason string `json:"reason"`
+}
+
+// closeRoomResponse — результат закрытия комнаты.
+type closeRoomResponse struct {
+	Status       string `json:"status"`
+	Disconnected int    `json:"disconnected"`
+}
+
+// CloseRoom принудительно закрывает комнату key в хабе, отключая всех клиентов.
+// Комната в базе остаётся, и клиенты могут переподключиться.
+func CloseRoom(db *database.DB, hub *room.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+
+		key := r.URL.Query().Get("key")
+		if key == "" {
+			log.Error("Отсутствует обязательный параметр: key")
+			http.Error(w, "Missing required parameter: key", http.StatusBadRequest)
+			return
+		}
+
+		var req closeRoomRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			log.Error("Некорректное тело запроса", logging.KeyError, err)
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		dbRoom, err := db.GetRoomByKey(r.Context(), key)
+		if err != nil {
+			log.Warn("Не удалось найти комнату", logging.KeyRoomKey, key, logging.KeyError, err)
+			http.Error(w, "Room not found", http.StatusNotFound)
+			return
+		}
+		disconnected, ok := hub.CloseRoom(key, req.Reason)
+		if !ok {
+			http.Error(w, "Room is not open", http.StatusNotFound)
+			return
+		}
+		audit.Record(r.Context(), db, audit.Event{
+			Action:  audit.ActionRoomClosed,
+			RoomID:  dbRoom.ID,
+			Payload: map[string]any{"reason": req.Reason, "disconnected": disconnected, "admin": true},
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(closeRoomResponse{Status: "success", Disconnected: disconnected}); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package admin
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"room/audit"
+	"room/database"
+	"room/handlers/room"
+	"room/logging"
+	"room/storage"
+	"strconv"
+)
+
+// deleteUserResponse — результат удаления пользователя.
+type deleteUserResponse struct {
+	Status string `json:"status"`
+	ID     int    `json:"id"`
+}
+
+// DeleteUser удаляет пользователя id вместе с его комнатами и аватаром
+// и закрывает его комнаты в хабе.
+// Видео пользователя остаются в чужих комнатах, где они установлены, остальные удаляются.
+func DeleteUser(db *database.DB, store storage.Storage, hub *room.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+
+		id, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil || id <= 0 {
+			http.Error(w, "Invalid id parameter", http.StatusBadRequest)
+			return
+		}
+
+		profile, err := db.GetProfile(r.Context(), id)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Error("Не удалось получить профиль", logging.KeyUserID, id, logging.KeyError, err)
+			http.Error(w, "Failed to delete user", http.StatusInternalServerError)
+			return
+		}
+
+		deleted, err := db.DeleteUser(r.Context(), id)
+		if errors.Is(err, database.ErrUserNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Error("Не удалось удалить пользователя", logging.KeyUserID, id, logging.KeyError, err)
+			http.Error(w, "Failed to delete user", http.StatusInternalServerError)
+			return
+		}
+		hub.CloseDeletedRooms(deleted.Rooms)
+		for _, file := range deleted.Files {
+			if err := store.Delete(r.Context(), file); err != nil {
+				log.Warn("Не удалось удалить файл пользователя", "file", file, logging.KeyError, err)
+			}
+		}
+		audit.Record(r.Context(), db, audit.Event{
+			Action:  audit.ActionUserDeleted,
+			Payload: map[string]any{"user_id": id, "name": profile.Name, "admin": true},
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(deleteUserResponse{Status: "success", ID: id}); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"room/audit"
+	"room/database"
+	"room/logging"
+	"strconv"
+)
+
+// GetAudit возвращает журнал аудита всего сервиса, начиная с последних событий.
+// Комната задаётся ключом key или, если она уже удалена, параметром room_id.
+// Остальные фильтры описаны в audit.ParseFilter.
+func GetAudit(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+		query := r.URL.Query()
+
+		filter, err := audit.ParseFilter(query)
+		if err != nil {
+			log.Warn("Некорректные параметры журнала аудита", logging.KeyError, err)
+			http.Error(w, "Invalid filter parameters", http.StatusBadRequest)
+			return
+		}
+		if s := query.Get("room_id"); s != "" {
+			id, err := strconv.Atoi(s)
+			if err != nil || id <= 0 {
+				http.Error(w, "Invalid room_id parameter", http.StatusBadRequest)
+				return
+			}
+			filter.RoomID = id
+		}
+		if key := query.Get("key"); key != "" {
+			room, err := db.GetRoomByKey(r.Context(), key)
+			if err != nil {
+				log.Warn("Не удалось найти комнату", logging.KeyRoomKey, key, logging.KeyError, err)
+				http.Error(w, "Room not found", http.StatusNotFound)
+				return
+			}
+			filter.RoomID = room.ID
+		}
+
+		events, err := db.ListAuditEvents(r.Context(), filter)
+		if err != nil {
+			log.Error("Не удалось получить журнал аудита", logging.KeyError, err)
+			http.Error(w, "Failed to get audit log", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(audit.NewPage(events, filter)); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"room/database"
+	"room/handlers/room"
+	"room/logging"
+)
+
+// getRoomResponse — комната из базы и её состояние в хабе.
+type getRoomResponse struct {
+	Room *database.Room `json:"room"`
+	// Live — состояние комнаты в хабе; nil, если она не открыта.
+	Live *room.RoomInfo `json:"live"`
+}
+
+// GetRoom возвращает комнату key с участниками и, если она открыта, подключённых клиентов.
+func GetRoom(db *database.DB, hub *room.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+
+		key := r.URL.Query().Get("key")
+		if key == "" {
+			log.Error("Отсутствует обязательный параметр: key")
+			http.Error(w, "Missing required parameter: key", http.StatusBadRequest)
+			return
+		}
+
+		dbRoom, err := db.GetRoomByKey(r.Context(), key)
+		if err != nil {
+			log.Warn("Не удалось найти комнату", logging.KeyRoomKey, key, logging.KeyError, err)
+			http.Error(w, "Room not found", http.StatusNotFound)
+			return
+		}
+		response := getRoomResponse{Room: dbRoom}
+		if info, ok := hub.RoomSnapshot(key); ok {
+			response.Live = &info
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"room/database"
+	"room/logging"
+	"strconv"
+)
+
+// Сколько пользователей с самыми большими библиотеками показывать.
+const (
+	defaultTopUsers = 10
+	maxTopUsers     = 100
+)
+
+// GetStorageUsage возвращает, сколько места занимают блобы, видео, ожидающие перекодирования
+// загрузки и субтитры, и пользователей с самыми большими библиотеками. Параметр top — их число.
+func GetStorageUsage(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+
+		top := defaultTopUsers
+		if s := r.URL.Query().Get("top"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 {
+				http.Error(w, "Invalid top parameter", http.StatusBadRequest)
+				return
+			}
+			top = min(n, maxTopUsers)
+		}
+
+		usage, err := db.GetStorageUsage(r.Context(), top)
+		if err != nil {
+			log.Error("Не удалось подсчитать место в хранилище", logging.KeyError, err)
+			http.Error(w, "Failed to get storage usage", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(usage); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
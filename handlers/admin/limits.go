@@ -0,0 +1,111 @@
+// Package admin содержит административные обработчики, доступные по токену администратора.
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"room/audit"
+	"room/database"
+	"room/logging"
+	"strconv"
+)
+
+// SetUserLimits назначает пользователю id собственные ограничения загрузки вместо
+// ограничений из настроек. Поле со значением null возвращает значение по умолчанию.
+func SetUserLimits(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+
+		id, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil || id <= 0 {
+			http.Error(w, "Invalid id parameter", http.StatusBadRequest)
+			return
+		}
+
+		var limits database.UserLimits
+		if err := json.NewDecoder(r.Body).Decode(&limits); err != nil {
+			log.Error("Некорректное тело запроса", logging.KeyError, err)
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if limits.MaxFileSize != nil && *limits.MaxFileSize <= 0 {
+			http.Error(w, "max_file_size must be positive", http.StatusBadRequest)
+			return
+		}
+		if limits.StorageQuota != nil && *limits.StorageQuota < 0 {
+			http.Error(w, "storage_quota must not be negative", http.StatusBadRequest)
+			return
+		}
+
+		err = db.SetUserLimits(r.Context(), id, limits)
+		if errors.Is(err, database.ErrUserNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Error("Не удалось изменить ограничения пользователя", logging.KeyUserID, id, logging.KeyError, err)
+			http.Error(w, "Failed to update limits", http.StatusInternalServerError)
+			return
+		}
+		audit.Record(r.Context(), db, audit.Event{
+			Action:  audit.ActionLimitsChanged,
+			Payload: map[string]any{"user_id": id, "limits": limits, "admin": true},
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(limits); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
+
+// SetRoomLimits назначает комнате key собственные ограничения вместо ограничений
+// из настроек. Поле со значением null возвращает значение по умолчанию.
+func SetRoomLimits(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+
+		key := r.URL.Query().Get("key")
+		if key == "" {
+			log.Error("Отсутствует обязательный параметр: key")
+			http.Error(w, "Missing required parameter: key", http.StatusBadRequest)
+			return
+		}
+
+		var limits database.RoomLimits
+		if err := json.NewDecoder(r.Body).Decode(&limits); err != nil {
+			log.Error("Некорректное тело запроса", logging.KeyError, err)
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if limits.MaxVideos != nil && *limits.MaxVideos < 0 {
+			http.Error(w, "max_videos must not be negative", http.StatusBadRequest)
+			return
+		}
+
+		room, err := db.GetRoomByKey(r.Context(), key)
+		if err != nil {
+			log.Warn("Не удалось найти комнату", logging.KeyRoomKey, key, logging.KeyError, err)
+			http.Error(w, "Room not found", http.StatusNotFound)
+			return
+		}
+		if err := db.SetRoomLimits(r.Context(), room.ID, limits); err != nil {
+			log.Error("Не удалось изменить ограничения комнаты", logging.KeyRoomKey, key, logging.KeyError, err)
+			http.Error(w, "Failed to update limits", http.StatusInternalServerError)
+			return
+		}
+		audit.Record(r.Context(), db, audit.Event{
+			Action:  audit.ActionLimitsChanged,
+			RoomID:  room.ID,
+			Payload: map[string]any{"limits": limits, "admin": true},
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(limits); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
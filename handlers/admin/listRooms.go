@@ -0,0 +1,81 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"room/database"
+	"room/handlers/room"
+	"room/logging"
+	"strconv"
+)
+
+// Параметры постраничной выдачи списка комнат.
+const (
+	defaultRoomsLimit = 50
+	maxRoomsLimit     = 500
+)
+
+// adminRoom — комната из базы вместе с её состоянием в хабе.
+type adminRoom struct {
+	database.Room
+	// Live сообщает, открыта ли комната в хабе.
+	Live bool `json:"live"`
+	// Clients — число подключённых клиентов.
+	Clients int `json:"clients"`
+}
+
+// listRoomsResponse — страница списка комнат. NextAfter равен 0 на последней странице.
+type listRoomsResponse struct {
+	Rooms     []adminRoom `json:"rooms"`
+	NextAfter int         `json:"next_after,omitempty"`
+}
+
+// ListRooms возвращает все комнаты из базы, включая приватные и архивные, по возрастанию ID
+// с числом подключённых к ним клиентов. Параметры: after — ID, после которого начать, и limit.
+func ListRooms(db *database.DB, hub *room.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+		query := r.URL.Query()
+
+		after := 0
+		if s := query.Get("after"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 {
+				http.Error(w, "Invalid after parameter", http.StatusBadRequest)
+				return
+			}
+			after = n
+		}
+		limit := defaultRoomsLimit
+		if s := query.Get("limit"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n <= 0 {
+				http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+				return
+			}
+			limit = min(n, maxRoomsLimit)
+		}
+
+		rooms, err := db.ListRooms(r.Context(), after, limit)
+		if err != nil {
+			log.Error("Не удалось получить список комнат", logging.KeyError, err)
+			http.Error(w, "Failed to list rooms", http.StatusInternalServerError)
+			return
+		}
+
+		response := listRoomsResponse{Rooms: make([]adminRoom, 0, len(rooms))}
+		for _, dbRoom := range rooms {
+			info, live := hub.RoomSnapshot(dbRoom.Key)
+			response.Rooms = append(response.Rooms, adminRoom{Room: dbRoom, Live: live, Clients: info.ClientCount})
+		}
+		if len(rooms) == limit {
+			response.NextAfter = rooms[len(rooms)-1].ID
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"room/audit"
+	"room/database"
+	"room/logging"
+)
+
+// purgeMembershipsResponse — результат очистки записей об участии.
+type purgeMembershipsResponse struct {
+	Status string `json:"status"`
+	Purged int64  `json:"purged"`
+}
+
+// PurgeMemberships удаляет записи users_in_room, оставшиеся от удалённых комнат и пользователей.
+func PurgeMemberships(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+
+		purged, err := db.PurgeOrphanMemberships(r.Context())
+		if err != nil {
+			log.Error("Не удалось очистить записи об участии", logging.KeyError, err)
+			http.Error(w, "Failed to purge memberships", http.StatusInternalServerError)
+			return
+		}
+		audit.Record(r.Context(), db, audit.Event{
+			Action:  audit.ActionMembershipsPurged,
+			Payload: map[string]any{"purged": purged, "admin": true},
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(purgeMembershipsResponse{Status: "success", Purged: purged}); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
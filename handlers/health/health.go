@@ -0,0 +1,84 @@
+// Package health содержит обработчики проверки состояния сервиса.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"room/database"
+	"room/handlers/room"
+	"room/logging"
+	"room/storage"
+	"time"
+)
+
+// readyTimeout — сколько ждать каждую проверку готовности.
+const readyTimeout = 2 * time.Second
+
+var errHubClosed = errors.New("хаб не принимает подключения")
+
+// Healthz сообщает, что процесс жив.
+func Healthz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("ok"))
+	}
+}
+
+// readyResponse — результат проверки готовности.
+type readyResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// Readyz проверяет базу данных, хаб и хранилище.
+// Отвечает 503, если хотя бы одна проверка не прошла.
+func Readyz(db *database.DB, hub *room.Hub, store storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+
+		checks := map[string]func(ctx context.Context) error{
+			"database": db.Ping,
+			"hub": func(context.Context) error {
+				if !hub.Accepting() {
+					return errHubClosed
+				}
+				return nil
+			},
+			"storage": store.Ping,
+		}
+
+		response := readyResponse{Status: "ok", Checks: make(map[string]string, len(checks))}
+		for name, check := range checks {
+			ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+			err := check(ctx)
+			cancel()
+			if err != nil {
+				log.Warn("Проверка готовности не пройдена", "check", name, logging.KeyError, err)
+				response.Status = "unavailable"
+				response.Checks[name] = err.Error()
+				continue
+			}
+			response.Checks[name] = "ok"
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if response.Status != "ok" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+		}
+	}
+}
+
+// DebugHub выводит состояние всех комнат хаба.
+func DebugHub(hub *room.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(hub.Snapshot()); err != nil {
+			logging.FromContext(r.Context()).Error("Не удалось закодировать ответ", logging.KeyError, err)
+		}
+	}
+}
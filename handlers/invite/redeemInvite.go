@@ -0,0 +1,104 @@
+package invite
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"room/audit"
+	"room/auth"
+	"room/database"
+	"room/logging"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// redeemInviteResponse — структура для ответа при успешном использовании приглашения
+type redeemInviteResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	RoomKey string `json:"room_key"`
+	Role    string `json:"role"`
+	// User — созданный гость с токеном, если приглашение использовано без входа
+	User *database.User `json:"user,omitempty"`
+}
+
+// RedeemInvite добавляет текущего пользователя в комнату по токену приглашения
+// из пути /invite/{token} и возвращает ключ комнаты. Без входа создаётся гость
+// комнаты со сроком guestTTL, и его токен возвращается в ответе.
+func RedeemInvite(db *database.DB, guestTTL time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+
+		token := chi.URLParam(r, "token")
+		var (
+			room  *database.Room
+			role  string
+			guest *database.User
+			// changed — пользователь вступил в комнату или получил новую роль
+			changed bool
+			err     error
+		)
+		userID := 0
+		if user, ok := auth.UserFromContext(r.Context()); ok {
+			userID = user.ID
+			room, role, changed, err = db.RedeemInvite(r.Context(), token, user.ID)
+		} else {
+			room, guest, role, err = db.RedeemInviteAsGuest(r.Context(), token, guestTTL)
+			changed = err == nil
+		}
+		switch {
+		case errors.Is(err, database.ErrInviteNotFound):
+			http.Error(w, "Invite not found", http.StatusNotFound)
+			return
+		case errors.Is(err, database.ErrInviteRevoked):
+			http.Error(w, "Invite revoked", http.StatusGone)
+			return
+		case errors.Is(err, database.ErrInviteExpired):
+			http.Error(w, "Invite expired", http.StatusGone)
+			return
+		case errors.Is(err, database.ErrInviteExhausted):
+			http.Error(w, "Invite usage limit reached", http.StatusGone)
+			return
+		case errors.Is(err, database.ErrBanned):
+			http.Error(w, "Banned from this room", http.StatusForbidden)
+			return
+		case errors.Is(err, database.ErrGuestScope):
+			http.Error(w, "Guests cannot join other rooms", http.StatusForbidden)
+			return
+		case err != nil:
+			log.Error("Не удалось использовать приглашение", logging.KeyError, err)
+			http.Error(w, "Failed to redeem invite", http.StatusInternalServerError)
+			return
+		}
+
+		if guest != nil {
+			userID = guest.ID
+		}
+		// Повторный переход по ссылке без изменения роли в журнал не попадает
+		if changed {
+			audit.Record(r.Context(), db, audit.Event{
+				Action:  audit.ActionRoleGranted,
+				ActorID: userID,
+				RoomID:  room.ID,
+				Payload: map[string]any{"user_id": userID, "role": role, "guest": guest != nil},
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if guest != nil {
+			w.WriteHeader(http.StatusCreated)
+		}
+		response := redeemInviteResponse{
+			Status:  "success",
+			Message: "Invite redeemed successfully",
+			RoomKey: room.Key,
+			Role:    role,
+			User:    guest,
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
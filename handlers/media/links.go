@@ -0,0 +1,44 @@
+package media
+
+import (
+	"room/signing"
+	"room/transcode"
+	"time"
+)
+
+// Links — подписанные ссылки на файлы перекодированного видео.
+type Links struct {
+	Playlist   string    `json:"playlist"`
+	Poster     string    `json:"poster,omitempty"`
+	Thumbnails string    `json:"thumbnails,omitempty"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// Linker выдаёт подписанные ссылки, которые принимает ServeVideo.
+type Linker struct {
+	signer    *signing.Signer
+	publicURL string
+}
+
+// NewLinker создаёт выдачу ссылок на адрес сервиса publicURL.
+func NewLinker(signer *signing.Signer, publicURL string) *Linker {
+	return &Linker{signer: signer, publicURL: publicURL}
+}
+
+// Links подписывает ссылки на файлы видео video для пользователя userID в комнате roomID;
+// roomID 0 — для библиотеки пользователя. Ссылки на превью выдаются, если thumbnails.
+// Подпись стоит в пути, поэтому относительные ссылки из плейлистов и дорожки миниатюр
+// наследуют её.
+func (l *Linker) Links(video string, thumbnails bool, roomID, userID int) Links {
+	token, claims := l.signer.Sign(video, roomID, userID)
+	mediaURL := l.publicURL + "/media/" + token
+	links := Links{
+		Playlist:  transcode.PlaylistURL(mediaURL, video),
+		ExpiresAt: claims.ExpiresAt,
+	}
+	if thumbnails {
+		links.Poster = transcode.PosterURL(mediaURL, video)
+		links.Thumbnails = transcode.ThumbnailsURL(mediaURL, video)
+	}
+	return links
+}
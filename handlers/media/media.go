@@ -0,0 +1,98 @@
+// Package media отдаёт перекодированные видео из хранилища по подписанным ссылкам.
+package media
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"path/filepath"
+	"room/database"
+	"room/logging"
+	"room/signing"
+	"room/storage"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// videoPrefix — каталог хранилища с перекодированными видео.
+const videoPrefix = "videos/"
+
+// contentTypes — типы файлов HLS и дорожки миниатюр, которые не знает mime.
+var contentTypes = map[string]string{
+	".m3u8": "application/vnd.apple.mpegurl",
+	".ts":   "video/mp2t",
+	".vtt":  "text/vtt; charset=utf-8",
+}
+
+// ServeVideo отдаёт плейлисты и сегменты HLS, постер и миниатюры перемотки
+// по пути /media/<подпись>/videos/<видео>/<файл>, выданному Linker.
+// Ссылка перестаёт действовать по истечении срока, а ссылка из комнаты — ещё и после
+// того, как пользователя выгнали или забанили. Ссылка на библиотеку действует только
+// для владельца видео.
+// Файлы загруженного видео ищутся в его блобе, поэтому видео с одинаковым содержимым
+// отдаются из одного каталога. Другие файлы хранилища через этот обработчик недоступны.
+func ServeVideo(db *database.DB, store storage.Storage, signer *signing.Signer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+
+		token, name, _ := strings.Cut(chi.URLParam(r, "*"), "/")
+		if !strings.HasPrefix(name, videoPrefix) || !filepath.IsLocal(name) {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+
+		id, file, _ := strings.Cut(strings.TrimPrefix(name, videoPrefix), "/")
+		dir := videoPrefix + id
+		claims, err := signer.Verify(token, dir)
+		if err != nil {
+			log.Debug("Ссылка на видео не принята", "video", dir, logging.KeyError, err)
+			http.Error(w, "Invalid or expired link", http.StatusForbidden)
+			return
+		}
+		if claims.RoomID != 0 {
+			revokedAt, err := db.MediaRevokedAt(r.Context(), claims.RoomID, claims.UserID)
+			if err != nil {
+				log.Error("Не удалось проверить отзыв ссылки", logging.KeyError, err)
+				http.Error(w, "Failed to check link", http.StatusInternalServerError)
+				return
+			}
+			if !revokedAt.IsZero() && !claims.IssuedAt.After(revokedAt) {
+				http.Error(w, "Invalid or expired link", http.StatusForbidden)
+				return
+			}
+		}
+
+		video, err := db.GetVideoByName(r.Context(), dir)
+		switch {
+		case err == nil:
+			dir = video.Dir()
+		case !errors.Is(err, database.ErrVideoNotFound):
+			log.Error("Не удалось получить видео", "video", dir, logging.KeyError, err)
+			http.Error(w, "Failed to get video", http.StatusInternalServerError)
+			return
+		}
+		if claims.RoomID == 0 && (video == nil || video.Owner == nil || *video.Owner != claims.UserID) {
+			http.Error(w, "Invalid or expired link", http.StatusForbidden)
+			return
+		}
+
+		content, err := store.Open(r.Context(), path.Join(dir, file))
+		if err != nil {
+			log.Debug("Файл видео не найден", "name", name, logging.KeyError, err)
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		defer content.Close()
+
+		if contentType, ok := contentTypes[path.Ext(name)]; ok {
+			w.Header().Set("Content-Type", contentType)
+		}
+		// Готовое видео не меняется, но хранить его можно только пока действует ссылка
+		maxAge := int(time.Until(claims.ExpiresAt).Seconds())
+		w.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(max(maxAge, 0)))
+		http.ServeContent(w, r, path.Base(name), time.Time{}, content)
+	}
+}
@@ -0,0 +1,156 @@
+package media
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"room/database"
+	"room/signing"
+	"room/storage"
+	"room/transcode"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	testVideo = "videos/abc"
+	testRoom  = 7
+	owner     = 1
+	viewer    = 2
+)
+
+// newTestServer создаёт сервер с ServeVideo, базой и хранилищем во временном каталоге
+// и сохраняет в нём загруженное видео testVideo пользователя owner.
+func newTestServer(t *testing.T) (*httptest.Server, *database.DB, *Linker) {
+	t.Helper()
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	db, err := database.New(filepath.Join(dir, "test.db"), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("database.New: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.CreateTable(ctx); err != nil {
+		t.Fatalf("CreateTable: %v", err)
+	}
+	store, err := storage.NewLocal(filepath.Join(dir, "media"))
+	if err != nil {
+		t.Fatalf("storage.NewLocal: %v", err)
+	}
+	if _, err := store.Save(ctx, testVideo+"/"+transcode.MasterPlaylist, strings.NewReader("#EXTM3U\n")); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	ownerID := owner
+	if _, err := db.CreateVideo(ctx, database.Video{Name: testVideo, Source: database.SourceUpload, Owner: &ownerID}); err != nil {
+		t.Fatalf("CreateVideo: %v", err)
+	}
+
+	signer, err := signing.New([]signing.Key{{ID: "test", Secret: []byte("0123456789abcdef")}}, time.Hour)
+	if err != nil {
+		t.Fatalf("signing.New: %v", err)
+	}
+	router := chi.NewRouter()
+	router.Get("/media/*", ServeVideo(db, store, signer))
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+	return srv, db, NewLinker(signer, srv.URL)
+}
+
+// get запрашивает url и возвращает код ответа.
+func get(t *testing.T, url string) int {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode
+}
+
+func TestServeVideo(t *testing.T) {
+	srv, _, linker := newTestServer(t)
+	links := linker.Links(testVideo, false, testRoom, viewer)
+
+	resp, err := http.Get(links.Playlist)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "#EXTM3U\n" {
+		t.Fatalf("плейлист: код %d, тело %q", resp.StatusCode, body)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "application/vnd.apple.mpegurl" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got := resp.Header.Get("Cache-Control"); !strings.HasPrefix(got, "private, max-age=") {
+		t.Errorf("Cache-Control = %q", got)
+	}
+
+	token := strings.TrimPrefix(links.Playlist, srv.URL+"/media/")
+	token, _, _ = strings.Cut(token, "/")
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{"нет файла", "/media/" + token + "/" + testVideo + "/missing.ts", http.StatusNotFound},
+		{"чужое видео", "/media/" + token + "/videos/other/" + transcode.MasterPlaylist, http.StatusForbidden},
+		{"не каталог видео", "/media/" + token + "/uploads/abc", http.StatusNotFound},
+		{"выход из каталога", "/media/" + token + "/videos/../uploads/abc", http.StatusForbidden},
+		{"изменённая подпись", "/media/" + token + "x/" + testVideo + "/" + transcode.MasterPlaylist, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if got := get(t, srv.URL+tt.path); got != tt.want {
+			t.Errorf("%s: код %d, ожидалось %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestServeVideoRevoked(t *testing.T) {
+	_, db, linker := newTestServer(t)
+	kicked := linker.Links(testVideo, false, testRoom, viewer)
+	other := linker.Links(testVideo, false, testRoom, owner)
+	if got := get(t, kicked.Playlist); got != http.StatusOK {
+		t.Fatalf("ссылка до отзыва: код %d", got)
+	}
+
+	// Так ссылки отзываются, когда пользователя выгоняют или банят
+	if err := db.RevokeMedia(context.Background(), testRoom, viewer); err != nil {
+		t.Fatalf("RevokeMedia: %v", err)
+	}
+	if got := get(t, kicked.Playlist); got != http.StatusForbidden {
+		t.Errorf("старая ссылка выгнанного пользователя: код %d, ожидалось %d", got, http.StatusForbidden)
+	}
+	if got := get(t, other.Playlist); got != http.StatusOK {
+		t.Errorf("ссылка другого участника: код %d, ожидалось %d", got, http.StatusOK)
+	}
+	if got := get(t, linker.Links(testVideo, false, testRoom+1, viewer).Playlist); got != http.StatusOK {
+		t.Errorf("ссылка в другой комнате: код %d, ожидалось %d", got, http.StatusOK)
+	}
+
+	// Ссылка, выданная после отзыва, например после повторного входа, действует
+	time.Sleep(2 * time.Millisecond)
+	if got := get(t, linker.Links(testVideo, false, testRoom, viewer).Playlist); got != http.StatusOK {
+		t.Errorf("новая ссылка: код %d, ожидалось %d", got, http.StatusOK)
+	}
+}
+
+func TestServeVideoLibrary(t *testing.T) {
+	_, _, linker := newTestServer(t)
+	// Ссылка на библиотеку без комнаты действует только для владельца видео
+	if got := get(t, linker.Links(testVideo, false, 0, owner).Playlist); got != http.StatusOK {
+		t.Errorf("ссылка владельца: код %d, ожидалось %d", got, http.StatusOK)
+	}
+	if got := get(t, linker.Links(testVideo, false, 0, viewer).Playlist); got != http.StatusForbidden {
+		t.Errorf("ссылка на чужую библиотеку: код %d, ожидалось %d", got, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,120 @@
+package room
+
+import (
+	"errors"
+	"net/http"
+	"room/auth"
+	"room/database"
+	"room/logging"
+	"slices"
+)
+
+// roomWithRole загружает комнату из параметра key и проверяет, что текущий
+// пользователь состоит в ней с одной из ролей roles. При ошибке пишет ответ сам.
+func roomWithRole(w http.ResponseWriter, r *http.Request, db *database.DB, roles ...string) (*database.Room, *database.User, bool) {
+	log := logging.FromContext(r.Context())
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		log.Error("Отсутствует обязательный параметр: key")
+		http.Error(w, "Missing required parameter: key", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	room, err := db.GetRoomByKey(r.Context(), key)
+	if err != nil {
+		log.Error("Не удалось найти комнату", logging.KeyRoomKey, key, logging.KeyError, err)
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	if !user.InScope(room.ID) {
+		log.Warn("Гость обращается к чужой комнате", logging.KeyRoomKey, key)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return nil, nil, false
+	}
+
+	role, err := db.GetUserRole(r.Context(), user.ID, room.ID)
+	if err != nil && !errors.Is(err, database.ErrNotMember) {
+		log.Error("Не удалось получить роль пользователя", logging.KeyRoomKey, key, logging.KeyError, err)
+		http.Error(w, "Failed to check access", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	if !slices.Contains(roles, role) {
+		log.Warn("Недостаточно прав в комнате", logging.KeyRoomKey, key, "role", role)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return nil, nil, false
+	}
+
+	return room, user, true
+}
+
+// currentUserID возвращает ID текущего пользователя или 0 для анонимного запроса.
+func currentUserID(r *http.Request) int {
+	if user, ok := auth.UserFromContext(r.Context()); ok {
+		return user.ID
+	}
+	return 0
+}
+
+// roomPassword возвращает пароль комнаты из заголовка X-Room-Password или параметра password.
+// Параметр нужен для WebSocket: браузер не может передать заголовки при подключении.
+func roomPassword(r *http.Request) string {
+	if password := r.Header.Get("X-Room-Password"); password != "" {
+		return password
+	}
+	return r.URL.Query().Get("password")
+}
+
+// checkEntry проверяет, можно ли показать комнату или подключиться к ней.
+// Участникам комната доступна всегда; приватная комната — только им,
+// комната с паролем — при верном пароле. Гостю доступна только его комната.
+// Возвращает код ответа при отказе.
+func checkEntry(r *http.Request, db *database.DB, room *database.Room) (int, bool) {
+	if user, ok := auth.UserFromContext(r.Context()); ok {
+		if !user.InScope(room.ID) {
+			return http.StatusForbidden, false
+		}
+		_, err := db.GetUserRole(r.Context(), user.ID, room.ID)
+		if err == nil {
+			return 0, true
+		}
+		if !errors.Is(err, database.ErrNotMember) {
+			logging.FromContext(r.Context()).Error("Не удалось проверить участие в комнате", logging.KeyError, err)
+			return http.StatusInternalServerError, false
+		}
+	}
+
+	if room.Visibility == database.VisibilityPrivate {
+		// Не раскрываем существование приватной комнаты
+		return http.StatusNotFound, false
+	}
+	if !room.CheckPassword(roomPassword(r)) {
+		return http.StatusUnauthorized, false
+	}
+	return 0, true
+}
+
+// denyEntry пишет ответ об отказе в доступе к комнате.
+func denyEntry(w http.ResponseWriter, r *http.Request, room *database.Room, status int) {
+	logging.FromContext(r.Context()).Warn("Отказано в доступе к комнате",
+		logging.KeyRoomKey, room.Key,
+		logging.KeyStatus, status,
+	)
+	switch status {
+	case http.StatusNotFound:
+		http.Error(w, "Room not found", status)
+	case http.StatusUnauthorized:
+		http.Error(w, "Room password required", status)
+	case http.StatusForbidden:
+		http.Error(w, "Forbidden", status)
+	default:
+		http.Error(w, "Failed to check access", status)
+	}
+}
@@ -0,0 +1,60 @@
+package room
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"room/audit"
+	"room/database"
+	"room/logging"
+	"strconv"
+)
+
+// AttachVideo устанавливает в комнату key видео id из библиотеки текущего пользователя,
+// не загружая его заново. Доступно владельцу и модераторам комнаты.
+func AttachVideo(hub *Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+
+		id, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil || id <= 0 {
+			http.Error(w, "Invalid id parameter", http.StatusBadRequest)
+			return
+		}
+
+		room, user, ok := roomWithRole(w, r, hub.db, database.RoleOwner, database.RoleModerator)
+		if !ok {
+			return
+		}
+
+		video, err := hub.db.GetUserVideo(r.Context(), user.ID, id)
+		if errors.Is(err, database.ErrVideoNotFound) {
+			http.Error(w, "Video not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Error("Не удалось получить видео", "video_id", id, logging.KeyError, err)
+			http.Error(w, "Failed to get video", http.StatusInternalServerError)
+			return
+		}
+
+		if err := hub.db.SetRoomVideo(r.Context(), room.ID, video.Name); err != nil {
+			log.Error("Не удалось установить видео для комнаты", logging.KeyRoomKey, room.Key, logging.KeyError, err)
+			http.Error(w, "Failed to set video", http.StatusInternalServerError)
+			return
+		}
+		hub.UpdateVideo(room.Key, video)
+		audit.Record(r.Context(), hub.db, audit.Event{
+			Action:  audit.ActionVideoChanged,
+			ActorID: user.ID,
+			RoomID:  room.ID,
+			Payload: map[string]any{"video": video.Name, "source": video.Source},
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(video); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package room
+
+import (
+	"net/http"
+)
+
+// Ban запрещает пользователю или IP-адресу входить в комнату и отключает их.
+// Доступно владельцу и модераторам. Тело запроса — ModerationAction
+// с user_id или ip, длительностью в секундах (0 — бессрочно) и причиной.
+func Ban(hub *Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		room, who, action, ok := moderationRequest(w, r, hub)
+		if !ok {
+			return
+		}
+
+		sanction, err := hub.ban(r.Context(), room, who, action)
+		if err != nil {
+			moderationError(w, r, err)
+			return
+		}
+
+		writeModeration(w, r, http.StatusCreated, "Ban created successfully", sanction)
+	}
+}
@@ -0,0 +1,84 @@
+package room
+
+import (
+	"encoding/json"
+	"net/http"
+	"room/auth"
+	"room/database"
+	"room/logging"
+	"time"
+)
+
+// createGuestResponse — структура для ответа при успешном создании гостя
+type createGuestResponse struct {
+	Status  string        `json:"status"`
+	Message string        `json:"message"`
+	User    database.User `json:"user"`
+	RoomKey string        `json:"room_key"`
+}
+
+// CreateGuest создаёт временного гостя комнаты из параметра key, чтобы
+// зритель по ссылке мог подключиться без регистрации. Гость действует ttl
+// и видит только эту комнату. Доступ проверяется как при входе в комнату.
+func CreateGuest(db *database.DB, ttl time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+
+		if _, ok := auth.UserFromContext(r.Context()); ok {
+			http.Error(w, "Already signed in", http.StatusConflict)
+			return
+		}
+
+		key := r.URL.Query().Get("key")
+		if key == "" {
+			log.Error("Отсутствует обязательный параметр: key")
+			http.Error(w, "Missing required parameter: key", http.StatusBadRequest)
+			return
+		}
+
+		room, err := db.GetRoomByKey(r.Context(), key)
+		if err != nil {
+			log.Warn("Не удалось найти комнату", logging.KeyRoomKey, key, logging.KeyError, err)
+			http.Error(w, "Room not found", http.StatusNotFound)
+			return
+		}
+		if status, ok := checkEntry(r, db, room); !ok {
+			denyEntry(w, r, room, status)
+			return
+		}
+		if room.ArchivedAt != nil {
+			http.Error(w, "Room archived", http.StatusGone)
+			return
+		}
+		ban, err := db.FindActiveBan(r.Context(), room.ID, 0, clientIP(r))
+		if err != nil {
+			log.Error("Не удалось проверить бан", logging.KeyError, err)
+			http.Error(w, "Failed to check access", http.StatusInternalServerError)
+			return
+		}
+		if ban != nil {
+			http.Error(w, "Banned from this room", http.StatusForbidden)
+			return
+		}
+
+		guest, err := db.CreateGuest(r.Context(), room.ID, ttl)
+		if err != nil {
+			log.Error("Не удалось создать гостя", logging.KeyRoomKey, key, logging.KeyError, err)
+			http.Error(w, "Guest not created", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		response := createGuestResponse{
+			Status:  "success",
+			Message: "Guest created successfully",
+			User:    *guest,
+			RoomKey: room.Key,
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
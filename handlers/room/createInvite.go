@@ -0,0 +1,106 @@
+package room
+
+import (
+	"encoding/json"
+	"net/http"
+	"room/database"
+	"room/logging"
+	"strings"
+	"time"
+)
+
+// createInviteRequest — параметры приглашения.
+type createInviteRequest struct {
+	// Role — роль, которую получит пользователь; по умолчанию member.
+	Role string `json:"role"`
+	// ExpiresIn — срок действия в секундах. Не задан — срок по умолчанию, 0 — бессрочно.
+	ExpiresIn *int64 `json:"expires_in"`
+	// MaxUses — сколько раз можно использовать приглашение; 0 — без ограничений.
+	MaxUses int `json:"max_uses"`
+}
+
+// createInviteResponse — структура для ответа при успешном создании приглашения
+type createInviteResponse struct {
+	Status  string          `json:"status"`
+	Message string          `json:"message"`
+	Invite  database.Invite `json:"invite"`
+	URL     string          `json:"url"`
+}
+
+// CreateInvite создаёт приглашение в комнату. Доступно владельцу и модераторам;
+// приглашение с ролью moderator может создать только владелец.
+// publicURL — внешний адрес сервиса для ссылки, defaultTTL — срок действия по умолчанию.
+func CreateInvite(db *database.DB, publicURL string, defaultTTL time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+
+		room, user, ok := roomWithRole(w, r, db, database.RoleOwner, database.RoleModerator)
+		if !ok {
+			return
+		}
+
+		var req createInviteRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				log.Error("Некорректное тело запроса", logging.KeyError, err)
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+		}
+		if req.Role == "" {
+			req.Role = database.RoleMember
+		}
+		if !database.ValidRole(req.Role) || req.Role == database.RoleOwner {
+			http.Error(w, "Invalid role", http.StatusBadRequest)
+			return
+		}
+		// Раздавать права модератора может только владелец
+		if req.Role == database.RoleModerator {
+			role, err := db.GetUserRole(r.Context(), user.ID, room.ID)
+			if err != nil {
+				log.Error("Не удалось получить роль пользователя", logging.KeyRoomKey, room.Key, logging.KeyError, err)
+				http.Error(w, "Failed to check access", http.StatusInternalServerError)
+				return
+			}
+			if role != database.RoleOwner {
+				log.Warn("Модератор пытается создать приглашение модератора", logging.KeyRoomKey, room.Key)
+				http.Error(w, "Only the owner can invite moderators", http.StatusForbidden)
+				return
+			}
+		}
+		if req.MaxUses < 0 || (req.ExpiresIn != nil && *req.ExpiresIn < 0) {
+			http.Error(w, "Invalid expiry or usage limit", http.StatusBadRequest)
+			return
+		}
+
+		ttl := defaultTTL
+		if req.ExpiresIn != nil {
+			ttl = time.Duration(*req.ExpiresIn) * time.Second
+		}
+		var expiresAt *time.Time
+		if ttl > 0 {
+			t := time.Now().UTC().Add(ttl)
+			expiresAt = &t
+		}
+
+		invite, err := db.CreateInvite(r.Context(), room.ID, user.ID, req.Role, expiresAt, req.MaxUses)
+		if err != nil {
+			log.Error("Не удалось создать приглашение", logging.KeyRoomKey, room.Key, logging.KeyError, err)
+			http.Error(w, "Invite not created", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		response := createInviteResponse{
+			Status:  "success",
+			Message: "Invite created successfully",
+			Invite:  *invite,
+			URL:     strings.TrimRight(publicURL, "/") + "/invite/" + invite.Token,
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
@@ -2,9 +2,11 @@ package room
 
 import (
 	"encoding/json"
-	"log/slog"
 	"net/http"
+	"room/audit"
+	"room/auth"
 	"room/database"
+	"room/logging"
 )
 
 // createRoomResponse — структура для ответа при успешном удалении
@@ -16,21 +18,34 @@ type createRoomResponse struct {
 
 func CreateRoom(database *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		room, err := database.CreateRoom()
+		log := logging.FromContext(r.Context())
+
+		// Авторизованный пользователь становится владельцем комнаты
+		ownerID := 0
+		if user, ok := auth.UserFromContext(r.Context()); ok {
+			// Гость ограничен своей комнатой и не может заводить новые
+			if user.Guest {
+				http.Error(w, "Guests cannot create rooms", http.StatusForbidden)
+				return
+			}
+			ownerID = user.ID
+		}
+
+		room, err := database.CreateRoom(r.Context(), ownerID)
 		if err != nil {
-			slog.Error("Не удалось создать комнату",
-				"error", err,
-				"удалённый_адрес", r.RemoteAddr,
-				"метод", r.Method,
-				"путь", r.URL.Path,
-			)
+			log.Error("Не удалось создать комнату", logging.KeyError, err)
 			http.Error(w, "Room not created", http.StatusInternalServerError)
 			return
 		}
+		audit.Record(r.Context(), database, audit.Event{
+			Action:  audit.ActionRoomCreated,
+			ActorID: ownerID,
+			RoomID:  room.ID,
+			Payload: map[string]any{"key": room.Key},
+		})
 
 		// Устанавливаем тип содержимого
 		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("Access-Control-Allow-Origin", "*")
 		response := createRoomResponse{
 			Status:  "success",
 			Message: "Room created successfully",
@@ -39,12 +54,7 @@ func CreateRoom(database *database.DB) http.HandlerFunc {
 
 		err = json.NewEncoder(w).Encode(response)
 		if err != nil {
-			slog.Error("Ошибка при отправке ответа с ключом комнаты",
-				"error", err,
-				"удалённый_адрес", r.RemoteAddr,
-				"метод", r.Method,
-				"путь", r.URL.Path,
-			)
+			log.Error("Ошибка при отправке ответа с ключом комнаты", logging.KeyError, err)
 			return
 		}
 	}
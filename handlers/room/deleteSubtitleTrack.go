@@ -0,0 +1,68 @@
+package room
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"room/database"
+	"room/logging"
+	"room/storage"
+	"strconv"
+)
+
+// deleteSubtitleTrackResponse — структура для ответа при успешном удалении субтитров
+type deleteSubtitleTrackResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	ID      int    `json:"id"`
+}
+
+// DeleteSubtitleTrack удаляет дорожку субтитров комнаты. Если дорожка была выбрана,
+// субтитры в комнате выключаются. Доступно владельцу и модераторам.
+func DeleteSubtitleTrack(hub *Hub, store storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+
+		room, _, ok := roomWithRole(w, r, hub.db, database.RoleOwner, database.RoleModerator)
+		if !ok {
+			return
+		}
+
+		id, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil || id <= 0 {
+			log.Error("Некорректное значение параметра id", "value", r.URL.Query().Get("id"))
+			http.Error(w, "Invalid id parameter", http.StatusBadRequest)
+			return
+		}
+
+		track, err := hub.db.DeleteSubtitleTrack(r.Context(), room.ID, id)
+		if errors.Is(err, database.ErrSubtitleNotFound) {
+			http.Error(w, "Subtitles not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Error("Не удалось удалить субтитры", "track_id", id, logging.KeyError, err)
+			http.Error(w, "Failed to delete subtitles", http.StatusInternalServerError)
+			return
+		}
+		if err := store.Delete(r.Context(), track.File); err != nil {
+			log.Warn("Не удалось удалить файл субтитров", "file", track.File, logging.KeyError, err)
+		}
+		if selected := room.Subtitles.TrackID; selected != nil && *selected == id {
+			state := room.Subtitles
+			state.TrackID = nil
+			hub.UpdateSubtitles(room.Key, state)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		response := deleteSubtitleTrackResponse{
+			Status:  "success",
+			Message: "Subtitles deleted successfully",
+			ID:      id,
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package room
+
+import (
+	"encoding/json"
+	"net/http"
+	"room/audit"
+	"room/database"
+	"room/logging"
+)
+
+// GetAudit возвращает журнал действий в комнате key, начиная с последних: кто
+// запускал, останавливал и перематывал видео, менял его, выгонял и банил участников.
+// Доступно владельцу и модераторам. Фильтры описаны в audit.ParseFilter.
+func GetAudit(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+
+		room, _, ok := roomWithRole(w, r, db, database.RoleOwner, database.RoleModerator)
+		if !ok {
+			return
+		}
+
+		filter, err := audit.ParseFilter(r.URL.Query())
+		if err != nil {
+			log.Warn("Некорректные параметры журнала аудита", logging.KeyError, err)
+			http.Error(w, "Invalid filter parameters", http.StatusBadRequest)
+			return
+		}
+		filter.RoomID = room.ID
+
+		events, err := db.ListAuditEvents(r.Context(), filter)
+		if err != nil {
+			log.Error("Не удалось получить журнал аудита", logging.KeyRoomKey, room.Key, logging.KeyError, err)
+			http.Error(w, "Failed to get audit log", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(audit.NewPage(events, filter)); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
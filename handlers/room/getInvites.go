@@ -0,0 +1,36 @@
+package room
+
+import (
+	"encoding/json"
+	"net/http"
+	"room/database"
+	"room/logging"
+)
+
+// GetInvites возвращает приглашения комнаты. Доступно владельцу и модераторам.
+func GetInvites(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+
+		room, _, ok := roomWithRole(w, r, db, database.RoleOwner, database.RoleModerator)
+		if !ok {
+			return
+		}
+
+		invites, err := db.GetRoomInvites(r.Context(), room.ID)
+		if err != nil {
+			log.Error("Не удалось получить приглашения", logging.KeyRoomKey, room.Key, logging.KeyError, err)
+			http.Error(w, "Failed to get invites", http.StatusInternalServerError)
+			return
+		}
+		if invites == nil {
+			invites = []database.Invite{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(invites); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
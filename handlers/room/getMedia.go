@@ -0,0 +1,65 @@
+package room
+
+import (
+	"encoding/json"
+	"net/http"
+	"room/database"
+	"room/logging"
+)
+
+// GetMedia выдаёт текущему пользователю подписанные ссылки на загруженное видео комнаты key.
+// Ссылки действуют ограниченное время и перестают действовать, если пользователя
+// выгонят или забанят; новые ссылки приходят и в сообщениях sync и change-video.
+func GetMedia(hub *Hub) http.HandlerFunc {
+	db := hub.db
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+
+		key := r.URL.Query().Get("key")
+		if key == "" {
+			log.Error("Отсутствует обязательный параметр: key")
+			http.Error(w, "Missing required parameter: key", http.StatusBadRequest)
+			return
+		}
+
+		room, err := db.GetRoomByKey(r.Context(), key)
+		if err != nil {
+			log.Warn("Не удалось найти комнату", logging.KeyRoomKey, key, logging.KeyError, err)
+			http.Error(w, "Room not found", http.StatusNotFound)
+			return
+		}
+		if status, ok := checkEntry(r, db, room); !ok {
+			denyEntry(w, r, room, status)
+			return
+		}
+		userID := currentUserID(r)
+		ban, err := db.FindActiveBan(r.Context(), room.ID, userID, clientIP(r))
+		if err != nil {
+			log.Error("Не удалось проверить бан", logging.KeyError, err)
+			http.Error(w, "Failed to check access", http.StatusInternalServerError)
+			return
+		}
+		if ban != nil {
+			http.Error(w, "Banned from this room", http.StatusForbidden)
+			return
+		}
+
+		video := room.VideoInfo
+		if video == nil && room.Video.Valid && room.Video.String != "" {
+			// Видео, загруженное до появления таблицы videos
+			video = &database.Video{Name: room.Video.String, Source: database.SourceUpload}
+		}
+		links := hub.mediaLinks(video, room.ID, userID)
+		if links == nil {
+			http.Error(w, "Room has no uploaded video", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(links); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
@@ -2,48 +2,42 @@ package room
 
 import (
 	"encoding/json"
-	"fmt"
-	"log/slog"
 	"net/http"
 	"room/database"
+	"room/logging"
 )
 
 func GetRoom(database *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+
 		key := r.URL.Query().Get("key")
 		if key == "" {
-			slog.Error("Отсутствует обязательный параметр: key",
-				"удалённый_адрес", r.RemoteAddr,
-				"метод", r.Method,
-				"путь", r.URL.Path,
-			)
+			log.Error("Отсутствует обязательный параметр: key")
 			http.Error(w, "Missing required parameter: key", http.StatusBadRequest)
 			return
 		}
 
-		room, err := database.GetRoomByKey(key)
+		room, err := database.GetRoomByKey(r.Context(), key)
 		if err != nil {
-			slog.Error(fmt.Sprintf("Не удалось найти комнату с Key: %s", key),
-				"удалённый_адрес", r.RemoteAddr,
-				"метод", r.Method,
-				"путь", r.URL.Path,
-				"ошибка", err.Error(),
+			log.Error("Не удалось найти комнату",
+				logging.KeyRoomKey, key,
+				logging.KeyError, err,
 			)
 			http.Error(w, "Room not found", http.StatusNotFound)
 			return
 		}
+		if status, ok := checkEntry(r, database, room); !ok {
+			denyEntry(w, r, room, status)
+			return
+		}
 
 		// Устанавливаем тип содержимого
 		w.Header().Set("Content-Type", "application/json")
 
 		err = json.NewEncoder(w).Encode(room)
 		if err != nil {
-			slog.Error("Ошибка при отправке ответа с ключом комнаты",
-				"error", err,
-				"удалённый_адрес", r.RemoteAddr,
-				"метод", r.Method,
-				"путь", r.URL.Path,
-			)
+			log.Error("Ошибка при отправке ответа с ключом комнаты", logging.KeyError, err)
 			// Нельзя вызвать http.Error после начала записи в w
 			return
 		}
@@ -0,0 +1,36 @@
+package room
+
+import (
+	"encoding/json"
+	"net/http"
+	"room/database"
+	"room/logging"
+)
+
+// GetSanctions возвращает действующие баны и мьюты комнаты. Доступно владельцу и модераторам.
+func GetSanctions(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+
+		room, _, ok := roomWithRole(w, r, db, database.RoleOwner, database.RoleModerator)
+		if !ok {
+			return
+		}
+
+		sanctions, err := db.GetActiveSanctions(r.Context(), room.ID)
+		if err != nil {
+			log.Error("Не удалось получить санкции", logging.KeyRoomKey, room.Key, logging.KeyError, err)
+			http.Error(w, "Failed to get sanctions", http.StatusInternalServerError)
+			return
+		}
+		if sanctions == nil {
+			sanctions = []database.Sanction{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(sanctions); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package room
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"room/database"
+	"room/logging"
+	"room/storage"
+	"strconv"
+)
+
+// GetSubtitleTrack отдаёт файл WebVTT дорожки субтитров id комнаты key.
+func GetSubtitleTrack(db *database.DB, store storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+
+		key := r.URL.Query().Get("key")
+		if key == "" {
+			log.Error("Отсутствует обязательный параметр: key")
+			http.Error(w, "Missing required parameter: key", http.StatusBadRequest)
+			return
+		}
+		id, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil || id <= 0 {
+			http.Error(w, "Invalid id parameter", http.StatusBadRequest)
+			return
+		}
+
+		room, err := db.GetRoomByKey(r.Context(), key)
+		if err != nil {
+			log.Warn("Не удалось найти комнату", logging.KeyRoomKey, key, logging.KeyError, err)
+			http.Error(w, "Room not found", http.StatusNotFound)
+			return
+		}
+		if status, ok := checkEntry(r, db, room); !ok {
+			denyEntry(w, r, room, status)
+			return
+		}
+
+		track, err := db.GetSubtitleTrack(r.Context(), room.ID, id)
+		if errors.Is(err, database.ErrSubtitleNotFound) {
+			http.Error(w, "Subtitles not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Error("Не удалось получить субтитры", "track_id", id, logging.KeyError, err)
+			http.Error(w, "Failed to get subtitles", http.StatusInternalServerError)
+			return
+		}
+
+		file, err := store.Open(r.Context(), track.File)
+		if err != nil {
+			log.Error("Не удалось открыть субтитры", "track_id", id, logging.KeyError, err)
+			http.Error(w, "Subtitles not found", http.StatusNotFound)
+			return
+		}
+		defer file.Close()
+
+		// Файл дорожки не меняется, но комната может быть закрытой
+		w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
+		w.Header().Set("Cache-Control", "private, max-age=86400")
+		http.ServeContent(w, r, path.Base(track.File), track.CreatedAt, file)
+	}
+}
@@ -0,0 +1,61 @@
+package room
+
+import (
+	"encoding/json"
+	"net/http"
+	"room/database"
+	"room/logging"
+)
+
+// getSubtitlesResponse — дорожки субтитров текущего видео и их состояние в комнате.
+type getSubtitlesResponse struct {
+	Tracks   []subtitleTrackResponse `json:"tracks"`
+	Selected database.SubtitleState  `json:"selected"`
+}
+
+// GetSubtitles возвращает дорожки субтитров текущего видео комнаты.
+func GetSubtitles(db *database.DB, publicURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+
+		key := r.URL.Query().Get("key")
+		if key == "" {
+			log.Error("Отсутствует обязательный параметр: key")
+			http.Error(w, "Missing required parameter: key", http.StatusBadRequest)
+			return
+		}
+
+		room, err := db.GetRoomByKey(r.Context(), key)
+		if err != nil {
+			log.Warn("Не удалось найти комнату", logging.KeyRoomKey, key, logging.KeyError, err)
+			http.Error(w, "Room not found", http.StatusNotFound)
+			return
+		}
+		if status, ok := checkEntry(r, db, room); !ok {
+			denyEntry(w, r, room, status)
+			return
+		}
+
+		response := getSubtitlesResponse{
+			Tracks:   []subtitleTrackResponse{},
+			Selected: room.Subtitles,
+		}
+		if room.Video.Valid && room.Video.String != "" {
+			tracks, err := db.GetSubtitleTracks(r.Context(), room.ID, room.Video.String)
+			if err != nil {
+				log.Error("Не удалось получить субтитры", logging.KeyRoomKey, key, logging.KeyError, err)
+				http.Error(w, "Failed to get subtitles", http.StatusInternalServerError)
+				return
+			}
+			for _, track := range tracks {
+				response.Tracks = append(response.Tracks, newSubtitleTrackResponse(track, room.Key, publicURL))
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
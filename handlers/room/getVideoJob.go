@@ -0,0 +1,54 @@
+package room
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"room/database"
+	"room/handlers/media"
+	"room/logging"
+)
+
+// GetVideoJob возвращает состояние перекодирования видео из параметра video,
+// загруженного в комнату key.
+func GetVideoJob(db *database.DB, linker *media.Linker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+
+		key := r.URL.Query().Get("key")
+		video := r.URL.Query().Get("video")
+		if key == "" || video == "" {
+			log.Error("Отсутствует обязательный параметр: key или video")
+			http.Error(w, "Missing required parameters: key, video", http.StatusBadRequest)
+			return
+		}
+
+		room, err := db.GetRoomByKey(r.Context(), key)
+		if err != nil {
+			log.Warn("Не удалось найти комнату", logging.KeyRoomKey, key, logging.KeyError, err)
+			http.Error(w, "Room not found", http.StatusNotFound)
+			return
+		}
+		if status, ok := checkEntry(r, db, room); !ok {
+			denyEntry(w, r, room, status)
+			return
+		}
+
+		job, err := db.GetTranscodeJob(r.Context(), video)
+		if errors.Is(err, database.ErrVideoNotFound) || (err == nil && (job.RoomID == nil || *job.RoomID != room.ID)) {
+			http.Error(w, "Video not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Error("Не удалось получить задание перекодирования", "video", video, logging.KeyError, err)
+			http.Error(w, "Failed to get video", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(newVideoJobResponse(*job, linker, currentUserID(r))); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package room
+
+import (
+	"room/logging"
+	"room/metrics"
+	"time"
+)
+
+// Коды закрытия WebSocket для клиентов комнаты, закрытой администратором или удалённой.
+const (
+	closeAdmin   = 4010
+	closeDeleted = 4011
+)
+
+// RoomSnapshot возвращает состояние открытой комнаты key или false, если она не открыта.
+func (h *Hub) RoomSnapshot(key string) (RoomInfo, bool) {
+	h.mx.RLock()
+	room := h.Rooms[key]
+	h.mx.RUnlock()
+	if room == nil || room.ctx.Err() != nil {
+		return RoomInfo{}, false
+	}
+	return room.info(), true
+}
+
+// CloseRoom отключает всех клиентов комнаты key с причиной reason и закрывает её в хабе.
+// Возвращает число отключённых клиентов или false, если комната не открыта.
+// Клиенты могут переподключиться: комната в базе не меняется.
+func (h *Hub) CloseRoom(key, reason string) (int, bool) {
+	count, ok := h.closeRoom(key, closeAdmin, closeReason("room closed", reason), "admin")
+	if ok {
+		h.logger.Info("Room closed by admin", logging.KeyRoomKey, key, "client_count", count)
+	}
+	return count, ok
+}
+
+// CloseDeletedRooms закрывает в хабе комнаты keys, удалённые из базы.
+func (h *Hub) CloseDeletedRooms(keys []string) {
+	for _, key := range keys {
+		if count, ok := h.closeRoom(key, closeDeleted, "room deleted", "deleted"); ok {
+			h.logger.Info("Deleted room closed", logging.KeyRoomKey, key, "client_count", count)
+		}
+	}
+}
+
+// closeRoom отключает всех клиентов комнаты key с кодом code и закрывает её в хабе.
+// teardown — метка причины в метрике закрытых комнат.
+func (h *Hub) closeRoom(key string, code int, reason, teardown string) (int, bool) {
+	h.mx.RLock()
+	room := h.Rooms[key]
+	h.mx.RUnlock()
+	if room == nil || room.ctx.Err() != nil {
+		return 0, false
+	}
+
+	clients := h.clientsWhere(key, func(*Client) bool { return true })
+	for _, c := range clients {
+		c.disconnect(code, reason)
+	}
+	metrics.HubRoomTeardowns.WithLabelValues(teardown).Inc()
+	room.cancel()
+	return len(clients), true
+}
+
+// Announce рассылает сообщение text во все открытые комнаты и возвращает их число.
+func (h *Hub) Announce(text string) int {
+	h.mx.RLock()
+	rooms := make([]*Room, 0, len(h.Rooms))
+	for _, room := range h.Rooms {
+		if room.ctx.Err() == nil {
+			rooms = append(rooms, room)
+		}
+	}
+	h.mx.RUnlock()
+
+	now := time.Now()
+	for _, room := range rooms {
+		room.broadcast(&Message{
+			Type:      CommandAnnouncement,
+			Timestamp: now,
+			Payload:   text,
+		})
+	}
+	return len(rooms)
+}
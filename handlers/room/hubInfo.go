@@ -0,0 +1,68 @@
+package room
+
+import (
+	"sort"
+	"time"
+)
+
+// RoomInfo — состояние комнаты хаба для диагностики.
+type RoomInfo struct {
+	Key         string       `json:"key"`
+	ClientCount int          `json:"client_count"`
+	CreatedAt   time.Time    `json:"created_at"`
+	Uptime      string       `json:"uptime"`
+	Clients     []ClientInfo `json:"clients"`
+}
+
+// ClientInfo — состояние подключённого клиента для диагностики.
+type ClientInfo struct {
+	ID            string    `json:"id"`
+	RemoteAddr    string    `json:"remote_addr"`
+	SendQueue     int       `json:"send_queue"`
+	SendQueueSize int       `json:"send_queue_size"`
+	ConnectedAt   time.Time `json:"connected_at"`
+	Uptime        string    `json:"uptime"`
+}
+
+// Snapshot возвращает состояние всех комнат хаба, отсортированных по ключу.
+func (h *Hub) Snapshot() []RoomInfo {
+	h.mx.RLock()
+	rooms := make([]*Room, 0, len(h.Rooms))
+	for _, room := range h.Rooms {
+		rooms = append(rooms, room)
+	}
+	h.mx.RUnlock()
+
+	infos := make([]RoomInfo, 0, len(rooms))
+	for _, room := range rooms {
+		infos = append(infos, room.info())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	return infos
+}
+
+func (r *Room) info() RoomInfo {
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+
+	now := time.Now()
+	info := RoomInfo{
+		Key:         r.key,
+		ClientCount: len(r.clients),
+		CreatedAt:   r.createdAt,
+		Uptime:      now.Sub(r.createdAt).Round(time.Second).String(),
+		Clients:     make([]ClientInfo, 0, len(r.clients)),
+	}
+	for client := range r.clients {
+		info.Clients = append(info.Clients, ClientInfo{
+			ID:            client.ID,
+			RemoteAddr:    client.Conn.RemoteAddr().String(),
+			SendQueue:     len(client.send),
+			SendQueueSize: cap(client.send),
+			ConnectedAt:   client.connectedAt,
+			Uptime:        now.Sub(client.connectedAt).Round(time.Second).String(),
+		})
+	}
+	sort.Slice(info.Clients, func(i, j int) bool { return info.Clients[i].ConnectedAt.Before(info.Clients[j].ConnectedAt) })
+	return info
+}
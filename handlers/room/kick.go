@@ -0,0 +1,23 @@
+package room
+
+import (
+	"net/http"
+)
+
+// Kick отключает участника от комнаты. Доступно владельцу и модераторам.
+// Тело запроса — ModerationAction с user_id или client_id и необязательной причиной.
+func Kick(hub *Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		room, who, action, ok := moderationRequest(w, r, hub)
+		if !ok {
+			return
+		}
+
+		if err := hub.kick(r.Context(), room, who, action); err != nil {
+			moderationError(w, r, err)
+			return
+		}
+
+		writeModeration(w, r, http.StatusOK, "Participant kicked successfully", nil)
+	}
+}
@@ -0,0 +1,42 @@
+package room
+
+import (
+	"net/http"
+	"room/database"
+	"room/logging"
+	"strconv"
+)
+
+// LiftSanction досрочно снимает бан или мьют по параметру id.
+// Доступно владельцу и модераторам.
+func LiftSanction(hub *Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+
+		room, user, ok := roomWithRole(w, r, hub.db, database.RoleOwner, database.RoleModerator)
+		if !ok {
+			return
+		}
+
+		id, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil || id <= 0 {
+			log.Error("Некорректное значение параметра id", "value", r.URL.Query().Get("id"))
+			http.Error(w, "Invalid id parameter", http.StatusBadRequest)
+			return
+		}
+
+		role, err := hub.db.GetUserRole(r.Context(), user.ID, room.ID)
+		if err != nil {
+			moderationError(w, r, err)
+			return
+		}
+
+		sanction, err := hub.liftSanction(r.Context(), room, actor{UserID: user.ID, Role: role}, id)
+		if err != nil {
+			moderationError(w, r, err)
+			return
+		}
+
+		writeModeration(w, r, http.StatusOK, "Sanction lifted successfully", sanction)
+	}
+}
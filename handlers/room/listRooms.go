@@ -0,0 +1,169 @@
+package room
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"room/database"
+	"room/logging"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Параметры постраничной выдачи каталога комнат.
+const (
+	defaultDirectoryLimit = 20
+	maxDirectoryLimit     = 100
+	// maxDirectoryBatches ограничивает число запросов к базе на одну страницу,
+	// когда фильтр по числу участников отсеивает почти все комнаты
+	maxDirectoryBatches = 10
+)
+
+var errInvalidCursor = errors.New("некорректный курсор")
+
+// directoryRoom — комната в каталоге.
+type directoryRoom struct {
+	Key          string    `json:"key"`
+	Title        string    `json:"title"`
+	Description  string    `json:"description"`
+	CoverImage   string    `json:"cover_image"`
+	HasVideo     bool      `json:"has_video"`
+	HasPassword  bool      `json:"has_password"`
+	Participants int       `json:"participants"`
+	LastActiveAt time.Time `json:"last_active_at"`
+}
+
+// listRoomsResponse — страница каталога. NextCursor пуст на последней странице.
+type listRoomsResponse struct {
+	Rooms      []directoryRoom `json:"rooms"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// encodeCursor упаковывает позицию в непрозрачную для клиента строку.
+func encodeCursor(c database.RoomCursor) string {
+	raw := fmt.Sprintf("%d:%d", c.LastActiveAt.UnixNano(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string) (*database.RoomCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, errInvalidCursor
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	roomID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	return &database.RoomCursor{LastActiveAt: time.Unix(0, n).UTC(), ID: roomID}, nil
+}
+
+// ListRooms возвращает каталог публичных комнат, начиная с самых активных.
+// Параметры: q — подстрока названия, has_video — true или false,
+// min_participants — минимум подключённых сейчас клиентов, limit и cursor — постраничная выдача.
+func ListRooms(hub *Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+		query := r.URL.Query()
+
+		filter := database.RoomFilter{
+			Query: strings.TrimSpace(query.Get("q")),
+			Limit: defaultDirectoryLimit,
+		}
+		if value := query.Get("has_video"); value != "" {
+			hasVideo, err := strconv.ParseBool(value)
+			if err != nil {
+				http.Error(w, "Invalid has_video parameter", http.StatusBadRequest)
+				return
+			}
+			filter.HasVideo = &hasVideo
+		}
+		if value := query.Get("limit"); value != "" {
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit <= 0 || limit > maxDirectoryLimit {
+				http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+				return
+			}
+			filter.Limit = limit
+		}
+		minParticipants := 0
+		if value := query.Get("min_participants"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				http.Error(w, "Invalid min_participants parameter", http.StatusBadRequest)
+				return
+			}
+			minParticipants = n
+		}
+		if value := query.Get("cursor"); value != "" {
+			cursor, err := decodeCursor(value)
+			if err != nil {
+				http.Error(w, "Invalid cursor parameter", http.StatusBadRequest)
+				return
+			}
+			filter.After = cursor
+		}
+
+		response := listRoomsResponse{Rooms: []directoryRoom{}}
+		for batch := 0; batch < maxDirectoryBatches; batch++ {
+			rooms, err := hub.db.ListPublicRooms(r.Context(), filter)
+			if err != nil {
+				log.Error("Не удалось получить список комнат", logging.KeyError, err)
+				http.Error(w, "Failed to list rooms", http.StatusInternalServerError)
+				return
+			}
+
+			for _, room := range rooms {
+				cursor := room.Cursor()
+				filter.After = &cursor
+
+				participants := hub.clientCount(room.Key)
+				if participants < minParticipants {
+					continue
+				}
+				response.Rooms = append(response.Rooms, directoryRoom{
+					Key:          room.Key,
+					Title:        room.Title,
+					Description:  room.Description,
+					CoverImage:   room.CoverImage,
+					HasVideo:     room.Video.Valid && room.Video.String != "",
+					HasPassword:  room.HasPassword,
+					Participants: participants,
+					LastActiveAt: room.LastActiveAt,
+				})
+				if len(response.Rooms) == filter.Limit {
+					break
+				}
+			}
+
+			if len(response.Rooms) == filter.Limit {
+				response.NextCursor = encodeCursor(*filter.After)
+				break
+			}
+			if len(rooms) < filter.Limit {
+				// Комнаты закончились
+				break
+			}
+			if batch == maxDirectoryBatches-1 {
+				// Страница неполная, но просмотрено достаточно; клиент продолжит с курсора
+				response.NextCursor = encodeCursor(*filter.After)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
@@ -0,0 +1,398 @@
+package room
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"room/audit"
+	"room/database"
+	"room/logging"
+	"time"
+	"unicode/utf8"
+)
+
+// Коды закрытия WebSocket, которые получают удалённые модератором клиенты.
+const (
+	closeKicked = 4001
+	closeBanned = 4003
+)
+
+// ModerationAction — параметры команды модерации, общие для WebSocket и REST.
+type ModerationAction struct {
+	// UserID — пользователь, к которому применяется действие.
+	UserID int `json:"user_id,omitempty"`
+	// ClientID — подключение, если пользователь анонимный (только для kick).
+	ClientID string `json:"client_id,omitempty"`
+	// IP — адрес для бана анонимных пользователей.
+	IP string `json:"ip,omitempty"`
+	// Scope — что запрещает мьют: control, chat или all.
+	Scope string `json:"scope,omitempty"`
+	// Duration — длительность бана или мьюта в секундах; 0 — бессрочно.
+	Duration float64 `json:"duration,omitempty"`
+	// Reason — причина, которую увидит пользователь.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Ошибки модерации, по которым выбирается ответ клиенту.
+var (
+	errForbidden     = errors.New("недостаточно прав для модерации")
+	errNoTarget      = errors.New("не указан участник")
+	errTargetMissing = errors.New("участник не подключён к комнате")
+	errInvalidAction = errors.New("некорректные параметры действия")
+)
+
+// actor — кто выполняет действие модерации.
+type actor struct {
+	UserID int
+	Role   string
+}
+
+func (a ModerationAction) expiresAt() *time.Time {
+	if a.Duration <= 0 {
+		return nil
+	}
+	t := time.Now().UTC().Add(time.Duration(a.Duration * float64(time.Second)))
+	return &t
+}
+
+// checkRank проверяет, что actor может модерировать пользователя targetID:
+// модераторы не трогают модераторов и владельца, владелец — всех, кроме себя.
+func (h *Hub) checkRank(ctx context.Context, room *database.Room, who actor, targetID int) error {
+	if who.Role != database.RoleOwner && who.Role != database.RoleModerator {
+		return errForbidden
+	}
+	if targetID == 0 {
+		return nil
+	}
+	if targetID == who.UserID {
+		return fmt.Errorf("%w: нельзя модерировать себя", errForbidden)
+	}
+	targetRole, err := h.db.GetUserRole(ctx, targetID, room.ID)
+	if err != nil && !errors.Is(err, database.ErrNotMember) {
+		return err
+	}
+	if targetRole == database.RoleOwner || (targetRole == database.RoleModerator && who.Role != database.RoleOwner) {
+		return errForbidden
+	}
+	return nil
+}
+
+// kick отключает участника от комнаты. Пользователь может переподключиться,
+// если его не забанили.
+func (h *Hub) kick(ctx context.Context, room *database.Room, who actor, a ModerationAction) error {
+	if a.UserID == 0 && a.ClientID == "" {
+		return errNoTarget
+	}
+
+	targets := h.clientsWhere(room.Key, func(c *Client) bool {
+		return (a.UserID != 0 && c.UserID == a.UserID) || (a.ClientID != "" && c.ID == a.ClientID)
+	})
+	if len(targets) == 0 {
+		return errTargetMissing
+	}
+	for _, c := range targets {
+		if err := h.checkRank(ctx, room, who, c.UserID); err != nil {
+			return err
+		}
+	}
+
+	// Ссылки на видео, выданные выгнанным пользователям, перестают действовать
+	revoked := map[int]bool{}
+	for _, c := range targets {
+		if c.UserID == 0 || revoked[c.UserID] {
+			continue
+		}
+		if err := h.db.RevokeMedia(ctx, room.ID, c.UserID); err != nil {
+			return err
+		}
+		revoked[c.UserID] = true
+	}
+	for _, c := range targets {
+		c.disconnect(closeKicked, closeReason("kicked", a.Reason))
+	}
+	h.notify(room.Key, CommandKick, a)
+
+	audit.Record(ctx, h.db, audit.Event{
+		Action:  audit.ActionKick,
+		ActorID: who.UserID,
+		RoomID:  room.ID,
+		Payload: map[string]any{"user_id": a.UserID, "client_id": a.ClientID, "reason": a.Reason, "connections": len(targets)},
+	})
+	return nil
+}
+
+// ban запрещает пользователю или IP-адресу входить в комнату и отключает их.
+func (h *Hub) ban(ctx context.Context, room *database.Room, who actor, a ModerationAction) (*database.Sanction, error) {
+	if a.UserID == 0 && a.IP == "" {
+		return nil, errNoTarget
+	}
+	if err := h.checkRank(ctx, room, who, a.UserID); err != nil {
+		return nil, err
+	}
+	// Бан по IP задевает всех с этого адреса: каждого из них нужно иметь право модерировать
+	targets := h.clientsWhere(room.Key, func(c *Client) bool {
+		return (a.UserID != 0 && c.UserID == a.UserID) || (a.IP != "" && c.IP == a.IP)
+	})
+	for _, c := range targets {
+		if err := h.checkRank(ctx, room, who, c.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	sanction := &database.Sanction{
+		RoomID:    room.ID,
+		Kind:      database.SanctionBan,
+		IP:        a.IP,
+		Reason:    a.Reason,
+		ExpiresAt: a.expiresAt(),
+		CreatedBy: who.UserID,
+	}
+	if a.UserID != 0 {
+		sanction.UserID = &a.UserID
+	}
+	if err := h.db.CreateSanction(ctx, sanction); err != nil {
+		return nil, err
+	}
+
+	for _, c := range targets {
+		// Ссылки забаненного пользователя отзывает сама санкция, остальных — бан по IP
+		if c.UserID != 0 && c.UserID != a.UserID {
+			if err := h.db.RevokeMedia(ctx, room.ID, c.UserID); err != nil {
+				logging.FromContext(ctx).Warn("Не удалось отозвать ссылки на видео", logging.KeyUserID, c.UserID, logging.KeyError, err)
+			}
+		}
+		c.disconnect(closeBanned, closeReason("banned", a.Reason))
+	}
+	h.notify(room.Key, CommandBan, a)
+
+	audit.Record(ctx, h.db, audit.Event{
+		Action:  audit.ActionBan,
+		ActorID: who.UserID,
+		RoomID:  room.ID,
+		Payload: map[string]any{"sanction_id": sanction.ID, "user_id": a.UserID, "ip": a.IP, "duration": a.Duration, "reason": a.Reason},
+	})
+	return sanction, nil
+}
+
+// mute запрещает пользователю отправлять команды управления, сообщения чата или всё сразу.
+func (h *Hub) mute(ctx context.Context, room *database.Room, who actor, a ModerationAction) (*database.Sanction, error) {
+	if a.UserID == 0 {
+		return nil, errNoTarget
+	}
+	if a.Scope == "" {
+		a.Scope = database.MuteScopeAll
+	}
+	if !database.ValidMuteScope(a.Scope) {
+		return nil, errInvalidAction
+	}
+	if err := h.checkRank(ctx, room, who, a.UserID); err != nil {
+		return nil, err
+	}
+
+	sanction := &database.Sanction{
+		RoomID:    room.ID,
+		Kind:      database.SanctionMute,
+		UserID:    &a.UserID,
+		Scope:     a.Scope,
+		Reason:    a.Reason,
+		ExpiresAt: a.expiresAt(),
+		CreatedBy: who.UserID,
+	}
+	if err := h.db.CreateSanction(ctx, sanction); err != nil {
+		return nil, err
+	}
+
+	for _, c := range h.clientsWhere(room.Key, func(c *Client) bool { return c.UserID == a.UserID }) {
+		c.setMute(sanction)
+	}
+	h.notify(room.Key, CommandMute, a)
+
+	audit.Record(ctx, h.db, audit.Event{
+		Action:  audit.ActionMute,
+		ActorID: who.UserID,
+		RoomID:  room.ID,
+		Payload: map[string]any{"sanction_id": sanction.ID, "user_id": a.UserID, "scope": a.Scope, "duration": a.Duration, "reason": a.Reason},
+	})
+	return sanction, nil
+}
+
+// liftSanction досрочно снимает бан или мьют.
+func (h *Hub) liftSanction(ctx context.Context, room *database.Room, who actor, id int) (*database.Sanction, error) {
+	if who.Role != database.RoleOwner && who.Role != database.RoleModerator {
+		return nil, errForbidden
+	}
+	sanction, err := h.db.LiftSanction(ctx, room.ID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	action := audit.ActionLiftBan
+	if sanction.Kind == database.SanctionMute {
+		action = audit.ActionLiftMute
+		if sanction.UserID != nil {
+			for _, c := range h.clientsWhere(room.Key, func(c *Client) bool { return c.UserID == *sanction.UserID }) {
+				c.setMute(nil)
+			}
+		}
+	}
+
+	audit.Record(ctx, h.db, audit.Event{
+		Action:  action,
+		ActorID: who.UserID,
+		RoomID:  room.ID,
+		Payload: map[string]any{"sanction_id": sanction.ID},
+	})
+	return sanction, nil
+}
+
+// clientsWhere возвращает подключённых клиентов комнаты, подходящих под match.
+func (h *Hub) clientsWhere(key string, match func(c *Client) bool) []*Client {
+	h.mx.RLock()
+	room := h.Rooms[key]
+	h.mx.RUnlock()
+	if room == nil {
+		return nil
+	}
+
+	room.mx.RLock()
+	defer room.mx.RUnlock()
+	var clients []*Client
+	for c := range room.clients {
+		if match(c) {
+			clients = append(clients, c)
+		}
+	}
+	return clients
+}
+
+// notify сообщает всем клиентам комнаты о действии модерации.
+// Адрес бана по IP остальным участникам не раскрывается.
+func (h *Hub) notify(key string, command CommandType, a ModerationAction) {
+	h.mx.RLock()
+	room := h.Rooms[key]
+	h.mx.RUnlock()
+	if room == nil {
+		return
+	}
+	a.IP = ""
+	room.broadcast(&Message{
+		Type:       command,
+		Timestamp:  time.Now(),
+		Payload:    a.Reason,
+		Moderation: &a,
+	})
+}
+
+// clientIP возвращает IP-адрес клиента без порта.
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// maxCloseReason — сколько байт причины помещается в кадр закрытия WebSocket.
+const maxCloseReason = 123
+
+// closeReason собирает причину закрытия и обрезает её по границе символа,
+// чтобы она поместилась в кадр закрытия.
+func closeReason(prefix, reason string) string {
+	text := prefix
+	if reason != "" {
+		text += ": " + reason
+	}
+	if len(text) <= maxCloseReason {
+		return text
+	}
+	end := maxCloseReason
+	for end > 0 && !utf8.RuneStart(text[end]) {
+		end--
+	}
+	return text[:end]
+}
+
+// moderationResponse — структура для ответа на действие модерации
+type moderationResponse struct {
+	Status   string             `json:"status"`
+	Message  string             `json:"message"`
+	Sanction *database.Sanction `json:"sanction,omitempty"`
+}
+
+// moderationRequest загружает комнату, роль модератора и параметры действия из тела запроса.
+// При ошибке пишет ответ сам.
+func moderationRequest(w http.ResponseWriter, r *http.Request, hub *Hub) (*database.Room, actor, ModerationAction, bool) {
+	log := logging.FromContext(r.Context())
+	var a ModerationAction
+
+	room, user, ok := roomWithRole(w, r, hub.db, database.RoleOwner, database.RoleModerator)
+	if !ok {
+		return nil, actor{}, a, false
+	}
+	role, err := hub.db.GetUserRole(r.Context(), user.ID, room.ID)
+	if err != nil {
+		log.Error("Не удалось получить роль пользователя", logging.KeyRoomKey, room.Key, logging.KeyError, err)
+		http.Error(w, "Failed to check access", http.StatusInternalServerError)
+		return nil, actor{}, a, false
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+		log.Error("Некорректное тело запроса", logging.KeyError, err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return nil, actor{}, a, false
+	}
+	if a.Duration < 0 {
+		http.Error(w, "Invalid duration", http.StatusBadRequest)
+		return nil, actor{}, a, false
+	}
+
+	return room, actor{UserID: user.ID, Role: role}, a, true
+}
+
+// moderationError пишет ответ на ошибку действия модерации.
+func moderationError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, errForbidden):
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	case errors.Is(err, errNoTarget), errors.Is(err, errInvalidAction):
+		http.Error(w, "Invalid moderation target or parameters", http.StatusBadRequest)
+	case errors.Is(err, errTargetMissing):
+		http.Error(w, "Participant not connected", http.StatusNotFound)
+	case errors.Is(err, database.ErrSanctionNotFound):
+		http.Error(w, "Sanction not found", http.StatusNotFound)
+	default:
+		logging.FromContext(r.Context()).Error("Не удалось выполнить действие модерации", logging.KeyError, err)
+		http.Error(w, "Moderation failed", http.StatusInternalServerError)
+	}
+}
+
+// moderationReason возвращает причину отказа для сообщения error по WebSocket.
+func moderationReason(err error) string {
+	switch {
+	case errors.Is(err, errForbidden):
+		return "forbidden"
+	case errors.Is(err, errNoTarget), errors.Is(err, errInvalidAction):
+		return "invalid moderation target or parameters"
+	case errors.Is(err, errTargetMissing):
+		return "participant not connected"
+	default:
+		return "moderation failed"
+	}
+}
+
+// writeModeration отправляет успешный ответ на действие модерации.
+func writeModeration(w http.ResponseWriter, r *http.Request, status int, message string, sanction *database.Sanction) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	response := moderationResponse{
+		Status:   "success",
+		Message:  message,
+		Sanction: sanction,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logging.FromContext(r.Context()).Error("Не удалось закодировать ответ", logging.KeyError, err)
+	}
+}
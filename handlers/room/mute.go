@@ -0,0 +1,25 @@
+package room
+
+import (
+	"net/http"
+)
+
+// Mute запрещает пользователю отправлять команды управления, сообщения чата или всё сразу.
+// Доступно владельцу и модераторам. Тело запроса — ModerationAction
+// с user_id, scope, длительностью в секундах (0 — бессрочно) и причиной.
+func Mute(hub *Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		room, who, action, ok := moderationRequest(w, r, hub)
+		if !ok {
+			return
+		}
+
+		sanction, err := hub.mute(r.Context(), room, who, action)
+		if err != nil {
+			moderationError(w, r, err)
+			return
+		}
+
+		writeModeration(w, r, http.StatusCreated, "Mute created successfully", sanction)
+	}
+}
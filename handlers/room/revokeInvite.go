@@ -0,0 +1,58 @@
+package room
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"room/database"
+	"room/logging"
+	"strconv"
+)
+
+// revokeInviteResponse — структура для ответа при успешном отзыве приглашения
+type revokeInviteResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	ID      int    `json:"id"`
+}
+
+// RevokeInvite отзывает приглашение комнаты. Доступно владельцу и модераторам.
+func RevokeInvite(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+
+		room, _, ok := roomWithRole(w, r, db, database.RoleOwner, database.RoleModerator)
+		if !ok {
+			return
+		}
+
+		id, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil || id <= 0 {
+			log.Error("Некорректное значение параметра id", "value", r.URL.Query().Get("id"))
+			http.Error(w, "Invalid id parameter", http.StatusBadRequest)
+			return
+		}
+
+		err = db.RevokeInvite(r.Context(), room.ID, id)
+		if errors.Is(err, database.ErrInviteNotFound) {
+			http.Error(w, "Invite not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Error("Не удалось отозвать приглашение", "invite_id", id, logging.KeyError, err)
+			http.Error(w, "Failed to revoke invite", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		response := revokeInviteResponse{
+			Status:  "success",
+			Message: "Invite revoked successfully",
+			ID:      id,
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
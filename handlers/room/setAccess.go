@@ -0,0 +1,81 @@
+package room
+
+import (
+	"encoding/json"
+	"net/http"
+	"room/audit"
+	"room/database"
+	"room/logging"
+)
+
+// setAccessRequest — новые настройки доступа к комнате.
+type setAccessRequest struct {
+	// Visibility — public, unlisted или private; пустое значение оставляет текущую.
+	Visibility string `json:"visibility"`
+	// Password — новый пароль; не задан — пароль не меняется, пустая строка снимает пароль.
+	Password *string `json:"password"`
+}
+
+// setAccessResponse — структура для ответа при успешном изменении доступа
+type setAccessResponse struct {
+	Status      string `json:"status"`
+	Message     string `json:"message"`
+	Visibility  string `json:"visibility"`
+	HasPassword bool   `json:"has_password"`
+}
+
+// SetAccess меняет видимость и пароль комнаты. Доступно только владельцу.
+func SetAccess(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+
+		room, user, ok := roomWithRole(w, r, db, database.RoleOwner)
+		if !ok {
+			return
+		}
+
+		var req setAccessRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Error("Некорректное тело запроса", logging.KeyError, err)
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.Visibility == "" {
+			req.Visibility = room.Visibility
+		}
+		if !database.ValidVisibility(req.Visibility) {
+			http.Error(w, "Invalid visibility", http.StatusBadRequest)
+			return
+		}
+
+		err := db.SetRoomAccess(r.Context(), room.ID, req.Visibility, req.Password)
+		if err != nil {
+			log.Error("Не удалось изменить доступ к комнате", logging.KeyRoomKey, room.Key, logging.KeyError, err)
+			http.Error(w, "Failed to update room access", http.StatusInternalServerError)
+			return
+		}
+
+		hasPassword := room.HasPassword
+		if req.Password != nil {
+			hasPassword = *req.Password != ""
+		}
+		audit.Record(r.Context(), db, audit.Event{
+			Action:  audit.ActionAccessChanged,
+			ActorID: user.ID,
+			RoomID:  room.ID,
+			Payload: map[string]any{"visibility": req.Visibility, "has_password": hasPassword},
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		response := setAccessResponse{
+			Status:      "success",
+			Message:     "Room access updated successfully",
+			Visibility:  req.Visibility,
+			HasPassword: hasPassword,
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
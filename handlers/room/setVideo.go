@@ -2,58 +2,73 @@ package room
 
 import (
 	"encoding/json"
-	"fmt"
-	"log/slog"
+	"errors"
 	"net/http"
+	"room/audit"
 	"room/database"
+	"room/logging"
 )
 
-func SetVideo(database *database.DB) http.HandlerFunc {
+// SetVideo устанавливает видео комнаты. Принимается перекодированное видео
+// или внешнее, ссылка на которое уже проверена через SetVideoURL: из библиотеки
+// текущего пользователя, загруженное в эту комнату или уже установленное в ней.
+// Доступно владельцу и модераторам. Открытая комната получает параметры нового видео,
+// чтобы проверять перемотку.
+func SetVideo(hub *Hub) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		key := r.URL.Query().Get("key")
-		if key == "" {
-			slog.Error("Отсутствует обязательный параметр: key",
-				"удалённый_адрес", r.RemoteAddr,
-				"метод", r.Method,
-				"путь", r.URL.Path,
-			)
-			http.Error(w, "Missing required parameter: key", http.StatusBadRequest)
-			return
-		}
-		file_name := r.URL.Query().Get("file_name")
+		log := logging.FromContext(r.Context())
+		db := hub.db
 
+		file_name := r.URL.Query().Get("file_name")
 		if file_name == "" {
-			slog.Error("Отсутствует обязательный параметр: file_name",
-				"удалённый_адрес", r.RemoteAddr,
-				"метод", r.Method,
-				"путь", r.URL.Path,
-			)
+			log.Error("Отсутствует обязательный параметр: file_name")
 			http.Error(w, "Missing required parameter: file_name", http.StatusBadRequest)
 			return
 		}
-		err := database.SetRoomVideoByKey(key, file_name)
+
+		room, user, ok := roomWithRole(w, r, db, database.RoleOwner, database.RoleModerator)
+		if !ok {
+			return
+		}
+
+		video, err := hub.findVideo(r.Context(), room.ID, user.ID, file_name)
+		if errors.Is(err, database.ErrVideoNotFound) {
+			http.Error(w, "Video not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, errVideoNotReady) {
+			http.Error(w, "Video is not ready", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Error("Не удалось проверить видео", "video", file_name, logging.KeyError, err)
+			http.Error(w, "Failed to check video", http.StatusInternalServerError)
+			return
+		}
+
+		err = db.SetRoomVideo(r.Context(), room.ID, file_name)
 		if err != nil {
-			slog.Error(fmt.Sprintf("Не удалось установить видео для комнаты комнату с Key: %s", key),
-				"удалённый_адрес", r.RemoteAddr,
-				"метод", r.Method,
-				"путь", r.URL.Path,
-				"ошибка", err.Error(),
+			log.Error("Не удалось установить видео для комнаты",
+				logging.KeyRoomKey, room.Key,
+				logging.KeyError, err,
 			)
 			http.Error(w, "Room not found", http.StatusNotFound)
 			return
 		}
-	
+		hub.UpdateVideo(room.Key, video)
+		audit.Record(r.Context(), db, audit.Event{
+			Action:  audit.ActionVideoChanged,
+			ActorID: user.ID,
+			RoomID:  room.ID,
+			Payload: map[string]any{"video": video.Name, "source": video.Source},
+		})
+
 		// Устанавливаем тип содержимого
 		w.Header().Set("Content-Type", "application/json")
 
 		err = json.NewEncoder(w).Encode(file_name)
 		if err != nil {
-			slog.Error("Ошибка при отправке ответа с ключом комнаты",
-				"error", err,
-				"удалённый_адрес", r.RemoteAddr,
-				"метод", r.Method,
-				"путь", r.URL.Path,
-			)
+			log.Error("Ошибка при отправке ответа с ключом комнаты", logging.KeyError, err)
 			// Нельзя вызвать http.Error после начала записи в w
 			return
 		}
@@ -0,0 +1,87 @@
+package room
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"room/audit"
+	"room/database"
+	"room/logging"
+	"room/remote"
+)
+
+type setVideoURLRequest struct {
+	URL string `json:"url"`
+}
+
+// SetVideoURL проверяет ссылку на внешнее видео — прямой файл или манифест HLS —
+// и устанавливает его в комнату. Доступно владельцу и модераторам.
+func SetVideoURL(hub *Hub, validator *remote.Validator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+
+		room, user, ok := roomWithRole(w, r, hub.db, database.RoleOwner, database.RoleModerator)
+		if !ok {
+			return
+		}
+
+		var req setVideoURLRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Error("Некорректное тело запроса", logging.KeyError, err)
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		source, err := validator.Check(r.Context(), req.URL)
+		switch {
+		case errors.Is(err, remote.ErrInvalidURL):
+			http.Error(w, "Invalid video URL", http.StatusBadRequest)
+			return
+		case errors.Is(err, remote.ErrForbiddenAddress):
+			log.Warn("Ссылка на видео ведёт на внутренний адрес", "url", req.URL, logging.KeyError, err)
+			http.Error(w, "Video URL points to a forbidden address", http.StatusUnprocessableEntity)
+			return
+		case errors.Is(err, remote.ErrUnsupportedType):
+			http.Error(w, "Unsupported video type", http.StatusUnsupportedMediaType)
+			return
+		case err != nil:
+			log.Warn("Ссылка на видео недоступна", "url", req.URL, logging.KeyError, err)
+			http.Error(w, "Video URL is not reachable", http.StatusUnprocessableEntity)
+			return
+		}
+
+		kind := database.SourceURL
+		if source.HLS {
+			kind = database.SourceHLS
+		}
+		video, err := hub.db.CreateVideo(r.Context(), database.Video{
+			Name:      source.URL,
+			Source:    kind,
+			Owner:     &user.ID,
+			VideoInfo: database.VideoInfo{Container: source.ContentType},
+		})
+		if err != nil {
+			log.Error("Не удалось сохранить видео", "url", source.URL, logging.KeyError, err)
+			http.Error(w, "Failed to save video", http.StatusInternalServerError)
+			return
+		}
+		if err := hub.db.SetRoomVideo(r.Context(), room.ID, video.Name); err != nil {
+			log.Error("Не удалось установить видео для комнаты", logging.KeyRoomKey, room.Key, logging.KeyError, err)
+			http.Error(w, "Failed to set video", http.StatusInternalServerError)
+			return
+		}
+		hub.UpdateVideo(room.Key, video)
+		audit.Record(r.Context(), hub.db, audit.Event{
+			Action:  audit.ActionVideoChanged,
+			ActorID: user.ID,
+			RoomID:  room.ID,
+			Payload: map[string]any{"video": video.Name, "source": video.Source},
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(video); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package room
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"room/database"
+	"room/logging"
+	"strconv"
+	"time"
+)
+
+// Пределы для субтитров.
+const (
+	maxSubtitleSize        = 5 << 20
+	maxSubtitleLabelLength = 100
+	// maxSubtitleOffset — предел сдвига субтитров в секундах в обе стороны
+	maxSubtitleOffset = 600
+)
+
+// subtitleLanguage — код языка BCP 47, например en, pt-BR или zh-Hans.
+var subtitleLanguage = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+var errSubtitleTrack = errors.New("дорожка субтитров не относится к видео комнаты")
+
+// subtitleTrackResponse — дорожка субтитров со ссылкой на её файл WebVTT.
+type subtitleTrackResponse struct {
+	database.SubtitleTrack
+	URL string `json:"url"`
+}
+
+func newSubtitleTrackResponse(track database.SubtitleTrack, roomKey, publicURL string) subtitleTrackResponse {
+	return subtitleTrackResponse{
+		SubtitleTrack: track,
+		URL:           fmt.Sprintf("%s/room/subtitle?key=%s&id=%d", publicURL, url.QueryEscape(roomKey), track.ID),
+	}
+}
+
+// subtitles выбирает дорожку или меняет сдвиг субтитров для всей комнаты.
+// Payload команды subtitle-track — ID дорожки, пустой — выключить субтитры;
+// time команды subtitle-offset — сдвиг в секундах.
+func (c *Client) subtitles(msg *Message) {
+	ctx := logging.WithLogger(context.Background(), c.logger)
+	state := c.Room.metadata().Subtitles
+
+	switch msg.Type {
+	case CommandSubtitleTrack:
+		if msg.Payload == "" {
+			state.TrackID = nil
+			break
+		}
+		id, err := strconv.Atoi(msg.Payload)
+		if err != nil {
+			c.sendError("invalid subtitle track")
+			return
+		}
+		if err := c.hub.checkSubtitleTrack(ctx, c.roomID, id); err != nil {
+			if !errors.Is(err, database.ErrSubtitleNotFound) && !errors.Is(err, errSubtitleTrack) {
+				c.logger.Error("failed to get subtitle track", logging.KeyError, err)
+			}
+			c.sendError("subtitle track not found")
+			return
+		}
+		state.TrackID = &id
+	case CommandSubtitleOffset:
+		if math.IsNaN(msg.Time) || math.Abs(msg.Time) > maxSubtitleOffset {
+			c.sendError("invalid subtitle offset")
+			return
+		}
+		state.Offset = msg.Time
+	}
+
+	if err := c.hub.db.SetRoomSubtitles(ctx, c.roomID, state); err != nil {
+		c.logger.Error("failed to save subtitles", logging.KeyError, err)
+		c.sendError("failed to update subtitles")
+		return
+	}
+	c.hub.UpdateSubtitles(c.Room.key, state)
+}
+
+// checkSubtitleTrack проверяет, что дорожка принадлежит текущему видео комнаты.
+func (h *Hub) checkSubtitleTrack(ctx context.Context, roomID, trackID int) error {
+	track, err := h.db.GetSubtitleTrack(ctx, roomID, trackID)
+	if err != nil {
+		return err
+	}
+	room, err := h.db.GetRoomByID(ctx, roomID)
+	if err != nil {
+		return err
+	}
+	if !room.Video.Valid || room.Video.String != track.Video {
+		return errSubtitleTrack
+	}
+	return nil
+}
+
+// UpdateSubtitles обновляет субтитры открытой комнаты и рассылает её состояние клиентам.
+func (h *Hub) UpdateSubtitles(key string, state database.SubtitleState) {
+	h.mx.RLock()
+	room := h.Rooms[key]
+	h.mx.RUnlock()
+	if room == nil {
+		return
+	}
+	meta := room.metadata()
+	meta.Subtitles = state
+	room.meta.Store(&meta)
+	room.broadcast(&Message{
+		Type:      CommandRoomUpdate,
+		Timestamp: time.Now(),
+		Room:      &meta,
+	})
+}
@@ -0,0 +1,119 @@
+package room
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"room/audit"
+	"room/database"
+	"room/logging"
+	"unicode/utf8"
+)
+
+// Пределы значений описания комнаты.
+const (
+	maxTitleLength       = 100
+	maxDescriptionLength = 2000
+	maxParticipantsLimit = 1000
+	minPlaybackRate      = 0.25
+	maxPlaybackRate      = 4
+)
+
+// updateRoomRequest — изменяемые поля комнаты. Незаданные поля не меняются,
+// в settings меняются только переданные ключи.
+type updateRoomRequest struct {
+	Title           *string         `json:"title"`
+	Description     *string         `json:"description"`
+	CoverImage      *string         `json:"cover_image"`
+	MaxParticipants *int            `json:"max_participants"`
+	PlaybackRate    *float64        `json:"playback_rate"`
+	Settings        json.RawMessage `json:"settings"`
+}
+
+// apply применяет изменения к описанию комнаты и возвращает текст ошибки проверки.
+func (req updateRoomRequest) apply(meta *database.RoomMetadata) string {
+	if req.Title != nil {
+		if utf8.RuneCountInString(*req.Title) > maxTitleLength {
+			return "Title is too long"
+		}
+		meta.Title = *req.Title
+	}
+	if req.Description != nil {
+		if utf8.RuneCountInString(*req.Description) > maxDescriptionLength {
+			return "Description is too long"
+		}
+		meta.Description = *req.Description
+	}
+	if req.CoverImage != nil {
+		if *req.CoverImage != "" {
+			u, err := url.Parse(*req.CoverImage)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return "Cover image must be an http(s) URL"
+			}
+		}
+		meta.CoverImage = *req.CoverImage
+	}
+	if req.MaxParticipants != nil {
+		if *req.MaxParticipants < 0 || *req.MaxParticipants > maxParticipantsLimit {
+			return "Invalid max_participants"
+		}
+		meta.MaxParticipants = *req.MaxParticipants
+	}
+	if req.PlaybackRate != nil {
+		if *req.PlaybackRate < minPlaybackRate || *req.PlaybackRate > maxPlaybackRate {
+			return "Invalid playback_rate"
+		}
+		meta.PlaybackRate = *req.PlaybackRate
+	}
+	if len(req.Settings) > 0 {
+		if err := json.Unmarshal(req.Settings, &meta.Settings); err != nil {
+			return "Invalid settings"
+		}
+	}
+	return ""
+}
+
+// UpdateRoom меняет описание и настройки комнаты и рассылает их подключённым клиентам.
+// Доступно только владельцу.
+func UpdateRoom(hub *Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+
+		room, user, ok := roomWithRole(w, r, hub.db, database.RoleOwner)
+		if !ok {
+			return
+		}
+
+		var req updateRoomRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Error("Некорректное тело запроса", logging.KeyError, err)
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		meta := room.RoomMetadata
+		if problem := req.apply(&meta); problem != "" {
+			http.Error(w, problem, http.StatusBadRequest)
+			return
+		}
+
+		if err := hub.db.UpdateRoomMetadata(r.Context(), room.ID, meta); err != nil {
+			log.Error("Не удалось изменить описание комнаты", logging.KeyRoomKey, room.Key, logging.KeyError, err)
+			http.Error(w, "Failed to update room", http.StatusInternalServerError)
+			return
+		}
+		room.RoomMetadata = meta
+		hub.UpdateRoom(room.Key, meta)
+		audit.Record(r.Context(), hub.db, audit.Event{
+			Action:  audit.ActionRoomUpdated,
+			ActorID: user.ID,
+			RoomID:  room.ID,
+			Payload: map[string]any{"title": meta.Title, "settings": meta.Settings},
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(room); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package room
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"room/database"
+	"room/logging"
+	"room/storage"
+	"room/subtitles"
+	"strings"
+	"unicode/utf8"
+)
+
+// UploadSubtitles добавляет дорожку субтитров к текущему видео комнаты.
+// Файл SRT, WebVTT или ASS передаётся телом запроса или полем subtitles формы
+// multipart/form-data и сохраняется в WebVTT. Параметры: language — код языка,
+// label — подпись дорожки, format — формат тела, если его нельзя определить по содержимому.
+// Доступно владельцу и модераторам.
+func UploadSubtitles(db *database.DB, store storage.Storage, publicURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+
+		room, user, ok := roomWithRole(w, r, db, database.RoleOwner, database.RoleModerator)
+		if !ok {
+			return
+		}
+		if !room.Video.Valid || room.Video.String == "" {
+			http.Error(w, "Room has no video", http.StatusConflict)
+			return
+		}
+
+		query := r.URL.Query()
+		language := query.Get("language")
+		if len(language) > 35 || !subtitleLanguage.MatchString(language) {
+			http.Error(w, "Invalid language parameter", http.StatusBadRequest)
+			return
+		}
+		label := strings.TrimSpace(query.Get("label"))
+		if utf8.RuneCountInString(label) > maxSubtitleLabelLength {
+			http.Error(w, "Label is too long", http.StatusBadRequest)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxSubtitleSize+64<<10)
+		var body io.Reader = r.Body
+		name := "." + query.Get("format")
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			file, header, err := r.FormFile("subtitles")
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, "Subtitles file is too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			if err != nil {
+				http.Error(w, "Missing subtitles file", http.StatusBadRequest)
+				return
+			}
+			defer file.Close()
+			body = file
+			name = header.Filename
+		}
+
+		data, err := io.ReadAll(io.LimitReader(body, maxSubtitleSize+1))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) || len(data) > maxSubtitleSize {
+			http.Error(w, "Subtitles file is too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to read subtitles", http.StatusBadRequest)
+			return
+		}
+
+		format, err := subtitles.Detect(name, data)
+		if err != nil {
+			http.Error(w, "Subtitles must be SRT, WebVTT or ASS", http.StatusUnsupportedMediaType)
+			return
+		}
+		if !utf8.Valid(data) {
+			http.Error(w, "Subtitles must be UTF-8 encoded", http.StatusUnprocessableEntity)
+			return
+		}
+		vtt, err := subtitles.ToWebVTT(data, format)
+		if err != nil {
+			log.Warn("Не удалось разобрать субтитры", "format", format, logging.KeyError, err)
+			http.Error(w, "Invalid subtitles", http.StatusUnprocessableEntity)
+			return
+		}
+
+		file := fmt.Sprintf("subtitles/%d/%s.vtt", room.ID, strings.ToLower(rand.Text()))
+		if _, err := store.Save(r.Context(), file, bytes.NewReader(vtt)); err != nil {
+			log.Error("Не удалось сохранить субтитры", logging.KeyError, err)
+			http.Error(w, "Failed to save subtitles", http.StatusInternalServerError)
+			return
+		}
+
+		track, err := db.CreateSubtitleTrack(r.Context(), room.ID, room.Video.String, language, label, file, user.ID)
+		if err != nil {
+			store.Delete(r.Context(), file)
+			log.Error("Не удалось сохранить субтитры", logging.KeyError, err)
+			http.Error(w, "Failed to save subtitles", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(newSubtitleTrackResponse(*track, room.Key, publicURL)); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
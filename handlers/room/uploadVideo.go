@@ -0,0 +1,187 @@
+package room
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"room/database"
+	"room/handlers/media"
+	"room/logging"
+	"room/quota"
+	"room/storage"
+	"room/transcode"
+	"strings"
+	"unicode/utf8"
+)
+
+// videoJobResponse — задание перекодирования со ссылкой на плейлист HLS.
+type videoJobResponse struct {
+	database.TranscodeJob
+	// Playlist — подписанная ссылка на основной плейлист; появляется, когда видео готово.
+	Playlist string `json:"playlist,omitempty"`
+}
+
+// newVideoJobResponse подписывает ссылку на видео задания для пользователя userID
+// в комнате, из которой загружено видео.
+func newVideoJobResponse(job database.TranscodeJob, linker *media.Linker, userID int) videoJobResponse {
+	response := videoJobResponse{TranscodeJob: job}
+	if job.Playable() && job.RoomID != nil {
+		response.Playlist = linker.Links(job.Video, false, *job.RoomID, userID).Playlist
+	}
+	return response
+}
+
+// maxVideoTitle — наибольшая длина названия видео в символах.
+const maxVideoTitle = 200
+
+// multipartOverhead — запас на заголовки и границы формы сверх размера файла.
+const multipartOverhead = 64 << 10
+
+// sniffSize — сколько байт начала файла нужно для определения его типа.
+const sniffSize = 512
+
+// uploadLimitError отвечает клиенту о нарушенном ограничении загрузки.
+// Возвращает false, если err — не ошибка ограничения.
+func uploadLimitError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, quota.ErrTooLarge):
+		http.Error(w, "Video is too large", http.StatusRequestEntityTooLarge)
+	case errors.Is(err, quota.ErrQuotaExceeded):
+		http.Error(w, "Storage quota exceeded", http.StatusRequestEntityTooLarge)
+	case errors.Is(err, quota.ErrRoomFull):
+		http.Error(w, "Room video limit reached", http.StatusUnprocessableEntity)
+	case errors.Is(err, quota.ErrType):
+		http.Error(w, "Unsupported video type", http.StatusUnprocessableEntity)
+	default:
+		return false
+	}
+	return true
+}
+
+// UploadVideo принимает видеофайл для комнаты и ставит его в очередь перекодирования в HLS.
+// Файл передаётся телом запроса или полем video формы multipart/form-data.
+// Название видео в библиотеке берётся из параметра title или из имени файла формы.
+// Ограничения размера и места проверяются до начала загрузки по заявленной длине
+// и во время чтения файла. Одинаковые файлы, определяемые по SHA-256, перекодируются
+// один раз и хранятся в одном экземпляре. Доступно владельцу и модераторам.
+func UploadVideo(db *database.DB, store storage.Storage, pipeline *transcode.Pipeline, limits *quota.Limits, linker *media.Linker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+
+		room, user, ok := roomWithRole(w, r, db, database.RoleOwner, database.RoleModerator)
+		if !ok {
+			return
+		}
+
+		budget, err := limits.Check(r.Context(), db, user.ID, room.ID, r.ContentLength)
+		if uploadLimitError(w, err) {
+			return
+		}
+		if err != nil {
+			log.Error("Не удалось проверить ограничения загрузки", logging.KeyError, err)
+			http.Error(w, "Failed to check upload limits", http.StatusInternalServerError)
+			return
+		}
+		// После постановки в очередь загрузку учитывает база
+		defer budget.Release()
+		if r.ContentLength > budget.Bytes+multipartOverhead {
+			uploadLimitError(w, budget.Err())
+			return
+		}
+
+		title := r.URL.Query().Get("title")
+
+		r.Body = http.MaxBytesReader(w, r.Body, budget.Bytes+multipartOverhead)
+		var body io.Reader = r.Body
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			reader, err := r.MultipartReader()
+			if err != nil {
+				http.Error(w, "Invalid multipart body", http.StatusBadRequest)
+				return
+			}
+			// Файл читается потоком, без буферизации всей формы
+			for {
+				part, err := reader.NextPart()
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					uploadLimitError(w, budget.Err())
+					return
+				}
+				if err != nil {
+					http.Error(w, "Missing video file", http.StatusBadRequest)
+					return
+				}
+				if part.FormName() == "video" {
+					body = part
+					if title == "" {
+						title = part.FileName()
+					}
+					break
+				}
+			}
+		}
+
+		buffered := bufio.NewReaderSize(body, sniffSize)
+		head, _ := buffered.Peek(sniffSize)
+		if len(head) == 0 {
+			http.Error(w, "Missing video file", http.StatusBadRequest)
+			return
+		}
+		if uploadLimitError(w, limits.CheckType(head)) {
+			return
+		}
+
+		id := strings.ToLower(rand.Text())
+		source := "uploads/" + id
+		hash := sha256.New()
+		size, err := store.Save(r.Context(), source, io.TeeReader(budget.Reader(buffered), hash))
+		if err != nil {
+			store.Delete(r.Context(), source)
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				err = budget.Err()
+			}
+			if uploadLimitError(w, err) {
+				return
+			}
+			log.Error("Не удалось сохранить видео", logging.KeyError, err)
+			http.Error(w, "Failed to save video", http.StatusInternalServerError)
+			return
+		}
+
+		title = strings.TrimSpace(title)
+		if utf8.RuneCountInString(title) > maxVideoTitle {
+			title = string([]rune(title)[:maxVideoTitle])
+		}
+		// Файл уже сохранён: обрыв соединения не должен оставить его без задания
+		ctx := context.WithoutCancel(r.Context())
+		job, err := pipeline.Enqueue(ctx, database.TranscodeJob{
+			Video:      "videos/" + id,
+			Source:     source,
+			Title:      title,
+			SourceSize: size,
+			Blob:       hex.EncodeToString(hash.Sum(nil)),
+			RoomID:     &room.ID,
+			CreatedBy:  user.ID,
+		})
+		if err != nil {
+			store.Delete(ctx, source)
+			log.Error("Не удалось поставить видео в очередь", logging.KeyError, err)
+			http.Error(w, "Failed to queue video", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		if err := json.NewEncoder(w).Encode(newVideoJobResponse(*job, linker, user.ID)); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
@@ -3,13 +3,25 @@ package room
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
+	"room/audit"
+	"room/auth"
+	"room/database"
+	"room/handlers/media"
+	"room/logging"
+	"room/metrics"
+	"room/ratelimit"
+	"room/tracing"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -19,9 +31,33 @@ const (
 	CommandSync        CommandType = "sync"
 	CommandError       CommandType = "error"
 	CommandVideoChange CommandType = "change-video"
+	CommandChat        CommandType = "chat"
+
+	// Команды модерации обрабатывает сервер, клиентам рассылается уведомление
+	CommandKick CommandType = "kick"
+	CommandBan  CommandType = "ban"
+	CommandMute CommandType = "mute"
+
+	// CommandRoomUpdate рассылает сервер при подключении клиента
+	// и при изменении описания, настроек или субтитров комнаты
+	CommandRoomUpdate CommandType = "room-update"
+
+	// Команды субтитров сохраняет сервер и рассылает новое состояние через room-update
+	CommandSubtitleTrack  CommandType = "subtitle-track"
+	CommandSubtitleOffset CommandType = "subtitle-offset"
+
+	// CommandTranscode рассылает сервер при изменении хода перекодирования видео, загруженного в комнату
+	CommandTranscode CommandType = "transcode"
+
+	// CommandAnnouncement рассылает администратор во все комнаты, например перед обслуживанием
+	CommandAnnouncement CommandType = "announcement"
 
 	pongWait   = 30 * time.Second
 	pingPeriod = 25 * time.Second
+	closeWait  = time.Second
+
+	// activityInterval — не чаще какого интервала записывать активность комнаты в базу
+	activityInterval = time.Minute
 )
 
 type CommandType string
@@ -32,25 +68,206 @@ type Message struct {
 	Time      float64     `json:"time,omitempty"`
 	Timestamp time.Time   `json:"timestamp"`
 	Payload   string      `json:"payload"`
+
+	// Moderation — параметры команд kick, ban и mute
+	Moderation *ModerationAction `json:"moderation,omitempty"`
+	// Room — новое описание комнаты для room-update
+	Room *database.RoomMetadata `json:"room,omitempty"`
+	// Sender — кто отправил команду; заполняет сервер
+	Sender *Sender `json:"sender,omitempty"`
+	// Transcode — состояние задания перекодирования для transcode
+	Transcode *database.TranscodeJob `json:"transcode,omitempty"`
+	// Media — подписанные для получателя ссылки на видео комнаты в sync и change-video;
+	// заполняет сервер
+	Media *media.Links `json:"media,omitempty"`
+
+	// ctx несёт спан рассылки, от которого отсчитываются спаны доставки клиентам
+	ctx context.Context
+	// dispatched — момент постановки сообщения в очереди клиентов
+	dispatched time.Time
+}
+
+func (m *Message) context() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+	return m.ctx
+}
+
+// Sender описывает отправителя команды для остальных участников.
+type Sender struct {
+	ClientID string `json:"client_id"`
+	UserID   int    `json:"user_id,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Guest    bool   `json:"guest,omitempty"`
 }
 
 type Client struct {
+	ID   string
 	Conn *websocket.Conn
 	Room *Room
 	send chan *Message
 
-	mu sync.Mutex // для защиты от повторного close
+	// UserID — пользователь клиента; 0 — анонимное подключение
+	UserID int
+	// Name и Guest — имя пользователя и признак гостя на момент подключения
+	Name  string
+	Guest bool
+	// Role — роль пользователя в комнате на момент подключения; пустая, если он не участник
+	Role   string
+	IP     string
+	roomID int
+	hub    *Hub
+
+	// logger содержит room_key и client_id, чтобы каждая строка лога клиента была привязана к ним
+	logger      *slog.Logger
+	connectedAt time.Time
+
+	// limits ограничивает частоту команд этого клиента
+	limits        *ratelimit.Commands
+	violations    int
+	maxViolations int
+
+	mu     sync.Mutex // для защиты от повторного close и доступа к mute
+	closed bool
+	// mute — действующий мьют клиента или nil
+	mute *database.Sanction
+}
+
+// setMute устанавливает или снимает (nil) мьют клиента.
+func (c *Client) setMute(mute *database.Sanction) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mute = mute
+}
+
+// muted сообщает, запрещено ли клиенту отправлять команду.
+func (c *Client) muted(command CommandType) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.mute == nil {
+		return false
+	}
+	if !c.mute.Active(time.Now()) {
+		c.mute = nil
+		return false
+	}
+	switch c.mute.Scope {
+	case database.MuteScopeAll:
+		return true
+	case database.MuteScopeChat:
+		return command == CommandChat
+	default:
+		return isControl(command)
+	}
+}
+
+// canControl сообщает, может ли клиент управлять воспроизведением.
+func (c *Client) canControl() bool {
+	if c.Role == database.RoleOwner || c.Role == database.RoleModerator {
+		return true
+	}
+	return c.Room.metadata().Settings.AnyoneCanControl
+}
+
+// isControl сообщает, управляет ли команда воспроизведением.
+func isControl(command CommandType) bool {
+	switch command {
+	case CommandPlay, CommandPause, CommandSeek, CommandSync, CommandVideoChange,
+		CommandSubtitleTrack, CommandSubtitleOffset:
+		return true
+	}
+	return false
+}
+
+// moderate выполняет команду модерации от имени клиента.
+func (c *Client) moderate(msg *Message) {
+	if msg.Moderation == nil {
+		c.sendError("missing moderation parameters")
+		return
+	}
+	if c.UserID == 0 {
+		c.sendError("forbidden")
+		return
+	}
+
+	ctx := logging.WithLogger(context.Background(), c.logger)
+	role, err := c.hub.db.GetUserRole(ctx, c.UserID, c.roomID)
+	if err != nil && !errors.Is(err, database.ErrNotMember) {
+		c.logger.Error("failed to get user role", logging.KeyError, err)
+		c.sendError("moderation failed")
+		return
+	}
+	room := &database.Room{ID: c.roomID, Key: c.Room.key}
+	who := actor{UserID: c.UserID, Role: role}
+
+	switch msg.Type {
+	case CommandKick:
+		err = c.hub.kick(ctx, room, who, *msg.Moderation)
+	case CommandBan:
+		_, err = c.hub.ban(ctx, room, who, *msg.Moderation)
+	case CommandMute:
+		_, err = c.hub.mute(ctx, room, who, *msg.Moderation)
+	}
+	if err != nil {
+		c.logger.Warn("moderation rejected", "type", msg.Type, logging.KeyError, err)
+		c.sendError(moderationReason(err))
+	}
 }
 
 func (c *Client) close() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	close(c.send)
+	_ = c.Conn.Close()
+}
+
+// trySend ставит сообщение в очередь клиента без блокировки.
+// Возвращает false, только если очередь открытого клиента переполнена.
+func (c *Client) trySend(message *Message) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return true
+	}
 	select {
-	case <-c.send:
+	case c.send <- message:
+		return true
 	default:
-		close(c.send)
+		return false
 	}
-	_ = c.Conn.Close()
+}
+
+// disconnect отправляет клиенту кадр закрытия с причиной и закрывает соединение.
+func (c *Client) disconnect(code int, reason string) {
+	deadline := time.Now().Add(closeWait)
+	_ = c.Conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline)
+	c.close()
+}
+
+// sendError отправляет клиенту сообщение об ошибке.
+func (c *Client) sendError(reason string) {
+	c.trySend(&Message{
+		Type:      CommandError,
+		Timestamp: time.Now(),
+		Payload:   reason,
+	})
+}
+
+// allow проверяет лимиты клиента и комнаты для команды.
+// Возвращает область лимита, который был превышен.
+func (c *Client) allow(command CommandType) (string, bool) {
+	if !c.limits.Allow(string(command)) {
+		return "client", false
+	}
+	if !c.Room.limits.Allow(string(command)) {
+		return "room", false
+	}
+	return "", true
 }
 
 func (c *Client) run() {
@@ -65,8 +282,12 @@ func (c *Client) receiveHandler() {
 	}()
 
 	c.Conn.SetReadDeadline(time.Now().Add(pongWait))
-	c.Conn.SetPongHandler(func(string) error {
+	c.Conn.SetPongHandler(func(appData string) error {
 		c.Conn.SetReadDeadline(time.Now().Add(pongWait))
+		// В ping отправляется время отправки, pong возвращает его обратно
+		if sent, err := strconv.ParseInt(appData, 10, 64); err == nil {
+			metrics.WSPingRTT.Observe(time.Since(time.Unix(0, sent)).Seconds())
+		}
 		return nil
 	})
 
@@ -74,29 +295,100 @@ func (c *Client) receiveHandler() {
 		_, data, err := c.Conn.ReadMessage()
 		if err != nil {
 			if !websocket.IsCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure, websocket.CloseNormalClosure) {
-				slog.Warn("read error", "error", err, "client", c.Conn.RemoteAddr())
+				c.logger.Warn("read error", logging.KeyError, err)
 			}
 			return
 		}
 
 		msg := &Message{}
 		if err := json.Unmarshal(data, msg); err != nil {
-			slog.Warn("invalid JSON", "error", err, "data", string(data))
+			c.logger.Warn("invalid JSON", logging.KeyError, err, "data", string(data))
 			continue
 		}
 
 		// Валидация типа команды
 		switch msg.Type {
-		case CommandPlay, CommandPause, CommandSeek, CommandSync, CommandVideoChange:
+		case CommandPlay, CommandPause, CommandSeek, CommandSync, CommandVideoChange, CommandChat:
 			// OK
+		case CommandKick, CommandBan, CommandMute, CommandSubtitleTrack, CommandSubtitleOffset:
+			// Обрабатываются сервером после проверки лимитов
 		default:
-			slog.Warn("unknown command type", "type", msg.Type)
+			c.logger.Warn("unknown command type", "type", msg.Type)
+			continue
+		}
+
+		metrics.WSMessages.WithLabelValues(string(msg.Type)).Inc()
+
+		if scope, ok := c.allow(msg.Type); !ok {
+			metrics.WSRateLimited.WithLabelValues(scope, string(msg.Type)).Inc()
+			// Нарушением клиента считается только превышение его собственного лимита,
+			// лимит комнаты могут исчерпать другие участники
+			if scope == "client" {
+				c.violations++
+			}
+			if c.maxViolations > 0 && c.violations >= c.maxViolations {
+				metrics.WSRateLimitDisconnects.Inc()
+				c.logger.Warn("client disconnected for repeated rate limit violations", "violations", c.violations)
+				c.disconnect(websocket.ClosePolicyViolation, "rate limit exceeded")
+				return
+			}
+			c.sendError("rate limit exceeded")
+			continue
+		}
+
+		switch msg.Type {
+		case CommandKick, CommandBan, CommandMute:
+			c.moderate(msg)
+			continue
+		}
+		if c.muted(msg.Type) {
+			c.sendError("muted")
+			continue
+		}
+		if isControl(msg.Type) && !c.canControl() {
+			c.sendError("playback control is restricted to moderators")
+			continue
+		}
+		if msg.Type == CommandSeek {
+			if duration := c.Room.duration(); duration > 0 && (msg.Time < 0 || msg.Time > duration) {
+				c.sendError("seek beyond video duration")
+				continue
+			}
+		}
+		if msg.Type == CommandVideoChange && !c.changeVideo(msg.Payload) {
+			continue
+		}
+		if msg.Type == CommandSubtitleTrack || msg.Type == CommandSubtitleOffset {
+			c.subtitles(msg)
+			c.hub.markActive(c.roomID)
 			continue
 		}
 
 		msg.From = c
+		msg.Sender = &Sender{ClientID: c.ID, UserID: c.UserID, Name: c.Name, Guest: c.Guest}
 		msg.Timestamp = time.Now()
+		msg.Moderation = nil
+		msg.Room = nil
+		msg.Transcode = nil
+		msg.Media = nil
+
+		// Спан охватывает ожидание места в очереди комнаты
+		ctx, span := tracing.Tracer().Start(context.Background(), "ws.command "+string(msg.Type),
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithTimestamp(msg.Timestamp),
+			trace.WithAttributes(
+				attribute.String(logging.KeyRoomKey, c.Room.key),
+				attribute.String(logging.KeyClientID, c.ID),
+				attribute.String("command.type", string(msg.Type)),
+				attribute.Float64("command.time", msg.Time),
+			),
+		)
+		msg.ctx = ctx
 		c.Room.message <- msg
+		span.End()
+
+		c.hub.markActive(c.roomID)
+		c.recordControl(msg)
 	}
 }
 
@@ -115,32 +407,93 @@ func (c *Client) sendHandler() {
 				return
 			}
 
-			data, err := json.Marshal(message)
-			if err != nil {
-				slog.Error("failed to marshal message", "error", err)
-				continue
-			}
-
-			c.Conn.SetWriteDeadline(time.Now().Add(pingPeriod))
-			if err := c.Conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			if err := c.deliver(message); err != nil {
 				return
 			}
 
 		case <-ticker.C:
 			c.Conn.SetWriteDeadline(time.Now().Add(pingPeriod))
-			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+			ping := []byte(strconv.FormatInt(time.Now().UnixNano(), 10))
+			if err := c.Conn.WriteMessage(websocket.PingMessage, ping); err != nil {
 				return
 			}
 		}
 	}
 }
 
+// deliver отправляет сообщение клиенту. Спан доставки начинается с момента
+// постановки сообщения в очередь клиента, чтобы было видно время ожидания.
+func (c *Client) deliver(message *Message) error {
+	startOpts := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String(logging.KeyClientID, c.ID),
+			attribute.String("command.type", string(message.Type)),
+		),
+	}
+	if !message.dispatched.IsZero() {
+		startOpts = append(startOpts, trace.WithTimestamp(message.dispatched))
+	}
+	_, span := tracing.Tracer().Start(message.context(), "ws.deliver", startOpts...)
+	defer span.End()
+	span.AddEvent("dequeued")
+
+	if message.Type == CommandSync || message.Type == CommandVideoChange {
+		message = c.withMedia(message)
+	}
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		c.logger.Error("failed to marshal message", logging.KeyError, err)
+		return nil
+	}
+
+	c.Conn.SetWriteDeadline(time.Now().Add(pingPeriod))
+	if err := c.Conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		span.RecordError(err)
+		return err
+	}
+	return nil
+}
+
+// withMedia возвращает копию сообщения с подписанными для клиента ссылками
+// на видео комнаты. Ссылки у каждого участника свои, поэтому общее сообщение не меняется.
+func (c *Client) withMedia(message *Message) *Message {
+	links := c.hub.mediaLinks(c.Room.video.Load(), c.roomID, c.UserID)
+	if links == nil {
+		return message
+	}
+	personal := *message
+	personal.Media = links
+	return &personal
+}
+
 type Room struct {
+	id         int
+	key        string
 	register   chan *Client
 	unregister chan *Client
 	message    chan *Message
 	clients    map[*Client]bool
 	mx         sync.RWMutex
+	logger     *slog.Logger
+	createdAt  time.Time
+
+	// limits ограничивает суммарную частоту команд всех клиентов комнаты
+	limits *ratelimit.Commands
+
+	// grace — сколько ждать подключений в опустевшей комнате перед её закрытием
+	grace time.Duration
+	// meta — описание и настройки комнаты из базы
+	meta atomic.Pointer[database.RoomMetadata]
+	// video — текущее видео комнаты; nil, если видео не выбрано
+	video atomic.Pointer[database.Video]
+
+	// idle — таймер закрытия опустевшей комнаты, idleSeq — номер последнего запущенного таймера
+	idle    *time.Timer
+	idleSeq uint64
+	// peak — наибольшее число клиентов, подключённых одновременно
+	peak int
 
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -148,9 +501,13 @@ type Room struct {
 	cleaned atomic.Bool
 }
 
-func NewRoom() *Room {
+func NewRoom(key string, logger *slog.Logger, limits map[string]ratelimit.Limit) *Room {
 	ctx, cancel := context.WithCancel(context.Background())
 	room := &Room{
+		key:        key,
+		logger:     logger.With(logging.KeyRoomKey, key),
+		createdAt:  time.Now(),
+		limits:     ratelimit.NewCommands(limits),
 		ctx:        ctx,
 		cancel:     cancel,
 		register:   make(chan *Client),
@@ -161,6 +518,22 @@ func NewRoom() *Room {
 	return room
 }
 
+// metadata возвращает описание и настройки комнаты.
+func (r *Room) metadata() database.RoomMetadata {
+	if meta := r.meta.Load(); meta != nil {
+		return *meta
+	}
+	return database.DefaultRoomMetadata()
+}
+
+// duration возвращает длительность текущего видео в секундах или 0, если она неизвестна.
+func (r *Room) duration() float64 {
+	if video := r.video.Load(); video != nil {
+		return video.Duration
+	}
+	return 0
+}
+
 func (r *Room) ClientCount() int {
 	r.mx.RLock()
 	defer r.mx.RUnlock()
@@ -175,20 +548,33 @@ func (r *Room) cleanup() {
 	r.mx.Lock()
 	defer r.mx.Unlock()
 
+	if r.idle != nil {
+		r.idle.Stop()
+		r.idle = nil
+	}
 	count := len(r.clients)
 	for client := range r.clients {
-		close(client.send)
-		client.Conn.Close()
+		client.close()
 	}
 	r.clients = nil
+	metrics.HubClients.Sub(float64(count))
+	if r.peak > 0 {
+		metrics.RoomPeakClients.Observe(float64(r.peak))
+	}
 
-	slog.Info("Room closed", "client_count", count)
+	r.logger.Info("Room closed", "client_count", count)
 }
 
 func (r *Room) registerClient(client *Client) {
 	r.mx.Lock()
 	defer r.mx.Unlock()
+	if r.idle != nil {
+		r.idle.Stop()
+		r.idle = nil
+	}
 	r.clients[client] = true
+	r.peak = max(r.peak, len(r.clients))
+	metrics.HubClients.Inc()
 	client.run()
 }
 
@@ -199,29 +585,66 @@ func (r *Room) unregisterClient(client *Client) {
 	if _, ok := r.clients[client]; ok {
 		delete(r.clients, client)
 		client.close()
+		metrics.HubClients.Dec()
 
 		if len(r.clients) == 0 {
-			r.cancel()
+			if r.grace <= 0 {
+				r.cancel()
+				return
+			}
+			r.idleSeq++
+			seq := r.idleSeq
+			r.idle = time.AfterFunc(r.grace, func() { r.closeIfIdle(seq) })
 		}
 	}
 }
 
+// closeIfIdle закрывает комнату, если за время ожидания никто не подключился.
+// seq отсекает таймеры, остановленные уже после срабатывания.
+func (r *Room) closeIfIdle(seq uint64) {
+	r.mx.RLock()
+	empty := len(r.clients) == 0 && r.idle != nil && r.idleSeq == seq
+	r.mx.RUnlock()
+	if empty {
+		r.logger.Info("Room idle, closing")
+		metrics.HubRoomTeardowns.WithLabelValues("idle").Inc()
+		r.cancel()
+	}
+}
+
 func (r *Room) sendMessage(message *Message) {
+	ctx, span := tracing.Tracer().Start(message.context(), "room.broadcast",
+		trace.WithAttributes(attribute.String(logging.KeyRoomKey, r.key)),
+	)
+	defer span.End()
+	message.ctx = ctx
+	message.dispatched = time.Now()
+
 	r.mx.RLock()
 	defer r.mx.RUnlock()
+	span.SetAttributes(attribute.Int("room.clients", len(r.clients)))
 
 	for client := range r.clients {
 		if client == message.From {
 			continue
 		}
-		select {
-		case client.send <- message:
-		default:
+		if !client.trySend(message) {
+			metrics.WSDroppedMessages.Inc()
+			span.AddEvent("dropped", trace.WithAttributes(attribute.String(logging.KeyClientID, client.ID)))
 			client.close()
 		}
 	}
 }
 
+// broadcast рассылает сообщение всем клиентам комнаты из-за пределов её цикла.
+// Если комната уже закрыта, сообщение отбрасывается.
+func (r *Room) broadcast(message *Message) {
+	select {
+	case r.message <- message:
+	case <-r.ctx.Done():
+	}
+}
+
 func (r *Room) Run() {
 	go func() {
 		for {
@@ -240,22 +663,299 @@ func (r *Room) Run() {
 	}()
 }
 
+// HubOptions — настройки хаба.
+type HubOptions struct {
+	// ClientLimits — лимиты частоты команд одного клиента по типам команд.
+	ClientLimits map[string]ratelimit.Limit
+	// RoomLimits — лимиты частоты команд всей комнаты по типам команд.
+	RoomLimits map[string]ratelimit.Limit
+	// MaxViolations — после скольких превышений лимита клиент отключается.
+	// 0 — не отключать.
+	MaxViolations int
+	// CheckOrigin проверяет источник запроса на подключение.
+	// nil — разрешены только подключения с того же хоста.
+	CheckOrigin func(r *http.Request) bool
+	// GracePeriod — сколько опустевшая комната ждёт новых подключений перед закрытием.
+	// 0 — закрывать сразу.
+	GracePeriod time.Duration
+	// Linker подписывает ссылки на загруженные видео для участников.
+	Linker *media.Linker
+}
+
 // Hub управляет комнатами
 type Hub struct {
 	Rooms map[string]*Room
 	mx    sync.RWMutex
+
+	db        *database.DB
+	opts      HubOptions
+	upgrader  websocket.Upgrader
+	logger    *slog.Logger
+	clientSeq atomic.Uint64
+	closed    atomic.Bool
+
+	// activity — когда активность комнаты по её ID в последний раз записана в базу
+	activity   map[int]time.Time
+	activityMx sync.Mutex
+}
+
+// NewHub создаёт пустой хаб комнат.
+func NewHub(db *database.DB, logger *slog.Logger, opts HubOptions) *Hub {
+	return &Hub{
+		Rooms:    make(map[string]*Room),
+		db:       db,
+		activity: make(map[int]time.Time),
+		opts:     opts,
+		upgrader: websocket.Upgrader{CheckOrigin: opts.CheckOrigin},
+		logger:   logger,
+	}
+}
+
+// Accepting сообщает, принимает ли хаб новые подключения.
+func (h *Hub) Accepting() bool {
+	return !h.closed.Load()
+}
+
+// Shutdown перестаёт принимать подключения и закрывает все комнаты.
+func (h *Hub) Shutdown() {
+	h.closed.Store(true)
+
+	h.mx.RLock()
+	defer h.mx.RUnlock()
+	for _, room := range h.Rooms {
+		metrics.HubRoomTeardowns.WithLabelValues("shutdown").Inc()
+		room.cancel()
+	}
+}
+
+// HasRoom сообщает, открыта ли комната в хабе.
+func (h *Hub) HasRoom(key string) bool {
+	h.mx.RLock()
+	defer h.mx.RUnlock()
+	room, ok := h.Rooms[key]
+	return ok && room.ctx.Err() == nil
+}
+
+// markActive записывает активность комнаты в базу, но не чаще activityInterval.
+func (h *Hub) markActive(roomID int) {
+	now := time.Now()
+	h.activityMx.Lock()
+	if now.Sub(h.activity[roomID]) < activityInterval {
+		h.activityMx.Unlock()
+		return
+	}
+	h.activity[roomID] = now
+	h.activityMx.Unlock()
+
+	go h.touch(roomID)
+}
+
+// touch записывает активность комнаты в базу.
+func (h *Hub) touch(roomID int) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.db.TouchRoom(logging.WithLogger(ctx, h.logger), roomID); err != nil {
+		h.logger.Warn("Failed to record room activity", logging.KeyRoomID, roomID, logging.KeyError, err)
+	}
 }
 
-func (h *Hub) getRoom(key string) *Room {
-	if key == "" {
+// clientCount возвращает число клиентов комнаты в хабе.
+func (h *Hub) clientCount(key string) int {
+	h.mx.RLock()
+	room := h.Rooms[key]
+	h.mx.RUnlock()
+	if room == nil {
+		return 0
+	}
+	return room.ClientCount()
+}
+
+// mediaLinks подписывает ссылки на загруженное видео video для пользователя userID
+// в комнате roomID. Для внешних видео ссылок нет: их адрес — имя видео.
+func (h *Hub) mediaLinks(video *database.Video, roomID, userID int) *media.Links {
+	if video == nil || video.Source != database.SourceUpload || h.opts.Linker == nil {
 		return nil
 	}
+	links := h.opts.Linker.Links(video.Name, video.Thumbnails, roomID, userID)
+	return &links
+}
 
+// UpdateRoom обновляет описание открытой комнаты и рассылает его клиентам.
+func (h *Hub) UpdateRoom(key string, meta database.RoomMetadata) {
 	h.mx.RLock()
 	room := h.Rooms[key]
 	h.mx.RUnlock()
+	if room == nil {
+		return
+	}
+	room.meta.Store(&meta)
+	room.broadcast(&Message{
+		Type:      CommandRoomUpdate,
+		Timestamp: time.Now(),
+		Room:      &meta,
+	})
+}
+
+// TranscodeUpdate рассылает состояние перекодирования в комнату, из которой загружено видео.
+func (h *Hub) TranscodeUpdate(job database.TranscodeJob) {
+	if job.RoomID == nil {
+		return
+	}
+	h.mx.RLock()
+	var room *Room
+	for _, r := range h.Rooms {
+		if r.id == *job.RoomID && r.ctx.Err() == nil {
+			room = r
+			break
+		}
+	}
+	h.mx.RUnlock()
+	if room == nil {
+		return
+	}
+	room.broadcast(&Message{
+		Type:      CommandTranscode,
+		Timestamp: time.Now(),
+		Transcode: &job,
+	})
+}
+
+// errVideoNotReady — видео ещё перекодируется или перекодировать его не удалось.
+var errVideoNotReady = errors.New("видео не готово к показу")
+
+// findVideo возвращает видео, которое пользователь userID может установить в комнату
+// roomID: внешнее или перекодированное видео из его библиотеки, загруженное в эту комнату
+// или уже установленное в ней. Возвращает database.ErrVideoNotFound или errVideoNotReady.
+func (h *Hub) findVideo(ctx context.Context, roomID, userID int, name string) (*database.Video, error) {
+	video, err := h.db.GetRoomVideo(ctx, roomID, userID, name)
+	if !errors.Is(err, database.ErrVideoNotFound) {
+		return video, err
+	}
+	job, err := h.db.GetTranscodeJob(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if job.CreatedBy != userID && (job.RoomID == nil || *job.RoomID != roomID) {
+		return nil, database.ErrVideoNotFound
+	}
+	if !job.Playable() {
+		return nil, errVideoNotReady
+	}
+	// Видео перекодировано до того, как стали сохраняться его параметры
+	return &database.Video{Name: name, Source: database.SourceUpload}, nil
+}
+
+// checkVideo проверяет, что видео из команды change-video готово к показу.
+// Возвращает видео с его параметрами или причину отказа для клиента.
+func (h *Hub) checkVideo(ctx context.Context, roomID, userID int, name string) (*database.Video, string) {
+	video, err := h.findVideo(ctx, roomID, userID, name)
+	switch {
+	case errors.Is(err, database.ErrVideoNotFound):
+		return nil, "video not found"
+	case errors.Is(err, errVideoNotReady):
+		return nil, "video is not ready"
+	case err != nil:
+		h.logger.Error("Failed to check video", "video", name, logging.KeyError, err)
+		return nil, "failed to check video"
+	}
+	return video, ""
+}
+
+// controlActions — команды управления воспроизведением, которые попадают в журнал аудита.
+// sync клиенты шлют постоянно, поэтому он не записывается.
+var controlActions = map[CommandType]string{
+	CommandPlay:  audit.ActionPlay,
+	CommandPause: audit.ActionPause,
+	CommandSeek:  audit.ActionSeek,
+}
 
-	if room != nil {
+// recordControl записывает в журнал аудита, кто и на какой позиции запустил,
+// остановил или перемотал видео.
+func (c *Client) recordControl(msg *Message) {
+	action, ok := controlActions[msg.Type]
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	audit.Record(logging.WithLogger(ctx, c.logger), c.hub.db, audit.Event{
+		Action:  action,
+		ActorID: c.UserID,
+		RoomID:  c.roomID,
+		Payload: map[string]any{"time": msg.Time, "client_id": c.ID, "name": c.Name},
+	})
+}
+
+// changeVideo проверяет и сохраняет видео из команды change-video.
+// Возвращает false, если команду не нужно рассылать.
+func (c *Client) changeVideo(name string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = logging.WithLogger(ctx, c.logger)
+
+	video, reason := c.hub.checkVideo(ctx, c.roomID, c.UserID, name)
+	if reason != "" {
+		c.sendError(reason)
+		return false
+	}
+	if err := c.hub.db.SetRoomVideo(ctx, c.roomID, name); err != nil {
+		c.logger.Error("failed to change video", logging.KeyError, err)
+		c.sendError("failed to change video")
+		return false
+	}
+	c.hub.UpdateVideo(c.Room.key, video)
+	audit.Record(ctx, c.hub.db, audit.Event{
+		Action:  audit.ActionVideoChanged,
+		ActorID: c.UserID,
+		RoomID:  c.roomID,
+		Payload: map[string]any{"video": video.Name, "source": video.Source, "client_id": c.ID, "name": c.Name},
+	})
+	return true
+}
+
+// UpdateVideo запоминает новое видео открытой комнаты. Субтитры привязаны к видео,
+// поэтому при смене видео их выбор сбрасывается и рассылается клиентам.
+func (h *Hub) UpdateVideo(key string, video *database.Video) {
+	h.mx.RLock()
+	room := h.Rooms[key]
+	h.mx.RUnlock()
+	if room == nil {
+		return
+	}
+	prev := room.video.Swap(video)
+	if prev != nil && prev.Name == video.Name {
+		return
+	}
+	if subtitles := room.metadata().Subtitles; subtitles.TrackID != nil || subtitles.Offset != 0 {
+		h.UpdateSubtitles(key, database.SubtitleState{})
+	}
+}
+
+// join регистрирует клиента в комнате. Если найденная комната успела закрыться
+// до регистрации, клиент попадает в новую.
+func (h *Hub) join(dbRoom *database.Room, client *Client) *Room {
+	for {
+		room := h.getRoom(dbRoom)
+		client.Room = room
+		select {
+		case room.register <- client:
+			return room
+		case <-room.ctx.Done():
+		}
+	}
+}
+
+// getRoom возвращает открытую комнату хаба или создаёт новую по записи dbRoom.
+// Описание и видео берутся из базы только при создании: дальше хаб сам хранит
+// их актуальными, а запись, прочитанная при подключении, может быть старше.
+func (h *Hub) getRoom(dbRoom *database.Room) *Room {
+	id, key := dbRoom.ID, dbRoom.Key
+	h.mx.RLock()
+	room := h.Rooms[key]
+	h.mx.RUnlock()
+
+	// Закрывающуюся комнату заменяем новой
+	if room != nil && room.ctx.Err() == nil {
 		return room
 	}
 
@@ -263,74 +963,161 @@ func (h *Hub) getRoom(key string) *Room {
 	defer h.mx.Unlock()
 
 	// Double-check
-	if room, exists := h.Rooms[key]; exists {
+	if room, exists := h.Rooms[key]; exists && room.ctx.Err() == nil {
 		return room
 	}
 
-	room = NewRoom()
+	room = NewRoom(key, h.logger, h.opts.RoomLimits)
+	room.id = id
+	room.grace = h.opts.GracePeriod
+	meta := dbRoom.RoomMetadata
+	room.meta.Store(&meta)
+	switch {
+	case dbRoom.VideoInfo != nil:
+		room.video.Store(dbRoom.VideoInfo)
+	case dbRoom.Video.Valid && dbRoom.Video.String != "":
+		room.video.Store(&database.Video{Name: dbRoom.Video.String, Source: database.SourceUpload})
+	}
 	h.Rooms[key] = room
+	metrics.HubRooms.Inc()
 	room.Run()
 
 	// Автоудаление из хаба при завершении
 	go func() {
 		<-room.ctx.Done()
 		h.mx.Lock()
-		delete(h.Rooms, key)
+		replaced := h.Rooms[key] != room
+		if !replaced {
+			delete(h.Rooms, key)
+		}
 		h.mx.Unlock()
-		slog.Info("Room removed from hub", "key", key)
+		metrics.HubRooms.Dec()
+
+		// Последняя активность — момент, когда комнату покинул последний клиент
+		h.activityMx.Lock()
+		delete(h.activity, id)
+		h.activityMx.Unlock()
+		h.touch(id)
+		h.logger.Info("Room removed from hub", logging.KeyRoomKey, key)
 	}()
 
 	return room
 }
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		// В продакшене ограничьте домены!
-		return true
-	},
-}
+func VideoController(hub *Hub) http.HandlerFunc {
+	db := hub.db
 
-var hub = Hub{
-	Rooms: make(map[string]*Room),
-}
-
-func VideoController() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+
 		key := r.URL.Query().Get("key")
 		if key == "" {
 			http.Error(w, "missing 'key' query parameter", http.StatusBadRequest)
 			return
 		}
+		if !hub.Accepting() {
+			http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
+			return
+		}
 
-		conn, err := upgrader.Upgrade(w, r, nil)
+		dbRoom, err := db.GetRoomByKey(r.Context(), key)
 		if err != nil {
-			slog.Error("WebSocket upgrade failed",
-				"remote_addr", r.RemoteAddr,
-				"method", r.Method,
-				"path", r.URL.Path,
-				"error", err,
-			)
-			http.Error(w, "Failed to upgrade connection", http.StatusBadRequest)
+			log.Warn("Не удалось найти комнату", logging.KeyRoomKey, key, logging.KeyError, err)
+			http.Error(w, "Room not found", http.StatusNotFound)
+			return
+		}
+		if status, ok := checkEntry(r, db, dbRoom); !ok {
+			denyEntry(w, r, dbRoom, status)
+			return
+		}
+		if dbRoom.ArchivedAt != nil {
+			http.Error(w, "Room archived", http.StatusGone)
 			return
 		}
 
-		room := hub.getRoom(key)
-		if room == nil {
-			http.Error(w, "Invalid room", http.StatusNotFound)
+		var userID int
+		var name string
+		var guest bool
+		if user, ok := auth.UserFromContext(r.Context()); ok {
+			userID = user.ID
+			name = user.Name
+			guest = user.Guest
+		}
+		ip := clientIP(r)
+		ban, err := db.FindActiveBan(r.Context(), dbRoom.ID, userID, ip)
+		if err != nil {
+			log.Error("Не удалось проверить бан", logging.KeyError, err)
+			http.Error(w, "Failed to check access", http.StatusInternalServerError)
+			return
+		}
+		if ban != nil {
+			log.Warn("Забаненный пользователь пытается подключиться", logging.KeyRoomKey, key, "sanction_id", ban.ID)
+			http.Error(w, "Banned from this room", http.StatusForbidden)
+			return
+		}
+		var mute *database.Sanction
+		var role string
+		if userID != 0 {
+			mute, err = db.FindActiveMute(r.Context(), dbRoom.ID, userID)
+			if err != nil {
+				log.Error("Не удалось проверить мьют", logging.KeyError, err)
+				http.Error(w, "Failed to check access", http.StatusInternalServerError)
+				return
+			}
+			role, err = db.GetUserRole(r.Context(), userID, dbRoom.ID)
+			if err != nil && !errors.Is(err, database.ErrNotMember) {
+				log.Error("Не удалось получить роль пользователя", logging.KeyError, err)
+				http.Error(w, "Failed to check access", http.StatusInternalServerError)
+				return
+			}
+		}
+		// Владелец и модераторы входят и в заполненную комнату
+		staff := role == database.RoleOwner || role == database.RoleModerator
+		if dbRoom.MaxParticipants > 0 && !staff && hub.clientCount(key) >= dbRoom.MaxParticipants {
+			http.Error(w, "Room is full", http.StatusForbidden)
 			return
 		}
 
+		conn, err := hub.upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			metrics.WSUpgradeFailures.Inc()
+			log.Error("WebSocket upgrade failed", logging.KeyError, err)
+			http.Error(w, "Failed to upgrade connection", http.StatusBadRequest)
+			return
+		}
+
+		id := strconv.FormatUint(hub.clientSeq.Add(1), 10)
 		client := &Client{
+			ID:   id,
 			Conn: conn,
-			Room: room,
 			send: make(chan *Message, 10),
+
+			UserID: userID,
+			Name:   name,
+			Guest:  guest,
+			Role:   role,
+			IP:     ip,
+			roomID: dbRoom.ID,
+			hub:    hub,
+			mute:   mute,
+
+			connectedAt: time.Now(),
+
+			limits:        ratelimit.NewCommands(hub.opts.ClientLimits),
+			maxViolations: hub.opts.MaxViolations,
+			// Логгер запроса уже содержит request_id и remote_addr
+			logger: log.With(logging.KeyRoomKey, key, logging.KeyClientID, id),
 		}
 
-		room.register <- client
-		slog.Info("Client connected",
-			"room_key", key,
-			"client_ip", r.RemoteAddr,
-			"total_clients", room.ClientCount(),
-		)
+		room := hub.join(dbRoom, client)
+		hub.markActive(dbRoom.ID)
+		// Новый клиент сразу получает состояние комнаты, включая выбранные субтитры
+		meta := room.metadata()
+		client.trySend(&Message{
+			Type:      CommandRoomUpdate,
+			Timestamp: time.Now(),
+			Room:      &meta,
+		})
+		client.logger.Info("Client connected", "total_clients", room.ClientCount())
 	}
 }
@@ -2,9 +2,10 @@ package user
 
 import (
 	"encoding/json"
-	"log/slog"
+	"errors"
 	"net/http"
 	"room/database"
+	"room/logging"
 )
 
 // createUserResponse — структура для ответа при успешном удалении
@@ -14,26 +15,23 @@ type createUserResponse struct {
 	User    database.User `json:"user"`
 }
 
-func CreateUser(database *database.DB) http.HandlerFunc {
+func CreateUser(db *database.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+
 		name := r.URL.Query().Get("name")
 		if name == "" {
-			slog.Error("Отсутствует обязательный параметр: name",
-				"удалённый_адрес", r.RemoteAddr,
-				"метод", r.Method,
-				"путь", r.URL.Path,
-			)
+			log.Error("Отсутствует обязательный параметр: name")
 			http.Error(w, "Missing required parameter: name", http.StatusBadRequest)
 			return
 		}
-		user, err := database.CreateUser(name)
+		user, err := db.CreateUser(r.Context(), name)
+		if errors.Is(err, database.ErrNameTaken) {
+			http.Error(w, "Name already taken", http.StatusConflict)
+			return
+		}
 		if err != nil {
-			slog.Error("Не удалось создать пользователя",
-				"error", err,
-				"удалённый_адрес", r.RemoteAddr,
-				"метод", r.Method,
-				"путь", r.URL.Path,
-			)
+			log.Error("Не удалось создать пользователя", logging.KeyError, err)
 			http.Error(w, "User not created", http.StatusInternalServerError)
 			return
 		}
@@ -48,12 +46,7 @@ func CreateUser(database *database.DB) http.HandlerFunc {
 
 		err = json.NewEncoder(w).Encode(response)
 		if err != nil {
-			slog.Error("Ошибка при отправке ответа с пользователем",
-				"error", err,
-				"удалённый_адрес", r.RemoteAddr,
-				"метод", r.Method,
-				"путь", r.URL.Path,
-			)
+			log.Error("Ошибка при отправке ответа с пользователем", logging.KeyError, err)
 			// Нельзя вызвать http.Error после начала записи в w
 			return
 		}
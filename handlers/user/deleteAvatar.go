@@ -0,0 +1,31 @@
+package user
+
+import (
+	"net/http"
+	"room/auth"
+	"room/database"
+	"room/logging"
+	"room/storage"
+)
+
+// DeleteAvatar удаляет аватар текущего пользователя.
+func DeleteAvatar(db *database.DB, store storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+		user, _ := auth.UserFromContext(r.Context())
+
+		previous, err := db.SetUserAvatar(r.Context(), user.ID, "")
+		if err != nil {
+			log.Error("Не удалось удалить аватар", logging.KeyError, err)
+			http.Error(w, "Failed to delete avatar", http.StatusInternalServerError)
+			return
+		}
+		if previous != "" {
+			if err := store.Delete(r.Context(), previous); err != nil {
+				log.Warn("Не удалось удалить файл аватара", "avatar", previous, logging.KeyError, err)
+			}
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -2,9 +2,13 @@ package user
 
 import (
 	"encoding/json"
-	"log/slog"
 	"net/http"
+	"room/audit"
+	"room/auth"
 	"room/database"
+	"room/handlers/room"
+	"room/logging"
+	"room/storage"
 	"strconv"
 )
 
@@ -15,54 +19,55 @@ type deleteUserResponse struct {
 	ID      int    `json:"id"`
 }
 
-func DeleteUser(db *database.DB) http.HandlerFunc {
+// DeleteUser удаляет текущего пользователя вместе с его комнатами и аватаром.
+// Параметр id необязателен; если он задан, он должен совпадать с текущим пользователем.
+func DeleteUser(db *database.DB, store storage.Storage, hub *room.Hub) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		idStr := r.URL.Query().Get("id")
-		if idStr == "" {
-			slog.Error("Отсутствует обязательный параметр: id",
-				"удалённый_адрес", r.RemoteAddr,
-				"метод", r.Method,
-				"путь", r.URL.Path,
-			)
-			http.Error(w, "Missing required parameter: id", http.StatusBadRequest)
-			return
-		}
+		log := logging.FromContext(r.Context())
+		user, _ := auth.UserFromContext(r.Context())
 
-		id, err := strconv.Atoi(idStr)
-		if err != nil {
-			slog.Error("Некорректное значение параметра id",
-				"значение", idStr,
-				"удалённый_адрес", r.RemoteAddr,
-				"метод", r.Method,
-				"путь", r.URL.Path,
-			)
-			http.Error(w, "Invalid id parameter", http.StatusBadRequest)
-			return
+		id := user.ID
+		if idStr := r.URL.Query().Get("id"); idStr != "" {
+			requested, err := strconv.Atoi(idStr)
+			if err != nil {
+				log.Error("Некорректное значение параметра id", "value", idStr)
+				http.Error(w, "Invalid id parameter", http.StatusBadRequest)
+				return
+			}
+			if requested != user.ID {
+				log.Warn("Попытка удалить другого пользователя", "target_user_id", requested)
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
 		}
 
-		if id <= 0 {
-			slog.Error("ID должно быть положительным числом",
-				"id", id,
-				"удалённый_адрес", r.RemoteAddr,
-				"метод", r.Method,
-				"путь", r.URL.Path,
-			)
-			http.Error(w, "Invalid id: must be positive", http.StatusBadRequest)
+		profile, err := db.GetProfile(r.Context(), id)
+		if err != nil {
+			log.Error("Не удалось получить профиль", logging.KeyError, err)
+			http.Error(w, "Failed to delete user", http.StatusInternalServerError)
 			return
 		}
 
-		err = db.DeleteUser(id)
+		deleted, err := db.DeleteUser(r.Context(), id)
 		if err != nil {
-			slog.Error("Не удалось удалить пользователя",
-				"error", err,
-				"id", id,
-				"удалённый_адрес", r.RemoteAddr,
-				"метод", r.Method,
-				"путь", r.URL.Path,
+			log.Error("Не удалось удалить пользователя",
+				logging.KeyUserID, id,
+				logging.KeyError, err,
 			)
 			http.Error(w, "Failed to delete user", http.StatusInternalServerError)
 			return
 		}
+		audit.Record(r.Context(), db, audit.Event{
+			Action:  audit.ActionUserDeleted,
+			ActorID: id,
+			Payload: map[string]any{"user_id": id, "name": profile.Name},
+		})
+		hub.CloseDeletedRooms(deleted.Rooms)
+		for _, file := range deleted.Files {
+			if err := store.Delete(r.Context(), file); err != nil {
+				log.Warn("Не удалось удалить файл пользователя", "file", file, logging.KeyError, err)
+			}
+		}
 
 		// Успешный ответ
 		response := deleteUserResponse{
@@ -74,14 +79,9 @@ func DeleteUser(db *database.DB) http.HandlerFunc {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		if err := json.NewEncoder(w).Encode(response); err != nil {
-			slog.Error("Не удалось закодировать ответ",
-				"error", err,
-				"удалённый_адрес", r.RemoteAddr,
-				"метод", r.Method,
-				"путь", r.URL.Path,
-			)
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
 			// Запись уже начата, ничего больше сделать нельзя
 			return
 		}
 	}
-}
\ No newline at end of file
+}
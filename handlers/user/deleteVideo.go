@@ -0,0 +1,50 @@
+package user
+
+import (
+	"errors"
+	"net/http"
+	"room/auth"
+	"room/database"
+	"room/logging"
+	"room/storage"
+	"strconv"
+)
+
+// DeleteVideo удаляет видео id из библиотеки текущего пользователя вместе с файлами
+// и субтитрами. Видео, установленное в комнатах, сначала нужно в них заменить.
+func DeleteVideo(db *database.DB, store storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+		user, _ := auth.UserFromContext(r.Context())
+
+		id, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil || id <= 0 {
+			http.Error(w, "Invalid id parameter", http.StatusBadRequest)
+			return
+		}
+
+		video, files, err := db.DeleteUserVideo(r.Context(), user.ID, id)
+		if errors.Is(err, database.ErrVideoNotFound) {
+			http.Error(w, "Video not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, database.ErrVideoInUse) {
+			http.Error(w, "Video is used in rooms", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Error("Не удалось удалить видео", "video_id", id, logging.KeyError, err)
+			http.Error(w, "Failed to delete video", http.StatusInternalServerError)
+			return
+		}
+
+		// Запись уже удалена, поэтому ошибки хранилища только записываются в лог
+		for _, file := range files {
+			if err := store.Delete(r.Context(), file); err != nil {
+				log.Warn("Не удалось удалить файлы видео", "video", video.Name, "file", file, logging.KeyError, err)
+			}
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,44 @@
+package user
+
+import (
+	"net/http"
+	"path"
+	"room/database"
+	"room/logging"
+	"room/storage"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetAvatar отдаёт аватар пользователя из пути /user/{id}/avatar.
+func GetAvatar(db *database.DB, store storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil || id <= 0 {
+			http.Error(w, "Invalid id parameter", http.StatusBadRequest)
+			return
+		}
+
+		profile, err := db.GetProfile(r.Context(), id)
+		if err != nil || profile.Avatar == "" {
+			http.Error(w, "Avatar not found", http.StatusNotFound)
+			return
+		}
+
+		file, err := store.Open(r.Context(), profile.Avatar)
+		if err != nil {
+			log.Error("Не удалось открыть аватар", logging.KeyUserID, id, logging.KeyError, err)
+			http.Error(w, "Avatar not found", http.StatusNotFound)
+			return
+		}
+		defer file.Close()
+
+		// Имя файла меняется при каждой загрузке, поэтому содержимое по нему неизменно
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		http.ServeContent(w, r, path.Base(profile.Avatar), time.Time{}, file)
+	}
+}
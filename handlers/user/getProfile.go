@@ -0,0 +1,57 @@
+package user
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"path"
+	"room/auth"
+	"room/database"
+	"room/logging"
+	"strconv"
+	"strings"
+)
+
+// profileResponse — профиль текущего пользователя.
+type profileResponse struct {
+	ID          int                      `json:"id"`
+	Name        string                   `json:"name"`
+	AvatarURL   string                   `json:"avatar_url,omitempty"`
+	Preferences database.UserPreferences `json:"preferences"`
+}
+
+// newProfileResponse строит ответ с профилем. Ссылка на аватар меняется вместе
+// с файлом, поэтому клиенты могут кэшировать картинку без проверки.
+func newProfileResponse(p *database.Profile, publicURL string) profileResponse {
+	response := profileResponse{
+		ID:          p.ID,
+		Name:        p.Name,
+		Preferences: p.Preferences,
+	}
+	if p.Avatar != "" {
+		response.AvatarURL = strings.TrimRight(publicURL, "/") + "/user/" + strconv.Itoa(p.ID) +
+			"/avatar?v=" + url.QueryEscape(strings.TrimSuffix(path.Base(p.Avatar), path.Ext(p.Avatar)))
+	}
+	return response
+}
+
+// GetProfile возвращает профиль текущего пользователя.
+func GetProfile(db *database.DB, publicURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+		user, _ := auth.UserFromContext(r.Context())
+
+		profile, err := db.GetProfile(r.Context(), user.ID)
+		if err != nil {
+			log.Error("Не удалось получить профиль", logging.KeyError, err)
+			http.Error(w, "Failed to get profile", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(newProfileResponse(profile, publicURL)); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package user
+
+import (
+	"encoding/json"
+	"net/http"
+	"room/auth"
+	"room/database"
+	"room/logging"
+)
+
+// GetUserRooms возвращает комнаты, в которых состоит текущий пользователь, с его ролью.
+func GetUserRooms(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+		user, _ := auth.UserFromContext(r.Context())
+
+		rooms, err := db.GetUserRooms(r.Context(), user.ID)
+		if err != nil {
+			log.Error("Не удалось получить комнаты пользователя", logging.KeyError, err)
+			http.Error(w, "Failed to get rooms", http.StatusInternalServerError)
+			return
+		}
+		if rooms == nil {
+			rooms = []database.MemberRoom{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(rooms); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package user
+
+import (
+	"encoding/json"
+	"net/http"
+	"room/auth"
+	"room/database"
+	"room/handlers/media"
+	"room/logging"
+	"time"
+)
+
+// videoResponse — видео библиотеки со ссылками для воспроизведения и превью.
+type videoResponse struct {
+	database.Video
+	URL           string `json:"url"`
+	Poster        string `json:"poster,omitempty"`
+	ThumbnailsURL string `json:"thumbnails_url,omitempty"`
+	// ExpiresAt — до какого времени действуют подписанные ссылки на загруженное видео.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// newVideoResponse подписывает ссылки на загруженное видео для его владельца userID.
+func newVideoResponse(video database.Video, linker *media.Linker, userID int) videoResponse {
+	response := videoResponse{Video: video, URL: video.Name}
+	if video.Source == database.SourceUpload {
+		links := linker.Links(video.Name, video.Thumbnails, 0, userID)
+		response.URL = links.Playlist
+		response.Poster = links.Poster
+		response.ThumbnailsURL = links.Thumbnails
+		response.ExpiresAt = &links.ExpiresAt
+	}
+	return response
+}
+
+// libraryResponse — библиотека видео пользователя и занятое ей место.
+type libraryResponse struct {
+	Videos []videoResponse `json:"videos"`
+	// Used — сколько байт хранилища занимают видео.
+	Used int64 `json:"used"`
+}
+
+// GetVideos возвращает библиотеку видео текущего пользователя.
+func GetVideos(db *database.DB, linker *media.Linker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+		user, _ := auth.UserFromContext(r.Context())
+
+		videos, err := db.GetUserVideos(r.Context(), user.ID)
+		if err != nil {
+			log.Error("Не удалось получить видео пользователя", logging.KeyError, err)
+			http.Error(w, "Failed to get videos", http.StatusInternalServerError)
+			return
+		}
+		used, err := db.GetUserVideoUsage(r.Context(), user.ID)
+		if err != nil {
+			log.Error("Не удалось подсчитать место под видео", logging.KeyError, err)
+			http.Error(w, "Failed to get videos", http.StatusInternalServerError)
+			return
+		}
+
+		response := libraryResponse{Videos: make([]videoResponse, 0, len(videos)), Used: used}
+		for _, video := range videos {
+			response.Videos = append(response.Videos, newVideoResponse(video, linker, user.ID))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
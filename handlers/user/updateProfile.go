@@ -0,0 +1,97 @@
+package user
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"room/auth"
+	"room/database"
+	"room/logging"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxNameLength — предел длины имени пользователя в символах.
+const maxNameLength = 64
+
+// updateProfileRequest — изменяемые поля профиля. Незаданные поля не меняются,
+// в preferences меняются только переданные ключи.
+type updateProfileRequest struct {
+	Name        *string         `json:"name"`
+	Preferences json.RawMessage `json:"preferences"`
+}
+
+// UpdateProfile меняет имя и настройки текущего пользователя.
+func UpdateProfile(db *database.DB, publicURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+		user, _ := auth.UserFromContext(r.Context())
+
+		var req updateProfileRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Error("Некорректное тело запроса", logging.KeyError, err)
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		profile, err := db.GetProfile(r.Context(), user.ID)
+		if err != nil {
+			log.Error("Не удалось получить профиль", logging.KeyError, err)
+			http.Error(w, "Failed to get profile", http.StatusInternalServerError)
+			return
+		}
+
+		// Сначала проверяем все поля, чтобы не сохранить часть изменений
+		prefs := profile.Preferences
+		if len(req.Preferences) > 0 {
+			if err := json.Unmarshal(req.Preferences, &prefs); err != nil {
+				http.Error(w, "Invalid preferences", http.StatusBadRequest)
+				return
+			}
+			if prefs.DefaultVolume < 0 || prefs.DefaultVolume > 1 {
+				http.Error(w, "default_volume must be between 0 and 1", http.StatusBadRequest)
+				return
+			}
+			if len(prefs.SubtitleLanguage) > 35 {
+				http.Error(w, "Invalid subtitle_language", http.StatusBadRequest)
+				return
+			}
+		}
+		var name string
+		if req.Name != nil {
+			name = strings.TrimSpace(*req.Name)
+			if name == "" || utf8.RuneCountInString(name) > maxNameLength {
+				http.Error(w, "Invalid name", http.StatusBadRequest)
+				return
+			}
+		}
+
+		if req.Name != nil {
+			err := db.UpdateUser(r.Context(), user.ID, name)
+			if errors.Is(err, database.ErrNameTaken) {
+				http.Error(w, "Name already taken", http.StatusConflict)
+				return
+			}
+			if err != nil {
+				log.Error("Не удалось изменить имя", logging.KeyError, err)
+				http.Error(w, "Failed to update name", http.StatusInternalServerError)
+				return
+			}
+			profile.Name = name
+		}
+		if len(req.Preferences) > 0 {
+			if err := db.SetUserPreferences(r.Context(), user.ID, prefs); err != nil {
+				log.Error("Не удалось сохранить настройки", logging.KeyError, err)
+				http.Error(w, "Failed to update preferences", http.StatusInternalServerError)
+				return
+			}
+			profile.Preferences = prefs
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(newProfileResponse(profile, publicURL)); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
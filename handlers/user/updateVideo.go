@@ -0,0 +1,71 @@
+package user
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"room/auth"
+	"room/database"
+	"room/handlers/media"
+	"room/logging"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxVideoTitle — наибольшая длина названия видео в символах.
+const maxVideoTitle = 200
+
+type updateVideoRequest struct {
+	Title string `json:"title"`
+}
+
+// UpdateVideo переименовывает видео id из библиотеки текущего пользователя.
+func UpdateVideo(db *database.DB, linker *media.Linker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+		user, _ := auth.UserFromContext(r.Context())
+
+		id, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil || id <= 0 {
+			http.Error(w, "Invalid id parameter", http.StatusBadRequest)
+			return
+		}
+
+		var req updateVideoRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Error("Некорректное тело запроса", logging.KeyError, err)
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		title := strings.TrimSpace(req.Title)
+		if utf8.RuneCountInString(title) > maxVideoTitle {
+			http.Error(w, "Title is too long", http.StatusBadRequest)
+			return
+		}
+
+		err = db.RenameVideo(r.Context(), user.ID, id, title)
+		if errors.Is(err, database.ErrVideoNotFound) {
+			http.Error(w, "Video not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Error("Не удалось переименовать видео", "video_id", id, logging.KeyError, err)
+			http.Error(w, "Failed to update video", http.StatusInternalServerError)
+			return
+		}
+
+		video, err := db.GetUserVideo(r.Context(), user.ID, id)
+		if err != nil {
+			log.Error("Не удалось получить видео", "video_id", id, logging.KeyError, err)
+			http.Error(w, "Failed to get video", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(newVideoResponse(*video, linker, user.ID)); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
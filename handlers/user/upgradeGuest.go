@@ -0,0 +1,70 @@
+package user
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"room/auth"
+	"room/database"
+	"room/logging"
+	"strings"
+	"unicode/utf8"
+)
+
+// upgradeGuestRequest — имя, под которым гость регистрируется.
+type upgradeGuestRequest struct {
+	Name string `json:"name"`
+}
+
+// upgradeGuestResponse — структура для ответа при успешной регистрации гостя
+type upgradeGuestResponse struct {
+	Status  string        `json:"status"`
+	Message string        `json:"message"`
+	User    database.User `json:"user"`
+}
+
+// UpgradeGuest регистрирует текущего гостя под выбранным именем. ID и токен
+// сохраняются, поэтому участие в комнатах и авторство сообщений не теряются.
+func UpgradeGuest(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+		user, _ := auth.UserFromContext(r.Context())
+
+		var req upgradeGuestRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Error("Некорректное тело запроса", logging.KeyError, err)
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		name := strings.TrimSpace(req.Name)
+		if name == "" || utf8.RuneCountInString(name) > maxNameLength {
+			http.Error(w, "Invalid name", http.StatusBadRequest)
+			return
+		}
+
+		err := db.UpgradeGuest(r.Context(), user.ID, name)
+		switch {
+		case errors.Is(err, database.ErrNameTaken):
+			http.Error(w, "Name already taken", http.StatusConflict)
+			return
+		case errors.Is(err, database.ErrNotGuest):
+			http.Error(w, "User is not a guest", http.StatusBadRequest)
+			return
+		case err != nil:
+			log.Error("Не удалось зарегистрировать гостя", logging.KeyError, err)
+			http.Error(w, "Failed to upgrade guest", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		response := upgradeGuestResponse{
+			Status:  "success",
+			Message: "Guest upgraded successfully",
+			User:    database.User{ID: user.ID, Name: name},
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
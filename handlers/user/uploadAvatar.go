@@ -0,0 +1,102 @@
+package user
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"room/auth"
+	"room/database"
+	"room/logging"
+	"room/storage"
+	"strings"
+)
+
+// maxAvatarSize — предел размера аватара в байтах.
+const maxAvatarSize = 2 << 20
+
+// avatarExtensions — разрешённые типы картинок и расширения файлов для них.
+var avatarExtensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// UploadAvatar сохраняет аватар текущего пользователя. Картинка передаётся
+// телом запроса или полем avatar формы multipart/form-data.
+func UploadAvatar(db *database.DB, store storage.Storage, publicURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := logging.FromContext(r.Context())
+		user, _ := auth.UserFromContext(r.Context())
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxAvatarSize+64<<10)
+		var body io.Reader = r.Body
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			file, _, err := r.FormFile("avatar")
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, "Avatar is too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			if err != nil {
+				http.Error(w, "Missing avatar file", http.StatusBadRequest)
+				return
+			}
+			defer file.Close()
+			body = file
+		}
+
+		// Тип определяем по содержимому, заголовкам клиента не доверяем
+		reader := bufio.NewReaderSize(io.LimitReader(body, maxAvatarSize+1), 512)
+		head, _ := reader.Peek(512)
+		ext, ok := avatarExtensions[http.DetectContentType(head)]
+		if !ok {
+			http.Error(w, "Avatar must be a PNG, JPEG, GIF or WebP image", http.StatusUnsupportedMediaType)
+			return
+		}
+
+		name := fmt.Sprintf("avatars/%d-%s%s", user.ID, strings.ToLower(rand.Text()), ext)
+		size, err := store.Save(r.Context(), name, reader)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) || size > maxAvatarSize {
+			store.Delete(r.Context(), name)
+			http.Error(w, "Avatar is too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			log.Error("Не удалось сохранить аватар", logging.KeyError, err)
+			http.Error(w, "Failed to save avatar", http.StatusInternalServerError)
+			return
+		}
+
+		previous, err := db.SetUserAvatar(r.Context(), user.ID, name)
+		if err != nil {
+			store.Delete(r.Context(), name)
+			log.Error("Не удалось сохранить аватар", logging.KeyError, err)
+			http.Error(w, "Failed to save avatar", http.StatusInternalServerError)
+			return
+		}
+		if previous != "" {
+			if err := store.Delete(r.Context(), previous); err != nil {
+				log.Warn("Не удалось удалить прежний аватар", "avatar", previous, logging.KeyError, err)
+			}
+		}
+
+		profile, err := db.GetProfile(r.Context(), user.ID)
+		if err != nil {
+			log.Error("Не удалось получить профиль", logging.KeyError, err)
+			http.Error(w, "Failed to get profile", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(newProfileResponse(profile, publicURL)); err != nil {
+			log.Error("Не удалось закодировать ответ", logging.KeyError, err)
+			return
+		}
+	}
+}
@@ -0,0 +1,225 @@
+// Package janitor архивирует или удаляет комнаты, которыми давно не пользовались,
+// и удаляет гостей с истёкшим сроком.
+package janitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"room/database"
+	"room/logging"
+	"room/metrics"
+	"room/storage"
+	"room/tracing"
+	"time"
+)
+
+// Что делать с неактивной комнатой.
+const (
+	// ActionArchive помечает комнату архивной и удаляет её видео.
+	ActionArchive = "archive"
+	// ActionDelete удаляет комнату вместе с видео.
+	ActionDelete = "delete"
+)
+
+// batchSize — сколько комнат обрабатывается за один запрос к базе.
+const batchSize = 100
+
+// LiveRooms сообщает, открыта ли комната в хабе. Открытые комнаты не трогаем,
+// даже если их активность в базе устарела.
+type LiveRooms interface {
+	HasRoom(key string) bool
+}
+
+// Options — настройки очистки.
+type Options struct {
+	// TTL — через сколько после последней активности комната считается заброшенной.
+	// 0 — комнаты не трогать.
+	TTL time.Duration
+	// Interval — как часто запускать очистку.
+	Interval time.Duration
+	// Action — ActionArchive или ActionDelete.
+	Action string
+}
+
+// Janitor периодически обрабатывает неактивные комнаты.
+type Janitor struct {
+	db     *database.DB
+	store  storage.Storage
+	live   LiveRooms
+	logger *slog.Logger
+	opts   Options
+}
+
+// New создаёт очистку. Запускается она методом Run.
+func New(db *database.DB, store storage.Storage, live LiveRooms, logger *slog.Logger, opts Options) (*Janitor, error) {
+	if opts.Action != ActionArchive && opts.Action != ActionDelete {
+		return nil, fmt.Errorf("неизвестное действие очистки '%s'", opts.Action)
+	}
+	if opts.TTL < 0 || opts.Interval <= 0 {
+		return nil, fmt.Errorf("некорректный срок неактивности или интервал очистки")
+	}
+	return &Janitor{
+		db:     db,
+		store:  store,
+		live:   live,
+		logger: logger.With("component", "janitor"),
+		opts:   opts,
+	}, nil
+}
+
+// Run выполняет очистку сразу и затем каждые Interval, пока не отменён ctx.
+func (j *Janitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := j.Sweep(ctx); err != nil {
+			j.logger.Error("Очистка комнат не удалась", logging.KeyError, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep удаляет гостей с истёкшим сроком и обрабатывает все комнаты,
+// неактивные дольше TTL. Возвращает число обработанных комнат.
+func (j *Janitor) Sweep(ctx context.Context) (int, error) {
+	start := time.Now()
+	ctx = logging.WithLogger(ctx, j.logger)
+	ctx, span := tracing.Tracer().Start(ctx, "janitor.sweep")
+	defer span.End()
+
+	guests, err := j.db.DeleteExpiredGuests(ctx, start)
+	metrics.JanitorGuests.Add(float64(guests))
+
+	count := 0
+	if err == nil && j.opts.TTL > 0 {
+		count, err = j.sweep(ctx, start.Add(-j.opts.TTL))
+	}
+
+	status := "ok"
+	if err != nil {
+		status = "error"
+		span.RecordError(err)
+	}
+	metrics.JanitorRuns.WithLabelValues(status).Inc()
+	metrics.JanitorDuration.Observe(time.Since(start).Seconds())
+	if count > 0 {
+		j.logger.Info("Очистка комнат завершена", "action", j.opts.Action, "rooms", count)
+	}
+	return count, err
+}
+
+func (j *Janitor) sweep(ctx context.Context, before time.Time) (int, error) {
+	count := 0
+	// Открытые комнаты остаются в выборке, поэтому увеличиваем лимит на их число
+	skipped := map[int]bool{}
+	for {
+		limit := batchSize + len(skipped)
+		rooms, err := j.db.GetIdleRooms(ctx, before, limit)
+		if err != nil {
+			return count, err
+		}
+
+		for _, room := range rooms {
+			if skipped[room.ID] {
+				continue
+			}
+			if j.live.HasRoom(room.Key) {
+				skipped[room.ID] = true
+				continue
+			}
+			expired, err := j.expire(ctx, room, before)
+			if err != nil {
+				return count, err
+			}
+			if expired {
+				count++
+			}
+		}
+
+		if len(rooms) < limit {
+			return count, nil
+		}
+	}
+}
+
+// expire архивирует или удаляет комнату, если она всё ещё неактивна с момента before,
+// а затем удаляет её субтитры и видео из хранилища. Загруженное видео, у которого
+// есть владелец, остаётся в его библиотеке. Возвращает false, если комната ожила.
+func (j *Janitor) expire(ctx context.Context, room database.Room, before time.Time) (bool, error) {
+	// Субтитры привязаны к видео, которое комната теряет в обоих случаях
+	var subtitles []string
+	var err error
+	switch j.opts.Action {
+	case ActionArchive:
+		subtitles, err = j.db.ArchiveIdleRoom(ctx, room.ID, before)
+	case ActionDelete:
+		subtitles, err = j.db.DeleteIdleRoom(ctx, room.ID, before)
+	}
+	if errors.Is(err, database.ErrRoomActive) {
+		j.logger.Debug("Комната ожила во время очистки", logging.KeyRoomID, room.ID)
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("комната %d: %w", room.ID, err)
+	}
+	metrics.JanitorRooms.WithLabelValues(j.opts.Action).Inc()
+
+	for _, file := range subtitles {
+		if err := j.store.Delete(ctx, file); err != nil {
+			j.logger.Warn("Не удалось удалить субтитры комнаты", logging.KeyRoomID, room.ID, "file", file, logging.KeyError, err)
+		}
+	}
+
+	if !room.Video.Valid || room.Video.String == "" {
+		return true, nil
+	}
+	// Комната уже обработана, поэтому ошибки хранилища не прерывают очистку
+	inUse, err := j.db.VideoInUse(ctx, room.Video.String)
+	if err != nil {
+		j.logger.Warn("Не удалось проверить использование видео", "video", room.Video.String, logging.KeyError, err)
+		return true, nil
+	}
+	if inUse {
+		return true, nil
+	}
+	video, err := j.db.GetVideoByName(ctx, room.Video.String)
+	if err != nil && !errors.Is(err, database.ErrVideoNotFound) {
+		j.logger.Warn("Не удалось получить видео", "video", room.Video.String, logging.KeyError, err)
+		return true, nil
+	}
+	// Внешнее видео хранится только ссылкой
+	if video != nil && video.Source != database.SourceUpload {
+		if err := j.db.DeleteVideo(ctx, room.Video.String); err != nil {
+			j.logger.Warn("Не удалось удалить внешнее видео", "video", room.Video.String, logging.KeyError, err)
+		}
+		return true, nil
+	}
+	// Загруженное видео остаётся в библиотеке владельца: комната уже не ссылается на него
+	if video != nil && video.Owner != nil {
+		return true, nil
+	}
+	// Общие файлы видео с одинаковым содержимым удаляются вместе с последним из них
+	unused, err := j.db.DeleteUploadedVideo(ctx, room.Video.String)
+	if err != nil {
+		j.logger.Warn("Не удалось удалить параметры видео", "video", room.Video.String, logging.KeyError, err)
+		return true, nil
+	}
+	if unused == "" {
+		return true, nil
+	}
+	if err := j.store.Delete(ctx, unused); err != nil {
+		j.logger.Warn("Не удалось удалить видео комнаты",
+			logging.KeyRoomID, room.ID,
+			"video", room.Video.String,
+			logging.KeyError, err,
+		)
+	}
+	return true, nil
+}
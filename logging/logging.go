@@ -0,0 +1,66 @@
+// Package logging создаёт логгер сервиса и передаёт его через context.
+//
+// Ключи атрибутов стабильны и записываются на английском, чтобы логи
+// можно было разбирать автоматически.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Стабильные ключи атрибутов.
+const (
+	KeyError      = "error"
+	KeyRequestID  = "request_id"
+	KeyRemoteAddr = "remote_addr"
+	KeyMethod     = "method"
+	KeyPath       = "path"
+	KeyStatus     = "status"
+	KeyDuration   = "duration"
+	KeyRoomKey    = "room_key"
+	KeyRoomID     = "room_id"
+	KeyClientID   = "client_id"
+	KeyUserID     = "user_id"
+)
+
+// New создаёт логгер с указанным форматом ("text" или "json") и уровнем.
+func New(w io.Writer, format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("неизвестный уровень логов '%s': %w", level, err)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	switch strings.ToLower(format) {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case "text", "":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("неизвестный формат логов '%s'", format)
+	}
+}
+
+type ctxKey struct{}
+
+// WithLogger возвращает копию ctx с привязанным логгером.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, logger)
+}
+
+// FromContextOr возвращает логгер из ctx или fallback, если его там нет.
+func FromContextOr(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if logger, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return fallback
+}
+
+// FromContext возвращает логгер из ctx или slog.Default().
+func FromContext(ctx context.Context) *slog.Logger {
+	return FromContextOr(ctx, slog.Default())
+}
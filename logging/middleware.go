@@ -0,0 +1,39 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Middleware привязывает к запросу логгер с request_id и параметрами запроса
+// и пишет строку журнала по завершении обработки.
+// Должен стоять после middleware.RequestID.
+func Middleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reqID := middleware.GetReqID(r.Context())
+			if reqID != "" {
+				w.Header().Set(middleware.RequestIDHeader, reqID)
+			}
+
+			reqLogger := logger.With(
+				KeyRequestID, reqID,
+				KeyRemoteAddr, r.RemoteAddr,
+				KeyMethod, r.Method,
+				KeyPath, r.URL.Path,
+			)
+
+			start := time.Now()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(WithLogger(r.Context(), reqLogger)))
+
+			reqLogger.Info("Запрос обработан",
+				KeyStatus, ww.Status(),
+				KeyDuration, time.Since(start),
+			)
+		})
+	}
+}
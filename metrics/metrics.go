@@ -0,0 +1,185 @@
+// Package metrics содержит метрики Prometheus для хаба, комнат, WebSocket и базы данных.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "room"
+
+var (
+	// HubRooms — количество активных комнат в хабе.
+	HubRooms = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "hub",
+		Name:      "rooms_active",
+		Help:      "Количество активных комнат в хабе.",
+	})
+
+	// HubClients — количество клиентов, подключённых ко всем комнатам хаба.
+	HubClients = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "hub",
+		Name:      "clients_connected",
+		Help:      "Количество клиентов, подключённых к комнатам хаба.",
+	})
+
+	// RoomPeakClients — наибольшее число клиентов в комнате за время её жизни в хабе.
+	// Метка по комнате дала бы неограниченное число рядов, поэтому это гистограмма.
+	RoomPeakClients = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "hub",
+		Name:      "room_peak_clients",
+		Help:      "Наибольшее число одновременно подключённых клиентов в комнате до её закрытия.",
+		Buckets:   []float64{1, 2, 3, 5, 10, 20, 50, 100, 200},
+	})
+
+	// WSMessages — сообщения, полученные от клиентов, по типу команды.
+	WSMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "ws",
+		Name:      "messages_total",
+		Help:      "Сообщения, полученные по WebSocket, по типу команды.",
+	}, []string{"type"})
+
+	// WSDroppedMessages — сообщения, не доставленные из-за переполненного буфера клиента.
+	WSDroppedMessages = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "ws",
+		Name:      "dropped_messages_total",
+		Help:      "Сообщения, отброшенные из-за переполненного буфера отправки клиента.",
+	})
+
+	// WSUpgradeFailures — неудачные попытки перехода на WebSocket.
+	WSUpgradeFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "ws",
+		Name:      "upgrade_failures_total",
+		Help:      "Неудачные попытки перехода соединения на WebSocket.",
+	})
+
+	// WSRateLimited — команды, отклонённые лимитом частоты.
+	WSRateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "ws",
+		Name:      "rate_limited_total",
+		Help:      "Команды WebSocket, отклонённые лимитом частоты, по области лимита и типу команды.",
+	}, []string{"scope", "type"})
+
+	// WSRateLimitDisconnects — клиенты, отключённые за повторные превышения лимита.
+	WSRateLimitDisconnects = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "ws",
+		Name:      "rate_limit_disconnects_total",
+		Help:      "Клиенты, отключённые за повторные превышения лимита частоты.",
+	})
+
+	// WSPingRTT — время между отправкой ping и получением pong.
+	WSPingRTT = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "ws",
+		Name:      "ping_rtt_seconds",
+		Help:      "Время между отправкой ping и получением pong.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	})
+
+	// HTTPDuration — время обработки HTTP-запросов.
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Время обработки HTTP-запросов.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// HTTPRateLimited — HTTP-запросы, отклонённые лимитом частоты.
+	HTTPRateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "rate_limited_total",
+		Help:      "HTTP-запросы, отклонённые лимитом частоты, по шаблону маршрута.",
+	}, []string{"route"})
+
+	// OriginRejected — запросы, отклонённые политикой источников.
+	OriginRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "origin_rejected_total",
+		Help:      "Запросы с неразрешённым Origin по источнику (http или websocket).",
+	}, []string{"source"})
+
+	// DBQueryDuration — время выполнения запросов к SQLite.
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "Время выполнения запросов к SQLite.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"query", "status"})
+
+	// HubRoomTeardowns — комнаты, закрытые в хабе, по причине.
+	HubRoomTeardowns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "hub",
+		Name:      "room_teardowns_total",
+		Help:      "Комнаты, закрытые в хабе, по причине (idle, shutdown, admin или deleted).",
+	}, []string{"reason"})
+
+	// JanitorRuns — проходы очистки неактивных комнат по результату.
+	JanitorRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "janitor",
+		Name:      "runs_total",
+		Help:      "Проходы очистки неактивных комнат по результату (ok или error).",
+	}, []string{"status"})
+
+	// JanitorRooms — комнаты, обработанные очисткой, по действию.
+	JanitorRooms = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "janitor",
+		Name:      "rooms_total",
+		Help:      "Неактивные комнаты, обработанные очисткой, по действию (archive или delete).",
+	}, []string{"action"})
+
+	// JanitorGuests — удалённые гости с истёкшим сроком.
+	JanitorGuests = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "janitor",
+		Name:      "guests_deleted_total",
+		Help:      "Гости, удалённые очисткой по истечении срока.",
+	})
+
+	// JanitorDuration — длительность прохода очистки.
+	JanitorDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "janitor",
+		Name:      "run_duration_seconds",
+		Help:      "Длительность прохода очистки неактивных комнат.",
+	})
+
+	// TranscodeJobs — завершённые задания перекодирования по результату.
+	TranscodeJobs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "transcode",
+		Name:      "jobs_total",
+		Help:      "Завершённые задания перекодирования по результату (done или failed).",
+	}, []string{"status"})
+
+	// TranscodeDuration — длительность задания перекодирования.
+	TranscodeDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "transcode",
+		Name:      "job_duration_seconds",
+		Help:      "Длительность задания перекодирования, включая копирование файлов.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 14),
+	})
+)
+
+// Handler возвращает обработчик для /metrics.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,303 @@
+// Package quota проверяет ограничения загрузки видео: размер файла, место,
+// занятое видео пользователя, число видео в комнате и допустимые типы файлов.
+package quota
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"room/database"
+	"slices"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrTooLarge — файл больше допустимого размера.
+	ErrTooLarge = errors.New("файл больше допустимого размера")
+	// ErrQuotaExceeded — у пользователя закончилось место под видео.
+	ErrQuotaExceeded = errors.New("превышен объём хранилища пользователя")
+	// ErrRoomFull — в комнату загружено наибольшее число видео.
+	ErrRoomFull = errors.New("достигнуто наибольшее число видео в комнате")
+	// ErrType — тип файла не разрешён.
+	ErrType = errors.New("недопустимый тип файла")
+)
+
+// Limits — ограничения по умолчанию. Администратор может изменить их
+// для отдельных пользователей и комнат.
+type Limits struct {
+	// MaxFileSize — наибольший размер одного файла в байтах.
+	MaxFileSize int64
+	// UserQuota — сколько байт могут занимать все видео пользователя; 0 — без ограничения.
+	UserQuota int64
+	// RoomVideos — сколько видео можно загрузить в комнату; 0 — без ограничения.
+	RoomVideos int
+	// AllowedTypes — допустимые MIME-типы загружаемых файлов.
+	AllowedTypes []string
+
+	// locks делает проверку и резервирование одним шагом для одновременных загрузок
+	// одного пользователя или в одну комнату; остальные загрузки не ждут друг друга.
+	locks keyLocks
+	// mu защищает reserved и pending.
+	mu sync.Mutex
+	// reserved — байты, зарезервированные идущими загрузками, по пользователям.
+	reserved map[int]int64
+	// pending — число идущих загрузок по комнатам.
+	pending map[int]int
+}
+
+// Budget — сколько байт можно загрузить и какую ошибку вернуть при превышении.
+type Budget struct {
+	Bytes   int64
+	over    error
+	release func()
+}
+
+// Err возвращает ошибку превышения бюджета: ErrTooLarge или ErrQuotaExceeded.
+func (b Budget) Err() error {
+	return b.over
+}
+
+// Release освобождает место и слот в комнате, зарезервированные Check.
+// Вызывается, когда загрузка поставлена в очередь или прервана; повторные вызовы ничего не делают.
+func (b Budget) Release() {
+	if b.release != nil {
+		b.release()
+	}
+}
+
+// Reader ограничивает r бюджетом: чтение сверх него возвращает Err.
+func (b Budget) Reader(r io.Reader) io.Reader {
+	return &limitedReader{r: r, left: b.Bytes, over: b.over}
+}
+
+type limitedReader struct {
+	r    io.Reader
+	left int64
+	over error
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.left < 0 {
+		return 0, l.over
+	}
+	// Читаем на байт больше остатка, чтобы отличить файл ровно в бюджет от большего
+	if int64(len(p)) > l.left+1 {
+		p = p[:l.left+1]
+	}
+	n, err := l.r.Read(p)
+	l.left -= int64(n)
+	if l.left < 0 {
+		return n + int(l.left), l.over
+	}
+	return n, err
+}
+
+// Check проверяет ограничения перед загрузкой видео пользователем userID в комнату roomID,
+// резервирует место и слот в комнате и возвращает, сколько байт ему можно загрузить.
+// size — заявленная длина запроса или -1, если она неизвестна. Пока загрузка не поставлена
+// в очередь, база её не учитывает, поэтому резерв держится до вызова Budget.Release.
+func (l *Limits) Check(ctx context.Context, db *database.DB, userID, roomID int, size int64) (Budget, error) {
+	unlock := l.lock(userID, roomID)
+	defer unlock()
+
+	userLimits, err := db.GetUserLimits(ctx, userID)
+	if err != nil {
+		return Budget{}, err
+	}
+	roomLimits, err := db.GetRoomLimits(ctx, roomID)
+	if err != nil {
+		return Budget{}, err
+	}
+
+	roomVideos := l.RoomVideos
+	if roomLimits.MaxVideos != nil {
+		roomVideos = *roomLimits.MaxVideos
+	}
+	if roomVideos > 0 {
+		count, err := db.CountRoomVideos(ctx, roomID)
+		if err != nil {
+			return Budget{}, err
+		}
+		if count+l.pendingIn(roomID) >= roomVideos {
+			return Budget{}, ErrRoomFull
+		}
+	}
+
+	budget := Budget{Bytes: l.MaxFileSize, over: ErrTooLarge}
+	if userLimits.MaxFileSize != nil {
+		budget.Bytes = *userLimits.MaxFileSize
+	}
+	userQuota := l.UserQuota
+	if userLimits.StorageQuota != nil {
+		userQuota = *userLimits.StorageQuota
+	}
+	if userQuota > 0 {
+		used, err := db.GetUserVideoUsage(ctx, userID)
+		if err != nil {
+			return Budget{}, err
+		}
+		if left := userQuota - used - l.reservedBy(userID); left < budget.Bytes {
+			budget = Budget{Bytes: left, over: ErrQuotaExceeded}
+		}
+		if budget.Bytes <= 0 {
+			return Budget{}, ErrQuotaExceeded
+		}
+	}
+
+	// Файл не больше заявленной длины запроса, поэтому резервировать больше не нужно
+	reserve := budget.Bytes
+	if size > 0 && size < reserve {
+		reserve = size
+	}
+	l.mu.Lock()
+	if l.reserved == nil {
+		l.reserved = make(map[int]int64)
+		l.pending = make(map[int]int)
+	}
+	l.reserved[userID] += reserve
+	l.pending[roomID]++
+	l.mu.Unlock()
+	budget.release = sync.OnceFunc(func() {
+		// Загрузка уже учтена в базе, и проверка, идущая сейчас, не должна
+		// увидеть её ни там, ни в резерве, поэтому ждём её завершения
+		unlock := l.lock(userID, roomID)
+		defer unlock()
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if l.reserved[userID] -= reserve; l.reserved[userID] <= 0 {
+			delete(l.reserved, userID)
+		}
+		if l.pending[roomID]--; l.pending[roomID] <= 0 {
+			delete(l.pending, roomID)
+		}
+	})
+	return budget, nil
+}
+
+// lock захватывает блокировки пользователя userID и комнаты roomID
+// и возвращает функцию, которая их освобождает.
+func (l *Limits) lock(userID, roomID int) func() {
+	unlockUser := l.locks.lock(fmt.Sprintf("user:%d", userID))
+	unlockRoom := l.locks.lock(fmt.Sprintf("room:%d", roomID))
+	return func() {
+		unlockRoom()
+		unlockUser()
+	}
+}
+
+// reservedBy возвращает байты, зарезервированные идущими загрузками пользователя userID.
+func (l *Limits) reservedBy(userID int) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.reserved[userID]
+}
+
+// pendingIn возвращает число идущих загрузок в комнату roomID.
+func (l *Limits) pendingIn(roomID int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.pending[roomID]
+}
+
+// keyLocks — мьютексы по ключам. Мьютекс создаётся при первом захвате
+// и удаляется, когда его никто не держит и не ждёт.
+type keyLocks struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+type keyLock struct {
+	mu sync.Mutex
+	// refs — сколько вызовов держат или ждут мьютекс.
+	refs int
+}
+
+// lock захватывает мьютекс ключа key и возвращает функцию, которая его освобождает.
+func (k *keyLocks) lock(key string) func() {
+	k.mu.Lock()
+	if k.locks == nil {
+		k.locks = make(map[string]*keyLock)
+	}
+	kl := k.locks[key]
+	if kl == nil {
+		kl = &keyLock{}
+		k.locks[key] = kl
+	}
+	kl.refs++
+	k.mu.Unlock()
+
+	kl.mu.Lock()
+	return func() {
+		kl.mu.Unlock()
+		k.mu.Lock()
+		if kl.refs--; kl.refs == 0 {
+			delete(k.locks, key)
+		}
+		k.mu.Unlock()
+	}
+}
+
+// CheckType проверяет тип файла по его началу head. Тип, указанный клиентом,
+// не учитывается: он ничего не говорит о содержимом.
+func (l *Limits) CheckType(head []byte) error {
+	for _, contentType := range detectTypes(head) {
+		if slices.Contains(l.AllowedTypes, contentType) {
+			return nil
+		}
+	}
+	return ErrType
+}
+
+// detectTypes определяет по началу файла head формат контейнера и возвращает
+// MIME-типы, под которыми он известен. Форматы, которые не различает
+// http.DetectContentType, распознаются по сигнатурам контейнеров.
+func detectTypes(head []byte) []string {
+	switch {
+	case len(head) >= 12 && string(head[4:8]) == "ftyp":
+		// Основной бренд ISO BMFF отличает QuickTime и 3GPP от MP4
+		switch brand := string(head[8:12]); {
+		case brand == "qt  ":
+			return []string{"video/quicktime"}
+		case strings.HasPrefix(brand, "3g"):
+			return []string{"video/3gpp"}
+		}
+		return []string{"video/mp4"}
+	case bytes.HasPrefix(head, []byte("\x1a\x45\xdf\xa3")):
+		// Заголовок EBML; DocType указан в его первых байтах
+		if bytes.Contains(head[:min(len(head), 64)], []byte("webm")) {
+			return []string{"video/webm"}
+		}
+		return []string{"video/x-matroska"}
+	case len(head) >= 12 && string(head[:4]) == "RIFF" && string(head[8:12]) == "AVI ":
+		return []string{"video/avi", "video/x-msvideo"}
+	case bytes.HasPrefix(head, []byte("OggS")):
+		return []string{"video/ogg"}
+	case bytes.HasPrefix(head, []byte("\x00\x00\x01\xba")), bytes.HasPrefix(head, []byte("\x00\x00\x01\xb3")):
+		return []string{"video/mpeg"}
+	case isTransportStream(head):
+		return []string{"video/mp2t"}
+	}
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	return []string{contentType}
+}
+
+// tsPacket — размер пакета транспортного потока MPEG.
+const tsPacket = 188
+
+// isTransportStream проверяет байт синхронизации в начале трёх первых пакетов транспортного потока MPEG.
+func isTransportStream(head []byte) bool {
+	if len(head) <= 2*tsPacket {
+		return false
+	}
+	for i := 0; i <= 2*tsPacket; i += tsPacket {
+		if head[i] != 0x47 {
+			return false
+		}
+	}
+	return true
+}
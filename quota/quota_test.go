@@ -0,0 +1,175 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"room/database"
+	"testing"
+	"time"
+)
+
+// transportStream возвращает начало транспортного потока MPEG из трёх пакетов.
+func transportStream() []byte {
+	head := make([]byte, 3*tsPacket)
+	for i := 0; i < len(head); i += tsPacket {
+		head[i] = 0x47
+	}
+	return head
+}
+
+func TestCheckType(t *testing.T) {
+	l := &Limits{AllowedTypes: []string{
+		"video/mp4", "video/webm", "video/x-matroska", "video/quicktime",
+		"video/x-msvideo", "video/mpeg", "video/mp2t", "video/ogg", "video/3gpp",
+	}}
+	tests := []struct {
+		name string
+		head []byte
+		ok   bool
+	}{
+		{"mp4", []byte("\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00mp42isom"), true},
+		{"quicktime", []byte("\x00\x00\x00\x14ftypqt  \x00\x00\x00\x00qt  "), true},
+		{"3gpp", []byte("\x00\x00\x00\x14ftyp3gp5\x00\x00\x00\x00"), true},
+		{"webm", []byte("\x1a\x45\xdf\xa3\x9f\x42\x86\x81\x01\x42\x82\x84webm"), true},
+		{"matroska", []byte("\x1a\x45\xdf\xa3\xa3\x42\x86\x81\x01\x42\x82\x88matroska"), true},
+		{"avi", []byte("RIFF\x00\x00\x00\x00AVI LIST"), true},
+		{"ogg", []byte("OggS\x00\x02"), true},
+		{"mpeg", []byte("\x00\x00\x01\xba\x44\x00"), true},
+		{"transport stream", transportStream(), true},
+		{"html", []byte("<!DOCTYPE html><html>"), false},
+		{"zip", []byte("PK\x03\x04"), false},
+		{"text", []byte("just some text"), false},
+		{"wave", []byte("RIFF\x00\x00\x00\x00WAVEfmt "), false},
+		{"short transport stream", transportStream()[:2*tsPacket], false},
+		{"empty", nil, false},
+	}
+	for _, tt := range tests {
+		err := l.CheckType(tt.head)
+		if tt.ok && err != nil {
+			t.Errorf("%s: %v, ожидалось nil", tt.name, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrType) {
+			t.Errorf("%s: %v, ожидалось %v", tt.name, err, ErrType)
+		}
+	}
+}
+
+func TestCheckTypeAllowList(t *testing.T) {
+	// Список задаёт администратор: не перечисленный формат отклоняется, даже если это видео
+	l := &Limits{AllowedTypes: []string{"video/webm"}}
+	if err := l.CheckType([]byte("\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00mp42isom")); !errors.Is(err, ErrType) {
+		t.Errorf("mp4 при списке только из webm: %v, ожидалось %v", err, ErrType)
+	}
+	// Оба имени AVI допустимы
+	l = &Limits{AllowedTypes: []string{"video/avi"}}
+	if err := l.CheckType([]byte("RIFF\x00\x00\x00\x00AVI LIST")); err != nil {
+		t.Errorf("avi: %v", err)
+	}
+}
+
+// newTestDB создаёт базу во временном каталоге с пользователями и комнатами.
+func newTestDB(t *testing.T, users int) (*database.DB, []int, []int) {
+	t.Helper()
+	ctx := context.Background()
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("database.New: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.CreateTable(ctx); err != nil {
+		t.Fatalf("CreateTable: %v", err)
+	}
+	var userIDs, roomIDs []int
+	for i := range users {
+		user, err := db.CreateUser(ctx, string(rune('a'+i)))
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		room, err := db.CreateRoom(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("CreateRoom: %v", err)
+		}
+		userIDs = append(userIDs, user.ID)
+		roomIDs = append(roomIDs, room.ID)
+	}
+	return db, userIDs, roomIDs
+}
+
+func TestCheckReserve(t *testing.T) {
+	ctx := context.Background()
+	db, users, rooms := newTestDB(t, 1)
+	l := &Limits{MaxFileSize: 100, UserQuota: 150, RoomVideos: 2}
+
+	first, err := l.Check(ctx, db, users[0], rooms[0], 100)
+	if err != nil {
+		t.Fatalf("первая загрузка: %v", err)
+	}
+	// Идущая загрузка занимает место и слот в комнате, пока не освобождена
+	second, err := l.Check(ctx, db, users[0], rooms[0], -1)
+	if err != nil {
+		t.Fatalf("вторая загрузка: %v", err)
+	}
+	if second.Bytes != 50 || !errors.Is(second.Err(), ErrQuotaExceeded) {
+		t.Errorf("бюджет второй загрузки %d (%v), ожидалось 50 (%v)", second.Bytes, second.Err(), ErrQuotaExceeded)
+	}
+	if _, err := l.Check(ctx, db, users[0], rooms[0], -1); !errors.Is(err, ErrRoomFull) {
+		t.Errorf("третья загрузка: %v, ожидалось %v", err, ErrRoomFull)
+	}
+
+	first.Release()
+	first.Release()
+	second.Release()
+	third, err := l.Check(ctx, db, users[0], rooms[0], -1)
+	if err != nil {
+		t.Fatalf("загрузка после освобождения: %v", err)
+	}
+	if third.Bytes != 100 || !errors.Is(third.Err(), ErrTooLarge) {
+		t.Errorf("бюджет после освобождения %d (%v), ожидалось 100 (%v)", third.Bytes, third.Err(), ErrTooLarge)
+	}
+	third.Release()
+	if len(l.reserved) != 0 || len(l.pending) != 0 || len(l.locks.locks) != 0 {
+		t.Errorf("после освобождения остались резервы %v, %v и блокировки %v", l.reserved, l.pending, l.locks.locks)
+	}
+}
+
+func TestCheckOtherUsersNotBlocked(t *testing.T) {
+	ctx := context.Background()
+	db, users, rooms := newTestDB(t, 2)
+	l := &Limits{MaxFileSize: 100, UserQuota: 150}
+
+	// Пока идёт проверка загрузки одного пользователя, загрузки других не ждут
+	unlock := l.lock(users[0], rooms[0])
+	done := make(chan error, 1)
+	go func() {
+		budget, err := l.Check(ctx, db, users[1], rooms[1], -1)
+		budget.Release()
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("загрузка другого пользователя: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("загрузка другого пользователя ждёт чужую проверку")
+	}
+
+	// Загрузка того же пользователя ждёт
+	go func() {
+		budget, err := l.Check(ctx, db, users[0], rooms[1], -1)
+		budget.Release()
+		done <- err
+	}()
+	select {
+	case <-done:
+		t.Error("загрузка того же пользователя не дождалась проверки")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	if err := <-done; err != nil {
+		t.Errorf("загрузка того же пользователя: %v", err)
+	}
+}
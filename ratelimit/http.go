@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"room/logging"
+	"room/metrics"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/time/rate"
+)
+
+// idleTimeout — через сколько забывать корзину адреса, от которого нет запросов.
+const idleTimeout = 10 * time.Minute
+
+type visitor struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// perIP хранит корзины по IP-адресам клиентов.
+type perIP struct {
+	limit    Limit
+	mu       sync.Mutex
+	visitors map[string]*visitor
+	lastGC   time.Time
+}
+
+func (p *perIP) get(ip string) *rate.Limiter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(p.lastGC) > idleTimeout {
+		for key, v := range p.visitors {
+			if now.Sub(v.lastSeen) > idleTimeout {
+				delete(p.visitors, key)
+			}
+		}
+		p.lastGC = now
+	}
+
+	v, ok := p.visitors[ip]
+	if !ok {
+		v = &visitor{limiter: p.limit.limiter()}
+		p.visitors[ip] = v
+	}
+	v.lastSeen = now
+	return v.limiter
+}
+
+// routePattern возвращает шаблон маршрута chi: сырой путь раздул бы число временных рядов.
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			return pattern
+		}
+	}
+	return "unknown"
+}
+
+// HTTP ограничивает частоту запросов с одного IP-адреса.
+// При превышении отвечает 429 с заголовком Retry-After.
+// Корзины у каждого вызова свои: маршрутам с отдельными лимитами нужны отдельные вызовы.
+func HTTP(limit Limit) func(http.Handler) http.Handler {
+	p := &perIP{
+		limit:    limit,
+		visitors: make(map[string]*visitor),
+		lastGC:   time.Now(),
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				ip = r.RemoteAddr
+			}
+
+			reservation := p.get(ip).Reserve()
+			if delay := reservation.Delay(); delay > 0 {
+				reservation.Cancel()
+				metrics.HTTPRateLimited.WithLabelValues(routePattern(r)).Inc()
+				logging.FromContext(r.Context()).Warn("Превышен лимит запросов")
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+				http.Error(w, "Too many requests", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// serve отправляет запрос с адреса remoteAddr через обработчик h и возвращает ответ.
+func serve(h http.Handler, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/room/create", nil)
+	req.RemoteAddr = remoteAddr
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHTTP(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := HTTP(Limit{Rate: 0.001, Burst: 2})(ok)
+
+	for i := range 2 {
+		if rec := serve(h, "192.0.2.1:1000"); rec.Code != http.StatusOK {
+			t.Fatalf("запрос %d: статус %d, ожидался 200", i+1, rec.Code)
+		}
+	}
+	rec := serve(h, "192.0.2.1:1001")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("статус %d, ожидался 429", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("нет заголовка Retry-After")
+	}
+
+	// У другого адреса своя корзина
+	if rec := serve(h, "192.0.2.2:1000"); rec.Code != http.StatusOK {
+		t.Errorf("статус %d для другого адреса, ожидался 200", rec.Code)
+	}
+}
+
+func TestHTTPSeparateRoutes(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	limit := Limit{Rate: 0.001, Burst: 1}
+	createRoom := HTTP(limit)(ok)
+	createUser := HTTP(limit)(ok)
+
+	if rec := serve(createRoom, "192.0.2.1:1000"); rec.Code != http.StatusOK {
+		t.Fatalf("статус %d, ожидался 200", rec.Code)
+	}
+	if rec := serve(createRoom, "192.0.2.1:1000"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("статус %d, ожидался 429", rec.Code)
+	}
+	// Исчерпанная корзина одного маршрута не ограничивает другой
+	if rec := serve(createUser, "192.0.2.1:1000"); rec.Code != http.StatusOK {
+		t.Errorf("статус %d на другом маршруте, ожидался 200", rec.Code)
+	}
+}
@@ -0,0 +1,96 @@
+// Package ratelimit ограничивает частоту команд WebSocket и HTTP-запросов
+// по алгоритму token bucket.
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/time/rate"
+)
+
+// DefaultKey — ключ лимита для команд, у которых нет собственного лимита.
+const DefaultKey = "default"
+
+// Limit — скорость пополнения (токенов в секунду) и размер корзины.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// ParseLimit разбирает лимит в формате "rate:burst", например "5:10".
+func ParseLimit(s string) (Limit, error) {
+	rateStr, burstStr, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok {
+		return Limit{}, fmt.Errorf("лимит '%s' должен быть в формате rate:burst", s)
+	}
+	r, err := strconv.ParseFloat(rateStr, 64)
+	if err != nil || r <= 0 {
+		return Limit{}, fmt.Errorf("некорректная скорость в лимите '%s'", s)
+	}
+	burst, err := strconv.Atoi(burstStr)
+	if err != nil || burst <= 0 {
+		return Limit{}, fmt.Errorf("некорректный размер корзины в лимите '%s'", s)
+	}
+	return Limit{Rate: r, Burst: burst}, nil
+}
+
+// ParseLimits разбирает набор лимитов по командам в формате
+// "default=10:20,seek=2:5". Лимит default применяется к остальным командам.
+func ParseLimits(s string) (map[string]Limit, error) {
+	limits := make(map[string]Limit)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("лимит '%s' должен быть в формате command=rate:burst", part)
+		}
+		limit, err := ParseLimit(value)
+		if err != nil {
+			return nil, err
+		}
+		limits[strings.TrimSpace(name)] = limit
+	}
+	return limits, nil
+}
+
+func (l Limit) limiter() *rate.Limiter {
+	return rate.NewLimiter(rate.Limit(l.Rate), l.Burst)
+}
+
+// Commands — набор корзин по типам команд.
+// Безопасен для одновременного использования.
+type Commands struct {
+	byCommand map[string]*rate.Limiter
+	fallback  *rate.Limiter
+}
+
+// NewCommands создаёт корзины для каждого лимита из limits.
+// Команды без собственного лимита делят общую корзину DefaultKey;
+// если и её нет, они не ограничиваются.
+func NewCommands(limits map[string]Limit) *Commands {
+	c := &Commands{byCommand: make(map[string]*rate.Limiter, len(limits))}
+	for name, limit := range limits {
+		if name == DefaultKey {
+			c.fallback = limit.limiter()
+			continue
+		}
+		c.byCommand[name] = limit.limiter()
+	}
+	return c
+}
+
+// Allow расходует токен для команды и сообщает, разрешена ли она.
+func (c *Commands) Allow(command string) bool {
+	if limiter, ok := c.byCommand[command]; ok {
+		return limiter.Allow()
+	}
+	if c.fallback != nil {
+		return c.fallback.Allow()
+	}
+	return true
+}
@@ -0,0 +1,120 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Limit
+		wantErr bool
+	}{
+		{in: "5:10", want: Limit{Rate: 5, Burst: 10}},
+		{in: " 0.2:5 ", want: Limit{Rate: 0.2, Burst: 5}},
+		{in: "5", wantErr: true},
+		{in: "x:10", wantErr: true},
+		{in: "0:10", wantErr: true},
+		{in: "-1:10", wantErr: true},
+		{in: "5:0", wantErr: true},
+		{in: "5:1.5", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseLimit(%q) = %+v, ожидалась ошибка", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseLimit(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseLimit(%q) = %+v, ожидалось %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	got, err := ParseLimits("default=5:10, seek=2:5,,")
+	if err != nil {
+		t.Fatalf("ParseLimits: %v", err)
+	}
+	want := map[string]Limit{
+		DefaultKey: {Rate: 5, Burst: 10},
+		"seek":     {Rate: 2, Burst: 5},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ParseLimits = %+v, ожидалось %+v", got, want)
+	}
+	for name, limit := range want {
+		if got[name] != limit {
+			t.Errorf("лимит %s = %+v, ожидалось %+v", name, got[name], limit)
+		}
+	}
+
+	for _, in := range []string{"seek", "seek=2", "seek=a:5"} {
+		if _, err := ParseLimits(in); err == nil {
+			t.Errorf("ParseLimits(%q): ожидалась ошибка", in)
+		}
+	}
+}
+
+// allowed возвращает, сколько команд подряд пропустила корзина из n попыток.
+func allowed(c *Commands, command string, n int) int {
+	count := 0
+	for range n {
+		if c.Allow(command) {
+			count++
+		}
+	}
+	return count
+}
+
+func TestCommandsBurst(t *testing.T) {
+	// Скорость пополнения мала, поэтому за время теста корзина не наполняется
+	c := NewCommands(map[string]Limit{"seek": {Rate: 0.001, Burst: 3}})
+	if got := allowed(c, "seek", 10); got != 3 {
+		t.Errorf("пропущено %d команд seek, ожидалось 3", got)
+	}
+}
+
+func TestCommandsSeparateBuckets(t *testing.T) {
+	c := NewCommands(map[string]Limit{
+		DefaultKey: {Rate: 0.001, Burst: 2},
+		"seek":     {Rate: 0.001, Burst: 1},
+	})
+	if got := allowed(c, "seek", 5); got != 1 {
+		t.Errorf("пропущено %d команд seek, ожидалось 1", got)
+	}
+	// Исчерпанная корзина seek не трогает общую
+	if got := allowed(c, "play", 1); got != 1 {
+		t.Errorf("пропущено %d команд play, ожидалось 1", got)
+	}
+	// Команды без собственного лимита делят общую корзину
+	if got := allowed(c, "pause", 5); got != 1 {
+		t.Errorf("пропущено %d команд pause, ожидалось 1", got)
+	}
+}
+
+func TestCommandsWithoutDefault(t *testing.T) {
+	c := NewCommands(map[string]Limit{"seek": {Rate: 0.001, Burst: 1}})
+	if got := allowed(c, "play", 100); got != 100 {
+		t.Errorf("пропущено %d команд play, ожидалось 100: без лимита default команды не ограничиваются", got)
+	}
+}
+
+func TestCommandsRefill(t *testing.T) {
+	c := NewCommands(map[string]Limit{"seek": {Rate: 1000, Burst: 1}})
+	if !c.Allow("seek") {
+		t.Fatal("первая команда отклонена")
+	}
+	// Токен пополняется за миллисекунду; ждём с запасом
+	time.Sleep(20 * time.Millisecond)
+	if !c.Allow("seek") {
+		t.Error("корзина не пополнилась")
+	}
+}
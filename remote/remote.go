@@ -0,0 +1,202 @@
+// Package remote проверяет внешние видео, заданные ссылкой: прямые файлы и манифесты HLS.
+// Запросы к внутренним адресам запрещены, чтобы ссылкой нельзя было заставить
+// сервис обращаться к его собственной сети.
+package remote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// maxRedirects — сколько перенаправлений проходить при проверке ссылки.
+const maxRedirects = 5
+
+// manifestPeek — сколько байт манифеста читать, чтобы убедиться, что это HLS.
+const manifestPeek = 1024
+
+var (
+	// ErrInvalidURL — ссылка не является абсолютной ссылкой http(s).
+	ErrInvalidURL = errors.New("некорректная ссылка")
+	// ErrForbiddenAddress — ссылка ведёт на внутренний адрес.
+	ErrForbiddenAddress = errors.New("ссылка ведёт на внутренний адрес")
+	// ErrUnsupportedType — по ссылке не видео и не манифест HLS.
+	ErrUnsupportedType = errors.New("неподдерживаемый тип содержимого")
+	// ErrUnreachable — ссылка не открывается.
+	ErrUnreachable = errors.New("ссылка недоступна")
+)
+
+// fileTypes — типы файлов, которые браузеры воспроизводят сами.
+var fileTypes = map[string]bool{
+	"video/mp4":       true,
+	"video/webm":      true,
+	"video/ogg":       true,
+	"application/ogg": true,
+}
+
+// manifestTypes — типы манифестов HLS.
+var manifestTypes = map[string]bool{
+	"application/vnd.apple.mpegurl": true,
+	"application/x-mpegurl":         true,
+	"audio/mpegurl":                 true,
+	"audio/x-mpegurl":               true,
+}
+
+// Source — проверенное внешнее видео.
+type Source struct {
+	URL string
+	// HLS сообщает, что ссылка ведёт на манифест HLS, а не на файл.
+	HLS bool
+	// ContentType — тип содержимого из ответа сервера без параметров.
+	ContentType string
+}
+
+// Validator проверяет ссылки на внешние видео.
+type Validator struct {
+	client *http.Client
+}
+
+// NewValidator создаёт проверку ссылок с таймаутом timeout на каждую.
+// allowPrivate разрешает внутренние адреса, например для локальной разработки.
+func NewValidator(timeout time.Duration, allowPrivate bool) *Validator {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		// Адрес проверяется после разрешения имени, поэтому подмена DNS
+		// между проверкой и подключением не помогает
+		dialer.Control = checkAddress
+	}
+	transport := &http.Transport{
+		// Прокси из окружения обошёл бы проверку адреса
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+	}
+	return &Validator{client: &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("слишком много перенаправлений")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrInvalidURL
+			}
+			return nil
+		},
+	}}
+}
+
+// checkAddress запрещает подключения к внутренним адресам.
+func checkAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !public(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, ip)
+	}
+	return nil
+}
+
+// sharedAddressSpace — адреса операторского NAT (RFC 6598).
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// public сообщает, является ли адрес публичным.
+func public(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip)
+}
+
+// Check проверяет, что по ссылке raw доступно видео, которое браузер сможет воспроизвести.
+func (v *Validator) Check(ctx context.Context, raw string) (*Source, error) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil {
+		return nil, ErrInvalidURL
+	}
+	u.Fragment = ""
+	source := &Source{URL: u.String()}
+
+	resp, err := v.request(ctx, http.MethodHead, source.URL)
+	if err == nil && resp.StatusCode >= 400 {
+		// Не все серверы отвечают на HEAD
+		resp.Body.Close()
+		resp, err = v.request(ctx, http.MethodGet, source.URL)
+	}
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("%w: статус %d", ErrUnreachable, resp.StatusCode)
+	}
+
+	source.ContentType, _, _ = mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	source.ContentType = strings.ToLower(source.ContentType)
+	switch {
+	case fileTypes[source.ContentType]:
+		return source, nil
+	case manifestTypes[source.ContentType] || strings.EqualFold(path.Ext(u.Path), ".m3u8"):
+		// Манифесты часто отдаются как text/plain, поэтому смотрим на содержимое
+		if err := v.checkManifest(ctx, source.URL); err != nil {
+			return nil, err
+		}
+		source.HLS = true
+		return source, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnsupportedType, source.ContentType)
+}
+
+// checkManifest проверяет, что файл по ссылке начинается как манифест HLS.
+func (v *Validator) checkManifest(ctx context.Context, rawURL string) error {
+	resp, err := v.request(ctx, http.MethodGet, rawURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("%w: статус %d", ErrUnreachable, resp.StatusCode)
+	}
+	head, err := io.ReadAll(io.LimitReader(resp.Body, manifestPeek))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUnreachable, err)
+	}
+	// Манифест может начинаться с метки порядка байтов UTF-8
+	line, _, _ := bytes.Cut(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf")), []byte("\n"))
+	if strings.TrimSpace(string(line)) != "#EXTM3U" {
+		return fmt.Errorf("%w: файл не является манифестом HLS", ErrUnsupportedType)
+	}
+	return nil
+}
+
+// request выполняет запрос к ссылке. Ошибки подключения оборачиваются в ErrUnreachable,
+// кроме запрета внутреннего адреса.
+func (v *Validator) request(ctx context.Context, method, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, ErrInvalidURL
+	}
+	resp, err := v.client.Do(req)
+	switch {
+	case errors.Is(err, ErrForbiddenAddress), errors.Is(err, ErrInvalidURL):
+		return nil, err
+	case err != nil:
+		return nil, fmt.Errorf("%w: %w", ErrUnreachable, err)
+	}
+	return resp, nil
+}
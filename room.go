@@ -1,15 +1,40 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"room/admincli"
+	"room/auth"
+	"room/config"
+	"room/cors"
 	"room/database"
+	"room/handlers/admin"
+	"room/handlers/health"
+	"room/handlers/invite"
+	"room/handlers/media"
 	"room/handlers/room"
 	"room/handlers/user"
+	"room/janitor"
+	"room/logging"
+	"room/metrics"
+	"room/quota"
+	"room/ratelimit"
+	"room/remote"
+	"room/signing"
+	"room/storage"
+	"room/tracing"
+	"room/transcode"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
 )
 
 // 1. Создание комнаты
@@ -17,32 +42,227 @@ import (
 // 3. Синхронизация видео
 
 func main() {
-	sqllite, err := database.New("./sqlite.db")
+	cfg, err := config.Load()
 	if err != nil {
-		fmt.Printf(fmt.Errorf("база данных не открылась: %w", err).Error())
+		fmt.Println(fmt.Errorf("некорректные настройки: %w", err))
 		return
 	}
-	err = sqllite.CreateTable()
+
+	// Подкоманда admin обращается к административному API запущенного сервиса
+	if len(os.Args) > 1 && os.Args[1] == "admin" {
+		opts := admincli.Options{URL: cfg.AdminURL, Token: cfg.AdminToken}
+		os.Exit(admincli.Run(context.Background(), opts, os.Args[2:], os.Stdout, os.Stderr))
+	}
+
+	logger, err := logging.New(os.Stdout, cfg.LogFormat, cfg.LogLevel)
+	if err != nil {
+		fmt.Println(fmt.Errorf("логгер не создан: %w", err))
+		return
+	}
+	// Сторонние пакеты, пишущие через slog по умолчанию, используют тот же логгер
+	slog.SetDefault(logger)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:    cfg.TracingExporter,
+		ServiceName: cfg.ServiceName,
+		Endpoint:    cfg.TracingEndpoint,
+		Insecure:    cfg.TracingInsecure,
+		SampleRatio: cfg.TracingSampleRatio,
+		Writer:      os.Stdout,
+	})
+	if err != nil {
+		logger.Error("Трассировка не настроена", logging.KeyError, err)
+		return
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Error("Не удалось выгрузить спаны", logging.KeyError, err)
+		}
+	}()
+
+	sqllite, err := database.New(cfg.DBPath, logger)
+	if err != nil {
+		logger.Error("База данных не открылась", logging.KeyError, err)
+		return
+	}
+	err = sqllite.CreateTable(context.Background())
+	if err != nil {
+		logger.Error("База данных не создалась", logging.KeyError, err)
+		return
+	}
+
+	store, err := storage.NewLocal(cfg.MediaDir)
+	if err != nil {
+		logger.Error("Хранилище недоступно", logging.KeyError, err)
+		return
+	}
+
+	originPolicy := &cors.Policy{
+		AllowedOrigins:   cfg.AllowedOrigins,
+		AllowCredentials: cfg.CORSAllowCredentials,
+		AllowedMethods:   []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions},
+		AllowedHeaders:   []string{"Authorization", "Content-Type", "X-Room-Password", chimiddleware.RequestIDHeader},
+		MaxAge:           cfg.CORSMaxAge,
+	}
+
+	signingKeys := cfg.MediaSigningKeys
+	if len(signingKeys) == 0 {
+		logger.Warn("MEDIA_SIGNING_KEYS не задан: ссылки на медиафайлы перестанут действовать после перезапуска")
+		signingKeys = []signing.Key{signing.RandomKey()}
+	}
+	signer, err := signing.New(signingKeys, cfg.MediaURLTTL)
+	if err != nil {
+		logger.Error("Подпись ссылок не настроена", logging.KeyError, err)
+		return
+	}
+	linker := media.NewLinker(signer, cfg.PublicURL)
+
+	hub := room.NewHub(sqllite, logger, room.HubOptions{
+		ClientLimits:  cfg.WSClientLimits,
+		RoomLimits:    cfg.WSRoomLimits,
+		MaxViolations: cfg.WSMaxViolations,
+		CheckOrigin:   originPolicy.CheckOrigin,
+		GracePeriod:   cfg.RoomGracePeriod,
+		Linker:        linker,
+	})
+	uploadLimits := &quota.Limits{
+		MaxFileSize:  cfg.VideoMaxSize,
+		UserQuota:    cfg.UserStorageQuota,
+		RoomVideos:   cfg.RoomMaxVideos,
+		AllowedTypes: cfg.VideoAllowedTypes,
+	}
+
+	ffmpeg := transcode.NewFFmpeg(cfg.FFmpegPath)
+	pipeline, err := transcode.New(sqllite, store, ffmpeg, transcode.NewFFprobe(cfg.FFprobePath), ffmpeg, hub, logger, transcode.Options{
+		Workers:           cfg.TranscodeWorkers,
+		TempDir:           cfg.TranscodeTempDir,
+		ThumbnailInterval: cfg.ThumbnailInterval,
+		ThumbnailWidth:    cfg.ThumbnailWidth,
+	})
 	if err != nil {
-		fmt.Printf(fmt.Errorf("база данных не создалась: %w", err).Error())
+		logger.Error("Перекодирование не настроено", logging.KeyError, err)
 		return
 	}
 
 	router := chi.NewRouter()
-	router.Use(middleware.Recoverer)                 // Восстановление после паники
-	router.Use(middleware.Timeout(30 * time.Second)) // Таймаут на обработку
+	router.Use(chimiddleware.RequestID)    // Идентификатор запроса
+	router.Use(tracing.Middleware)         // Спан на каждый запрос
+	router.Use(logging.Middleware(logger)) // Логгер запроса в контексте
+	router.Use(middleware.Recoverer)       // Восстановление после паники
+	router.Use(metrics.Middleware)         // Метрики времени обработки
+	router.Use(originPolicy.Middleware)    // Политика источников и CORS
+
+	// Таймаут на обработку. Загрузки видео и субтитров идут без него: передача
+	// большого файла дольше таймаута, а сохранение и постановка в очередь не должны обрываться
+	timeout := middleware.Timeout(30 * time.Second)
+
+	router.Group(func(r chi.Router) {
+		r.Use(timeout)
+		r.With(auth.Admin(cfg.AdminToken)).Handle("/metrics", metrics.Handler())
+		r.Get("/healthz", health.Healthz())
+		r.Get("/readyz", health.Readyz(sqllite, hub, store))
+		r.Route("/debug", func(r chi.Router) {
+			r.Use(auth.Admin(cfg.AdminToken))
+			r.Get("/hub", health.DebugHub(hub))
+			r.Mount("/", chimiddleware.Profiler())
+		})
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(auth.Admin(cfg.AdminToken))
+			r.Put("/user/limits", admin.SetUserLimits(sqllite))
+			r.Put("/room/limits", admin.SetRoomLimits(sqllite))
+			r.Get("/rooms", admin.ListRooms(sqllite, hub))
+			r.Get("/room", admin.GetRoom(sqllite, hub))
+			r.Post("/room/close", admin.CloseRoom(sqllite, hub))
+			r.Post("/announce", admin.Announce(sqllite, hub))
+			r.Delete("/user", admin.DeleteUser(sqllite, store, hub))
+			r.Post("/memberships/purge", admin.PurgeMemberships(sqllite))
+			r.Get("/storage", admin.GetStorageUsage(sqllite))
+			r.Get("/audit", admin.GetAudit(sqllite))
+		})
 
+		r.Get("/rooms", room.ListRooms(hub))
+		r.Get("/media/*", media.ServeVideo(sqllite, store, signer))
+		r.With(auth.User(sqllite), ratelimit.HTTP(cfg.HTTPCreateLimit)).Post("/invite/{token}", invite.RedeemInvite(sqllite, cfg.GuestTTL))
+		r.Route("/user", func(r chi.Router) {
+			r.Use(auth.User(sqllite))
+			r.With(ratelimit.HTTP(cfg.HTTPCreateLimit)).Get("/create", user.CreateUser(sqllite))
+			r.Get("/{id}/avatar", user.GetAvatar(sqllite, store))
+			r.Group(func(r chi.Router) {
+				r.Use(auth.RequireUser)
+				r.Get("/", user.GetProfile(sqllite, cfg.PublicURL))
+				r.Patch("/", user.UpdateProfile(sqllite, cfg.PublicURL))
+				r.Delete("/", user.DeleteUser(sqllite, store, hub))
+				r.Put("/avatar", user.UploadAvatar(sqllite, store, cfg.PublicURL))
+				r.Delete("/avatar", user.DeleteAvatar(sqllite, store))
+				r.Get("/rooms", user.GetUserRooms(sqllite))
+				r.Post("/upgrade", user.UpgradeGuest(sqllite))
+				r.Get("/videos", user.GetVideos(sqllite, linker))
+				r.Patch("/video", user.UpdateVideo(sqllite, linker))
+				r.Delete("/video", user.DeleteVideo(sqllite, store))
+			})
+		})
+	})
 	router.Route("/room", func(r chi.Router) {
-		r.Get("/", room.GetRoom(sqllite))
-		r.Get("/create", room.CreateRoom(sqllite))
-		r.Get("/setVideo", room.SetVideo(sqllite))
-		r.Get("/ws", room.VideoController())
+		r.Use(auth.User(sqllite))
+		r.Post("/video", room.UploadVideo(sqllite, store, pipeline, uploadLimits, linker))
+		r.Post("/subtitles", room.UploadSubtitles(sqllite, store, cfg.PublicURL))
+		r.Group(func(r chi.Router) {
+			r.Use(timeout)
+			r.Get("/", room.GetRoom(sqllite))
+			r.Patch("/", room.UpdateRoom(hub))
+			r.With(ratelimit.HTTP(cfg.HTTPCreateLimit)).Get("/create", room.CreateRoom(sqllite))
+			r.With(ratelimit.HTTP(cfg.HTTPCreateLimit)).Post("/guest", room.CreateGuest(sqllite, cfg.GuestTTL))
+			r.Get("/setVideo", room.SetVideo(hub))
+			r.Get("/video", room.GetVideoJob(sqllite, linker))
+			r.Get("/media", room.GetMedia(hub))
+			r.Post("/video/attach", room.AttachVideo(hub))
+			r.Post("/video/url", room.SetVideoURL(hub, remote.NewValidator(cfg.VideoURLTimeout, cfg.VideoURLAllowPrivate)))
+			r.Get("/ws", room.VideoController(hub))
+			r.Post("/invite", room.CreateInvite(sqllite, cfg.PublicURL, cfg.InviteTTL))
+			r.Get("/invites", room.GetInvites(sqllite))
+			r.Delete("/invite", room.RevokeInvite(sqllite))
+			r.Post("/access", room.SetAccess(sqllite))
+			r.Post("/kick", room.Kick(hub))
+			r.Post("/ban", room.Ban(hub))
+			r.Post("/mute", room.Mute(hub))
+			r.Get("/sanctions", room.GetSanctions(sqllite))
+			r.Get("/audit", room.GetAudit(sqllite))
+			r.Delete("/sanction", room.LiftSanction(hub))
+			r.Get("/subtitles", room.GetSubtitles(sqllite, cfg.PublicURL))
+			r.Get("/subtitle", room.GetSubtitleTrack(sqllite, store))
+			r.Delete("/subtitle", room.DeleteSubtitleTrack(hub, store))
+		})
 	})
-	router.Route("/user", func(r chi.Router) {
-		r.Get("/create", user.CreateUser(sqllite))
-		r.Get("/delete", user.DeleteUser(sqllite))
+
+	server := &http.Server{Addr: cfg.Addr, Handler: router}
+
+	// Останавливаемся по сигналу, чтобы успеть выгрузить спаны
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	roomJanitor, err := janitor.New(sqllite, store, hub, logger, janitor.Options{
+		TTL:      cfg.RoomTTL,
+		Interval: cfg.JanitorInterval,
+		Action:   cfg.RoomExpiryAction,
 	})
+	if err != nil {
+		logger.Error("Очистка комнат не настроена", logging.KeyError, err)
+		return
+	}
+	go roomJanitor.Run(ctx)
+	go pipeline.Run(ctx)
+	go func() {
+		<-ctx.Done()
+		hub.Shutdown()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
 
-	fmt.Println("Сервер запущен на http://localhost:3000")
-	http.ListenAndServe(":3000", router)
+	logger.Info("Сервер запущен", "addr", cfg.Addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("Сервер остановлен", logging.KeyError, err)
+	}
 }
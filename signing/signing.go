@@ -0,0 +1,167 @@
+// Package signing подписывает ссылки на медиафайлы HMAC-SHA256. Подпись
+// привязывает ссылку к видео, комнате, пользователю и сроку действия.
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ошибки проверки подписи.
+var (
+	ErrInvalid = errors.New("подпись ссылки неверна")
+	ErrExpired = errors.New("срок действия ссылки истёк")
+)
+
+// minSecretLength — наименьшая длина секрета ключа в байтах.
+const minSecretLength = 16
+
+// keyID — допустимый идентификатор ключа: он входит в путь ссылки.
+var keyID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// Key — ключ подписи. ID попадает в ссылку, чтобы при проверке выбрать ключ.
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// ParseKeys разбирает ключи вида id:secret, разделённые запятыми.
+func ParseKeys(s string) ([]Key, error) {
+	var keys []Key
+	seen := map[string]bool{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		id, secret, ok := strings.Cut(item, ":")
+		if !ok || !keyID.MatchString(id) {
+			return nil, fmt.Errorf("ключ %q: ожидается id:secret, id из латинских букв, цифр, _ и -", id)
+		}
+		if len(secret) < minSecretLength {
+			return nil, fmt.Errorf("ключ %q: секрет короче %d байт", id, minSecretLength)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("ключ %q указан дважды", id)
+		}
+		seen[id] = true
+		keys = append(keys, Key{ID: id, Secret: []byte(secret)})
+	}
+	return keys, nil
+}
+
+// RandomKey создаёт случайный ключ. Ссылки, подписанные им, перестают
+// действовать после перезапуска сервиса.
+func RandomKey() Key {
+	return Key{ID: "ephemeral", Secret: []byte(rand.Text())}
+}
+
+// Claims — на что выдана ссылка.
+type Claims struct {
+	// Video — имя видео, к файлам которого даёт доступ ссылка.
+	Video string
+	// RoomID — комната, в которой выдана ссылка; 0 — библиотека пользователя.
+	RoomID int
+	// UserID — пользователь, которому выдана ссылка; 0 — анонимный участник.
+	UserID    int
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// Signer подписывает и проверяет ссылки. Подписывает первый ключ, проверяют все,
+// поэтому ключ меняют так: новый ставят первым, старый оставляют до истечения его ссылок.
+type Signer struct {
+	keys map[string]Key
+	sign Key
+	ttl  time.Duration
+}
+
+// New создаёт подписчика со сроком действия ссылок ttl.
+func New(keys []Key, ttl time.Duration) (*Signer, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("не задано ни одного ключа подписи")
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("срок действия ссылок должен быть положительным")
+	}
+	s := &Signer{keys: make(map[string]Key, len(keys)), sign: keys[0], ttl: ttl}
+	for _, key := range keys {
+		s.keys[key.ID] = key
+	}
+	return s, nil
+}
+
+// Sign выдаёт ссылку на видео video в комнате roomID пользователю userID
+// и возвращает токен для пути ссылки вместе с её claims.
+func (s *Signer) Sign(video string, roomID, userID int) (string, Claims) {
+	now := time.Now()
+	claims := Claims{
+		Video:     video,
+		RoomID:    roomID,
+		UserID:    userID,
+		IssuedAt:  now.Truncate(time.Millisecond),
+		ExpiresAt: now.Add(s.ttl).Truncate(time.Second),
+	}
+	fields := []string{
+		s.sign.ID,
+		strconv.Itoa(roomID),
+		strconv.Itoa(userID),
+		// Время выдачи в миллисекундах: ссылка, выданная сразу после отзыва, должна действовать
+		strconv.FormatInt(claims.IssuedAt.UnixMilli(), 10),
+		strconv.FormatInt(claims.ExpiresAt.Unix(), 10),
+	}
+	return strings.Join(append(fields, mac(s.sign, video, fields)), "."), claims
+}
+
+// Verify проверяет токен ссылки на файлы видео video и возвращает её claims.
+func (s *Signer) Verify(token, video string) (Claims, error) {
+	fields := strings.Split(token, ".")
+	if len(fields) != 6 {
+		return Claims{}, ErrInvalid
+	}
+	key, ok := s.keys[fields[0]]
+	if !ok {
+		return Claims{}, ErrInvalid
+	}
+	if !hmac.Equal([]byte(fields[5]), []byte(mac(key, video, fields[:5]))) {
+		return Claims{}, ErrInvalid
+	}
+
+	roomID, err1 := strconv.Atoi(fields[1])
+	userID, err2 := strconv.Atoi(fields[2])
+	issuedAt, err3 := strconv.ParseInt(fields[3], 10, 64)
+	expiresAt, err4 := strconv.ParseInt(fields[4], 10, 64)
+	if err := errors.Join(err1, err2, err3, err4); err != nil {
+		return Claims{}, ErrInvalid
+	}
+	claims := Claims{
+		Video:     video,
+		RoomID:    roomID,
+		UserID:    userID,
+		IssuedAt:  time.UnixMilli(issuedAt),
+		ExpiresAt: time.Unix(expiresAt, 0),
+	}
+	if !time.Now().Before(claims.ExpiresAt) {
+		return Claims{}, ErrExpired
+	}
+	return claims, nil
+}
+
+// mac подписывает поля токена вместе с именем видео.
+func mac(key Key, video string, fields []string) string {
+	h := hmac.New(sha256.New, key.Secret)
+	h.Write([]byte(video))
+	for _, field := range fields {
+		h.Write([]byte{'\n'})
+		h.Write([]byte(field))
+	}
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,130 @@
+package signing
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	currentKey = Key{ID: "k2", Secret: []byte("0123456789abcdef-current")}
+	oldKey     = Key{ID: "k1", Secret: []byte("0123456789abcdef-old")}
+)
+
+func newSigner(t *testing.T, ttl time.Duration, keys ...Key) *Signer {
+	t.Helper()
+	s, err := New(keys, ttl)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return s
+}
+
+func TestParseKeys(t *testing.T) {
+	keys, err := ParseKeys(" k2:0123456789abcdef-current , k1:0123456789abcdef:old,")
+	if err != nil {
+		t.Fatalf("ParseKeys: %v", err)
+	}
+	if len(keys) != 2 || keys[0].ID != "k2" || keys[1].ID != "k1" || string(keys[1].Secret) != "0123456789abcdef:old" {
+		t.Errorf("ParseKeys = %+v", keys)
+	}
+
+	for _, in := range []string{"k1", "k1:short", "bad.id:0123456789abcdef", ":0123456789abcdef", "k1:0123456789abcdef,k1:fedcba9876543210"} {
+		if _, err := ParseKeys(in); err == nil {
+			t.Errorf("ParseKeys(%q): ожидалась ошибка", in)
+		}
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	s := newSigner(t, time.Hour, currentKey)
+	token, claims := s.Sign("videos/abc", 7, 42)
+	if !strings.HasPrefix(token, currentKey.ID+".") {
+		t.Errorf("токен %q подписан не текущим ключом", token)
+	}
+
+	got, err := s.Verify(token, "videos/abc")
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if got.Video != "videos/abc" || got.RoomID != 7 || got.UserID != 42 {
+		t.Errorf("Verify = %+v, ожидалось видео videos/abc, комната 7, пользователь 42", got)
+	}
+	if !got.IssuedAt.Equal(claims.IssuedAt) || !got.ExpiresAt.Equal(claims.ExpiresAt) {
+		t.Errorf("время ссылки %v–%v, ожидалось %v–%v", got.IssuedAt, got.ExpiresAt, claims.IssuedAt, claims.ExpiresAt)
+	}
+	if until := time.Until(got.ExpiresAt); until <= 0 || until > time.Hour {
+		t.Errorf("ссылка действует ещё %v, ожидалось не больше часа", until)
+	}
+}
+
+func TestVerifyTampered(t *testing.T) {
+	s := newSigner(t, time.Hour, currentKey)
+	token, _ := s.Sign("videos/abc", 7, 42)
+	fields := strings.Split(token, ".")
+
+	// Любое изменённое поле, включая срок действия и саму подпись, делает ссылку недействительной
+	replacements := map[int]string{1: "8", 2: "43", 3: "1", 4: "9999999999", 5: "AAAA"}
+	for i, value := range replacements {
+		tampered := append([]string(nil), fields...)
+		tampered[i] = value
+		if _, err := s.Verify(strings.Join(tampered, "."), "videos/abc"); !errors.Is(err, ErrInvalid) {
+			t.Errorf("изменено поле %d: %v, ожидалось %v", i, err, ErrInvalid)
+		}
+	}
+
+	// Ключ с тем же ID, но другим секретом
+	forger := newSigner(t, time.Hour, Key{ID: currentKey.ID, Secret: []byte("fedcba9876543210-forged")})
+	forged, _ := forger.Sign("videos/abc", 7, 42)
+	if _, err := s.Verify(forged, "videos/abc"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("ссылка, подписанная чужим секретом: %v, ожидалось %v", err, ErrInvalid)
+	}
+
+	// Ссылка на одно видео не открывает другое
+	if _, err := s.Verify(token, "videos/other"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("ссылка на другое видео: %v, ожидалось %v", err, ErrInvalid)
+	}
+	for _, bad := range []string{"", "k2", strings.Join(fields[:5], "."), token + ".extra", "unknown." + strings.Join(fields[1:], ".")} {
+		if _, err := s.Verify(bad, "videos/abc"); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Verify(%q): %v, ожидалось %v", bad, err, ErrInvalid)
+		}
+	}
+}
+
+func TestVerifyExpired(t *testing.T) {
+	// Срок обрезается до секунды, поэтому такая ссылка истекает сразу
+	s := newSigner(t, time.Nanosecond, currentKey)
+	token, _ := s.Sign("videos/abc", 7, 42)
+	if _, err := s.Verify(token, "videos/abc"); !errors.Is(err, ErrExpired) {
+		t.Errorf("Verify: %v, ожидалось %v", err, ErrExpired)
+	}
+}
+
+func TestVerifyRotatedKey(t *testing.T) {
+	old := newSigner(t, time.Hour, oldKey)
+	token, _ := old.Sign("videos/abc", 7, 42)
+
+	// После смены ключа ссылки, подписанные старым, действуют, пока он в списке
+	rotated := newSigner(t, time.Hour, currentKey, oldKey)
+	if _, err := rotated.Verify(token, "videos/abc"); err != nil {
+		t.Errorf("ссылка, подписанная старым ключом: %v", err)
+	}
+	if fresh, _ := rotated.Sign("videos/abc", 7, 42); !strings.HasPrefix(fresh, currentKey.ID+".") {
+		t.Errorf("новая ссылка %q подписана не первым ключом", fresh)
+	}
+
+	// Убранный из списка ключ больше не принимается
+	if _, err := newSigner(t, time.Hour, currentKey).Verify(token, "videos/abc"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("ссылка, подписанная убранным ключом: %v, ожидалось %v", err, ErrInvalid)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(nil, time.Hour); err == nil {
+		t.Error("New без ключей: ожидалась ошибка")
+	}
+	if _, err := New([]Key{currentKey}, 0); err == nil {
+		t.Error("New с нулевым сроком: ожидалась ошибка")
+	}
+}
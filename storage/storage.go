@@ -0,0 +1,116 @@
+// Package storage отвечает за хранение медиафайлов сервиса.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Storage — хранилище медиафайлов.
+type Storage interface {
+	// Ping проверяет, что хранилище доступно для записи.
+	Ping(ctx context.Context) error
+	// Save записывает файл name из r и возвращает число записанных байт.
+	// Файл появляется целиком или не появляется вовсе.
+	Save(ctx context.Context, name string, r io.Reader) (int64, error)
+	// Open открывает файл name для чтения.
+	Open(ctx context.Context, name string) (io.ReadSeekCloser, error)
+	// Delete удаляет файл или каталог name со всем содержимым.
+	// Отсутствующий файл ошибкой не считается.
+	Delete(ctx context.Context, name string) error
+}
+
+// Local хранит файлы в каталоге на локальном диске.
+type Local struct {
+	dir string
+}
+
+// NewLocal создаёт хранилище в каталоге dir, создавая каталог при необходимости.
+func NewLocal(dir string) (*Local, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("не удалось создать каталог хранилища '%s': %w", dir, err)
+	}
+	return &Local{dir: dir}, nil
+}
+
+// Ping проверяет, что каталог существует и в него можно записать файл.
+func (l *Local) Ping(ctx context.Context) error {
+	f, err := os.CreateTemp(l.dir, ".ping-*")
+	if err != nil {
+		return fmt.Errorf("каталог хранилища недоступен для записи: %w", err)
+	}
+	name := f.Name()
+	f.Close()
+	if err := os.Remove(name); err != nil {
+		return fmt.Errorf("не удалось удалить проверочный файл: %w", err)
+	}
+	return nil
+}
+
+// path возвращает путь к файлу хранилища.
+// Имена, выходящие за пределы каталога, отклоняются.
+func (l *Local) path(name string) (string, error) {
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("недопустимое имя файла '%s'", name)
+	}
+	return filepath.Join(l.dir, name), nil
+}
+
+// Save записывает файл во временный файл рядом и переименовывает его по завершении.
+func (l *Local) Save(ctx context.Context, name string, r io.Reader) (int64, error) {
+	path, err := l.path(name)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return 0, fmt.Errorf("не удалось создать каталог для '%s': %w", name, err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return 0, fmt.Errorf("не удалось создать файл '%s': %w", name, err)
+	}
+	n, err := io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return n, fmt.Errorf("не удалось записать файл '%s': %w", name, err)
+	}
+	return n, nil
+}
+
+// Open открывает файл из каталога хранилища.
+func (l *Local) Open(ctx context.Context, name string) (io.ReadSeekCloser, error) {
+	path, err := l.path(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть файл '%s': %w", name, err)
+	}
+	return f, nil
+}
+
+// Delete удаляет файл или каталог из каталога хранилища.
+func (l *Local) Delete(ctx context.Context, name string) error {
+	path, err := l.path(name)
+	if err != nil {
+		return err
+	}
+	err = os.RemoveAll(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("не удалось удалить файл '%s': %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+// Package subtitles разбирает субтитры SRT, WebVTT и ASS/SSA и преобразует их в WebVTT,
+// который браузеры показывают без сторонних библиотек.
+package subtitles
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Поддерживаемые форматы субтитров.
+const (
+	FormatSRT = "srt"
+	FormatVTT = "vtt"
+	FormatASS = "ass"
+)
+
+// ErrUnknownFormat — формат субтитров не удалось определить.
+var ErrUnknownFormat = errors.New("неизвестный формат субтитров")
+
+// Cue — одна реплика субтитров.
+type Cue struct {
+	Start time.Duration
+	End   time.Duration
+	// Settings — настройки положения реплики WebVTT; для других форматов пусты.
+	Settings string
+	Text     string
+}
+
+// Detect определяет формат по расширению имени файла, а если его нет — по содержимому.
+func Detect(name string, data []byte) (string, error) {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".srt":
+		return FormatSRT, nil
+	case ".vtt":
+		return FormatVTT, nil
+	case ".ass", ".ssa":
+		return FormatASS, nil
+	}
+
+	text := normalize(data)
+	switch {
+	case strings.HasPrefix(text, "WEBVTT"):
+		return FormatVTT, nil
+	case strings.HasPrefix(text, "[Script Info]"):
+		return FormatASS, nil
+	case strings.Contains(text, "-->"):
+		return FormatSRT, nil
+	}
+	return "", ErrUnknownFormat
+}
+
+// Parse разбирает субтитры формата format и возвращает реплики по времени начала.
+func Parse(data []byte, format string) ([]Cue, error) {
+	text := normalize(data)
+	var cues []Cue
+	var err error
+	switch format {
+	case FormatSRT:
+		cues, err = parseBlocks(text)
+	case FormatVTT:
+		header, _, _ := strings.Cut(text, "\n")
+		if header != "WEBVTT" && !strings.HasPrefix(header, "WEBVTT ") && !strings.HasPrefix(header, "WEBVTT\t") {
+			return nil, fmt.Errorf("нет заголовка WEBVTT")
+		}
+		cues, err = parseBlocks(text)
+	case FormatASS:
+		cues, err = parseASS(text)
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(cues) == 0 {
+		return nil, fmt.Errorf("в файле нет реплик")
+	}
+	sort.SliceStable(cues, func(i, j int) bool { return cues[i].Start < cues[j].Start })
+	return cues, nil
+}
+
+// ToWebVTT преобразует субтитры формата format в WebVTT.
+func ToWebVTT(data []byte, format string) ([]byte, error) {
+	cues, err := Parse(data, format)
+	if err != nil {
+		return nil, err
+	}
+	return Write(cues), nil
+}
+
+// Write записывает реплики в формате WebVTT.
+func Write(cues []Cue) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("WEBVTT\n")
+	for _, cue := range cues {
+		buf.WriteString("\n")
+		buf.WriteString(formatTimestamp(cue.Start))
+		buf.WriteString(" --> ")
+		buf.WriteString(formatTimestamp(cue.End))
+		if cue.Settings != "" {
+			buf.WriteString(" ")
+			buf.WriteString(cue.Settings)
+		}
+		buf.WriteString("\n")
+		buf.WriteString(cue.Text)
+		buf.WriteString("\n")
+	}
+	return buf.Bytes()
+}
+
+// normalize убирает BOM и приводит переводы строк к \n.
+func normalize(data []byte) string {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	return strings.ReplaceAll(text, "\r", "\n")
+}
+
+// parseBlocks разбирает SRT и WebVTT: реплики — блоки, разделённые пустыми строками,
+// со строкой времени "начало --> конец". Блоки без неё (номера, NOTE, STYLE) пропускаются.
+func parseBlocks(text string) ([]Cue, error) {
+	var cues []Cue
+	for _, block := range strings.Split(text, "\n\n") {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+		timing := -1
+		for i, line := range lines {
+			if strings.Contains(line, "-->") {
+				timing = i
+				break
+			}
+		}
+		// Строке времени может предшествовать только номер или идентификатор реплики
+		if timing < 0 || timing > 1 {
+			continue
+		}
+
+		start, rest, _ := strings.Cut(lines[timing], "-->")
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("некорректная строка времени '%s'", lines[timing])
+		}
+		cue := Cue{Settings: strings.Join(fields[1:], " ")}
+		var err error
+		if cue.Start, err = parseTimestamp(strings.TrimSpace(start)); err != nil {
+			return nil, err
+		}
+		if cue.End, err = parseTimestamp(fields[0]); err != nil {
+			return nil, err
+		}
+		cue.Text = cleanText(strings.Join(lines[timing+1:], "\n"))
+		if cue.Text == "" || cue.End <= cue.Start {
+			continue
+		}
+		cues = append(cues, cue)
+	}
+	return cues, nil
+}
+
+// parseTimestamp разбирает время вида [чч:]мм:сс,ммм или [чч:]мм:сс.ммм.
+func parseTimestamp(s string) (time.Duration, error) {
+	value := strings.Replace(s, ",", ".", 1)
+	clock, fraction, _ := strings.Cut(value, ".")
+	parts := strings.Split(clock, ":")
+	if len(parts) < 2 || len(parts) > 3 || len(fraction) > 3 {
+		return 0, fmt.Errorf("некорректное время '%s'", s)
+	}
+
+	var total time.Duration
+	units := []time.Duration{time.Second, time.Minute, time.Hour}
+	for i := range parts {
+		n, err := strconv.Atoi(parts[len(parts)-1-i])
+		if err != nil || n < 0 || (i < 2 && i < len(parts)-1 && n >= 60) {
+			return 0, fmt.Errorf("некорректное время '%s'", s)
+		}
+		total += time.Duration(n) * units[i]
+	}
+	if fraction != "" {
+		n, err := strconv.Atoi(fraction)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("некорректное время '%s'", s)
+		}
+		for i := len(fraction); i < 3; i++ {
+			n *= 10
+		}
+		total += time.Duration(n) * time.Millisecond
+	}
+	return total, nil
+}
+
+// formatTimestamp записывает время в виде чч:мм:сс.ммм.
+func formatTimestamp(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// cleanText убирает пустые строки внутри реплики: в WebVTT они завершают реплику.
+func cleanText(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, " \t")
+		if line == "" {
+			continue
+		}
+		// "-->" внутри текста WebVTT не допускает
+		lines = append(lines, strings.ReplaceAll(line, "-->", "->"))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// assOverride — блоки команд оформления ASS вида {\i1}.
+var assOverride = regexp.MustCompile(`\{[^}]*\}`)
+
+// parseASS разбирает строки Dialogue секции [Events] в порядке полей из её строки Format.
+func parseASS(text string) ([]Cue, error) {
+	var cues []Cue
+	inEvents := false
+	var format []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			inEvents = strings.EqualFold(line, "[Events]")
+			continue
+		}
+		if !inEvents {
+			continue
+		}
+
+		kind, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch kind {
+		case "Format":
+			format = nil
+			for _, field := range strings.Split(value, ",") {
+				format = append(format, strings.ToLower(strings.TrimSpace(field)))
+			}
+		case "Dialogue":
+			if format == nil {
+				return nil, fmt.Errorf("строка Dialogue до строки Format")
+			}
+			// Текст — последнее поле и может содержать запятые
+			fields := strings.SplitN(strings.TrimSpace(value), ",", len(format))
+			if len(fields) != len(format) {
+				return nil, fmt.Errorf("некорректная строка Dialogue '%s'", line)
+			}
+			var cue Cue
+			var err error
+			for i, name := range format {
+				field := strings.TrimSpace(fields[i])
+				switch name {
+				case "start":
+					cue.Start, err = parseTimestamp(field)
+				case "end":
+					cue.End, err = parseTimestamp(field)
+				case "text":
+					cue.Text = assText(fields[i])
+				}
+				if err != nil {
+					return nil, err
+				}
+			}
+			if cue.Text == "" || cue.End <= cue.Start {
+				continue
+			}
+			cues = append(cues, cue)
+		}
+	}
+	return cues, nil
+}
+
+// assText переводит текст реплики ASS в текст WebVTT.
+func assText(text string) string {
+	text = assOverride.ReplaceAllString(text, "")
+	text = strings.NewReplacer(`\N`, "\n", `\n`, "\n", `\h`, " ").Replace(text)
+	text = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+	return cleanText(strings.TrimSpace(text))
+}
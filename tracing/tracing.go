@@ -0,0 +1,105 @@
+// Package tracing настраивает OpenTelemetry-трассировку HTTP-запросов,
+// команд WebSocket и запросов к базе данных.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName — имя, под которым сервис создаёт спаны.
+const instrumentationName = "room"
+
+// Exporter — куда отправлять спаны.
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// Options — параметры трассировки.
+type Options struct {
+	// Exporter — "none", "otlp" или "stdout".
+	Exporter string
+	// ServiceName — значение service.name в ресурсах.
+	ServiceName string
+	// Endpoint — адрес OTLP/HTTP коллектора (host:port). Пустое значение —
+	// адрес берётся из стандартных переменных OTEL_EXPORTER_OTLP_*.
+	Endpoint string
+	// Insecure отключает TLS при отправке в коллектор.
+	Insecure bool
+	// SampleRatio — доля трассируемых запросов от 0 до 1.
+	SampleRatio float64
+	// Writer — куда писать спаны при Exporter == "stdout".
+	Writer io.Writer
+}
+
+// Setup регистрирует глобальный TracerProvider и возвращает функцию,
+// которая выгружает оставшиеся спаны при остановке сервиса.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+
+	switch opts.Exporter {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		clientOpts := []otlptracehttp.Option{}
+		if opts.Endpoint != "" {
+			clientOpts = append(clientOpts, otlptracehttp.WithEndpoint(opts.Endpoint))
+		}
+		if opts.Insecure {
+			clientOpts = append(clientOpts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, clientOpts...)
+	case ExporterStdout:
+		stdoutOpts := []stdouttrace.Option{}
+		if opts.Writer != nil {
+			stdoutOpts = append(stdoutOpts, stdouttrace.WithWriter(opts.Writer))
+		}
+		exporter, err = stdouttrace.New(stdoutOpts...)
+	default:
+		return nil, fmt.Errorf("неизвестный экспортёр трассировки '%s'", opts.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать экспортёр трассировки: %w", err)
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(semconv.ServiceName(opts.ServiceName)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось описать ресурс трассировки: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return provider.Shutdown, nil
+}
+
+// Tracer возвращает трассировщик сервиса.
+// Пока Setup не вызван, спаны не записываются.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
@@ -0,0 +1,203 @@
+package transcode
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// segmentDuration — длительность HLS-сегмента в секундах.
+const segmentDuration = 6
+
+// FFmpeg перекодирует видео локальным бинарником ffmpeg.
+type FFmpeg struct {
+	// Binary — путь к ffmpeg; пустой — искать в PATH.
+	Binary string
+}
+
+// NewFFmpeg создаёт перекодировщик, использующий бинарник binary.
+func NewFFmpeg(binary string) *FFmpeg {
+	return &FFmpeg{Binary: binary}
+}
+
+func (f *FFmpeg) binary() string {
+	if f.Binary == "" {
+		return "ffmpeg"
+	}
+	return f.Binary
+}
+
+// source — сведения об исходнике из вывода ffmpeg.
+type source struct {
+	duration time.Duration
+	height   int
+	hasAudio bool
+}
+
+var (
+	durationLine = regexp.MustCompile(`Duration: (\d+):(\d{2}):(\d{2})\.(\d+)`)
+	videoStream  = regexp.MustCompile(`Stream #\d+:\d+.*: Video: .*?, (\d{2,5})x(\d{2,5})`)
+	audioStream  = regexp.MustCompile(`Stream #\d+:\d+.*: Audio: `)
+)
+
+// inspect читает длительность, высоту кадра и наличие звука из заголовка,
+// который ffmpeg печатает при открытии файла.
+func (f *FFmpeg) inspect(ctx context.Context, input string) (source, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, f.binary(), "-hide_banner", "-i", input)
+	cmd.Stderr = &stderr
+	// Без выходного файла ffmpeg всегда завершается с ошибкой, поэтому смотрим только вывод
+	_ = cmd.Run()
+	if ctx.Err() != nil {
+		return source{}, ctx.Err()
+	}
+	out := stderr.String()
+
+	var src source
+	match := videoStream.FindStringSubmatch(out)
+	if match == nil {
+		return source{}, fmt.Errorf("в файле нет видеопотока: %s", lastLines(out, 3))
+	}
+	src.height, _ = strconv.Atoi(match[2])
+	src.hasAudio = audioStream.MatchString(out)
+	if m := durationLine.FindStringSubmatch(out); m != nil {
+		h, _ := strconv.Atoi(m[1])
+		mins, _ := strconv.Atoi(m[2])
+		sec, _ := strconv.Atoi(m[3])
+		frac, _ := strconv.ParseFloat("0."+m[4], 64)
+		src.duration = time.Duration(h)*time.Hour + time.Duration(mins)*time.Minute +
+			time.Duration(sec)*time.Second + time.Duration(frac*float64(time.Second))
+	}
+	return src, nil
+}
+
+// Transcode перекодирует видео в H.264/AAC. Качества выше исходника пропускаются,
+// но самое низкое остаётся всегда.
+func (f *FFmpeg) Transcode(ctx context.Context, input, outDir string, ladder []Rendition, progress func(float64)) error {
+	if len(ladder) == 0 {
+		return fmt.Errorf("не заданы качества перекодирования")
+	}
+	src, err := f.inspect(ctx, input)
+	if err != nil {
+		return err
+	}
+	renditions := []Rendition{ladder[0]}
+	for _, r := range ladder[1:] {
+		if r.Height <= src.height {
+			renditions = append(renditions, r)
+		}
+	}
+
+	for _, r := range renditions {
+		if err := os.MkdirAll(filepath.Join(outDir, r.Name), 0o755); err != nil {
+			return fmt.Errorf("не удалось создать каталог качества %s: %w", r.Name, err)
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, f.binary(), f.args(input, outDir, renditions, src.hasAudio)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("не удалось запустить ffmpeg: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("не удалось запустить ffmpeg: %w", err)
+	}
+	readProgress(stdout, src.duration, progress)
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("ffmpeg завершился с ошибкой: %w: %s", err, lastLines(stderr.String(), 3))
+	}
+	return nil
+}
+
+// args собирает аргументы ffmpeg для HLS-лесенки из renditions.
+func (f *FFmpeg) args(input, outDir string, renditions []Rendition, hasAudio bool) []string {
+	var filter strings.Builder
+	fmt.Fprintf(&filter, "[0:v]split=%d", len(renditions))
+	for i := range renditions {
+		fmt.Fprintf(&filter, "[s%d]", i)
+	}
+	for i, r := range renditions {
+		fmt.Fprintf(&filter, ";[s%d]scale=-2:%d[v%d]", i, r.Height, i)
+	}
+
+	args := []string{"-hide_banner", "-nostats", "-y", "-i", input, "-filter_complex", filter.String()}
+	var streams []string
+	for i, r := range renditions {
+		n := strconv.Itoa(i)
+		args = append(args,
+			"-map", "[v"+n+"]",
+			"-c:v:"+n, "libx264",
+			"-b:v:"+n, r.VideoBitrate,
+			"-maxrate:v:"+n, r.VideoBitrate,
+			"-bufsize:v:"+n, r.VideoBitrate,
+		)
+		stream := "v:" + n
+		if hasAudio {
+			args = append(args,
+				"-map", "0:a:0",
+				"-c:a:"+n, "aac",
+				"-b:a:"+n, r.AudioBitrate,
+				"-ac:a:"+n, "2",
+			)
+			stream += ",a:" + n
+		}
+		streams = append(streams, stream+",name:"+r.Name)
+	}
+
+	return append(args,
+		"-preset", "veryfast",
+		"-pix_fmt", "yuv420p",
+		// Ключевые кадры на границах сегментов, чтобы качества можно было переключать
+		"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", segmentDuration),
+		"-f", "hls",
+		"-hls_time", strconv.Itoa(segmentDuration),
+		"-hls_playlist_type", "vod",
+		"-hls_flags", "independent_segments",
+		"-hls_segment_filename", filepath.Join(outDir, "%v", "segment_%04d.ts"),
+		"-master_pl_name", MasterPlaylist,
+		"-var_stream_map", strings.Join(streams, " "),
+		"-progress", "pipe:1",
+		filepath.Join(outDir, "%v", "index.m3u8"),
+	)
+}
+
+// readProgress читает вывод -progress ffmpeg и сообщает долю обработанной длительности.
+func readProgress(r io.Reader, duration time.Duration, progress func(float64)) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok || key != "out_time_us" || duration <= 0 || progress == nil {
+			continue
+		}
+		us, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || us < 0 {
+			continue
+		}
+		progress(min(float64(us)/float64(duration.Microseconds()), 1))
+	}
+	// Дочитываем вывод, чтобы ffmpeg не заблокировался на записи
+	io.Copy(io.Discard, r)
+}
+
+// lastLines возвращает последние n непустых строк вывода для сообщения об ошибке.
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "; ")
+}
@@ -0,0 +1,336 @@
+package transcode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"room/database"
+	"room/logging"
+	"room/metrics"
+	"room/storage"
+	"room/tracing"
+	"sync"
+	"time"
+)
+
+// progressInterval — не чаще какого интервала сохранять и рассылать ход перекодирования.
+const progressInterval = time.Second
+
+// Notifier получает изменения состояния заданий, например чтобы разослать их в комнату.
+type Notifier interface {
+	TranscodeUpdate(job database.TranscodeJob)
+}
+
+// Options — настройки очереди перекодирования.
+type Options struct {
+	// Workers — сколько видео перекодировать одновременно.
+	Workers int
+	// Ladder — качества HLS; пустая — DefaultLadder.
+	Ladder []Rendition
+	// TempDir — каталог для временных файлов; пустой — системный.
+	TempDir string
+	// ThumbnailInterval — через сколько секунд видео брать кадры для миниатюр перемотки;
+	// 0 — не создавать постер и миниатюры.
+	ThumbnailInterval time.Duration
+	// ThumbnailWidth — ширина миниатюры; 0 — defaultThumbnailWidth.
+	ThumbnailWidth int
+}
+
+// defaultThumbnailWidth — ширина миниатюры по умолчанию.
+const defaultThumbnailWidth = 160
+
+// Pipeline перекодирует загруженные видео в фоне. Очередь хранится в базе,
+// поэтому задания, прерванные остановкой сервиса, выполняются после запуска.
+type Pipeline struct {
+	db         *database.DB
+	store      storage.Storage
+	transcoder Transcoder
+	prober     Prober
+	extractor  FrameExtractor
+	notifier   Notifier
+	logger     *slog.Logger
+	opts       Options
+
+	// wake будит свободный обработчик, когда в очереди появляется задание
+	wake chan struct{}
+}
+
+// New создаёт очередь перекодирования. Запускается она методом Run.
+// extractor создаёт постер и миниатюры перемотки.
+func New(db *database.DB, store storage.Storage, transcoder Transcoder, prober Prober, extractor FrameExtractor, notifier Notifier, logger *slog.Logger, opts Options) (*Pipeline, error) {
+	if opts.Workers <= 0 {
+		return nil, fmt.Errorf("число обработчиков перекодирования должно быть положительным")
+	}
+	if opts.ThumbnailInterval < 0 {
+		return nil, fmt.Errorf("интервал миниатюр не может быть отрицательным")
+	}
+	if len(opts.Ladder) == 0 {
+		opts.Ladder = DefaultLadder
+	}
+	if opts.ThumbnailWidth <= 0 {
+		opts.ThumbnailWidth = defaultThumbnailWidth
+	}
+	return &Pipeline{
+		db:         db,
+		store:      store,
+		transcoder: transcoder,
+		prober:     prober,
+		extractor:  extractor,
+		notifier:   notifier,
+		logger:     logger.With("component", "transcode"),
+		opts:       opts,
+		wake:       make(chan struct{}, 1),
+	}, nil
+}
+
+// Enqueue ставит в очередь перекодирование загруженного файла job.Source в видео job.Video.
+// Готовое видео попадает в библиотеку пользователя job.CreatedBy под названием job.Title.
+// Файл с хешем job.Blob перекодируется один раз: следующие загрузки того же файла
+// используют готовый результат.
+func (p *Pipeline) Enqueue(ctx context.Context, job database.TranscodeJob) (*database.TranscodeJob, error) {
+	created, err := p.db.CreateTranscodeJob(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+	p.notifier.TranscodeUpdate(*created)
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+	return created, nil
+}
+
+// Run возвращает в очередь прерванные задания и обрабатывает очередь, пока не отменён ctx.
+func (p *Pipeline) Run(ctx context.Context) {
+	ctx = logging.WithLogger(ctx, p.logger)
+	if n, err := p.db.RequeueTranscodeJobs(ctx); err != nil {
+		p.logger.Error("Не удалось вернуть задания перекодирования в очередь", logging.KeyError, err)
+	} else if n > 0 {
+		p.logger.Info("Прерванные задания перекодирования возвращены в очередь", "jobs", n)
+	}
+
+	var wg sync.WaitGroup
+	for range p.opts.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// work берёт задания из очереди, пока она не опустеет, и ждёт новых.
+func (p *Pipeline) work(ctx context.Context) {
+	// Периодическая проверка подбирает задания, о которых обработчик не узнал через wake
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		job, err := p.db.ClaimTranscodeJob(ctx)
+		if err != nil && ctx.Err() == nil {
+			p.logger.Error("Не удалось получить задание перекодирования", logging.KeyError, err)
+		}
+		if job != nil {
+			p.run(ctx, job)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// run выполняет задание и сохраняет результат. Задание, прерванное остановкой
+// сервиса, остаётся в работе и возвращается в очередь при следующем запуске.
+func (p *Pipeline) run(ctx context.Context, job *database.TranscodeJob) {
+	start := time.Now()
+	ctx, span := tracing.Tracer().Start(ctx, "transcode.job")
+	defer span.End()
+	log := p.logger.With("video", job.Video, "job_id", job.ID)
+	log.Info("Перекодирование начато")
+	p.notifier.TranscodeUpdate(*job)
+
+	err := p.process(ctx, job)
+	if ctx.Err() != nil {
+		log.Warn("Перекодирование прервано остановкой сервиса")
+		return
+	}
+
+	jobErr := ""
+	if err != nil {
+		span.RecordError(err)
+		log.Error("Перекодирование не удалось", logging.KeyError, err)
+		jobErr = err.Error()
+	}
+	unused, err := p.db.FinishTranscodeJob(ctx, job, jobErr)
+	if err != nil {
+		log.Error("Не удалось сохранить результат перекодирования", logging.KeyError, err)
+		return
+	}
+	if unused != "" {
+		if err := p.store.Delete(ctx, unused); err != nil {
+			log.Warn("Не удалось удалить результат перекодирования", logging.KeyError, err)
+		}
+	}
+	if err := p.store.Delete(ctx, job.Source); err != nil {
+		log.Warn("Не удалось удалить исходный файл", "source", job.Source, logging.KeyError, err)
+	}
+
+	metrics.TranscodeJobs.WithLabelValues(job.Status).Inc()
+	metrics.TranscodeDuration.Observe(time.Since(start).Seconds())
+	p.notifier.TranscodeUpdate(*job)
+}
+
+// process копирует исходник во временный каталог, определяет его параметры,
+// перекодирует, создаёт превью и загружает результат в хранилище в каталог блоба. Если такой же
+// файл уже перекодирован, видео использует готовый блоб.
+// Параметры сохраняются последними, когда видео уже можно показывать.
+func (p *Pipeline) process(ctx context.Context, job *database.TranscodeJob) error {
+	if job.Blob != "" {
+		blob, err := p.db.GetBlob(ctx, job.Blob)
+		if err != nil {
+			return err
+		}
+		if blob.Ready {
+			p.logger.Info("Видео с таким содержимым уже перекодировано", "video", job.Video, "job_id", job.ID)
+			return p.createVideo(ctx, job, blob.Size, blob.Info)
+		}
+	}
+
+	dir, err := os.MkdirTemp(p.opts.TempDir, "transcode-*")
+	if err != nil {
+		return fmt.Errorf("не удалось создать временный каталог: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "input")
+	if err := p.download(ctx, job.Source, input); err != nil {
+		return err
+	}
+	info, err := p.prober.Probe(ctx, input)
+	if err != nil {
+		return err
+	}
+
+	out := filepath.Join(dir, "out")
+	if err := os.Mkdir(out, 0o755); err != nil {
+		return fmt.Errorf("не удалось создать каталог результата: %w", err)
+	}
+	if err := p.transcoder.Transcode(ctx, input, out, p.opts.Ladder, p.progress(ctx, job)); err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(out, MasterPlaylist)); err != nil {
+		return fmt.Errorf("перекодировщик не создал %s: %w", MasterPlaylist, err)
+	}
+	if p.opts.ThumbnailInterval > 0 {
+		err := p.thumbnails(ctx, input, out, info.Duration)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// Видео можно смотреть и без превью
+		if err != nil {
+			p.logger.Warn("Не удалось создать превью", "video", job.Video, logging.KeyError, err)
+		}
+		info.Thumbnails = err == nil
+	}
+	size, err := p.upload(ctx, out, job.Dir())
+	if err != nil {
+		// Частично загруженный результат не нужен
+		if err := p.store.Delete(ctx, job.Dir()); err != nil {
+			p.logger.Warn("Не удалось удалить результат перекодирования", "video", job.Video, logging.KeyError, err)
+		}
+		return err
+	}
+	if job.Blob != "" {
+		if err := p.db.CompleteBlob(ctx, job.Blob, size, *info); err != nil {
+			return err
+		}
+	}
+	return p.createVideo(ctx, job, size, *info)
+}
+
+// createVideo добавляет перекодированное видео задания в библиотеку пользователя.
+func (p *Pipeline) createVideo(ctx context.Context, job *database.TranscodeJob, size int64, info database.VideoInfo) error {
+	_, err := p.db.CreateVideo(ctx, database.Video{
+		Name:      job.Video,
+		Source:    database.SourceUpload,
+		Owner:     &job.CreatedBy,
+		Title:     job.Title,
+		Size:      size,
+		Blob:      job.Blob,
+		VideoInfo: info,
+	})
+	return err
+}
+
+// download копирует файл name из хранилища в локальный файл path.
+func (p *Pipeline) download(ctx context.Context, name, path string) error {
+	src, err := p.store.Open(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("не удалось создать временный файл: %w", err)
+	}
+	_, err = io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось скопировать исходный файл: %w", err)
+	}
+	return nil
+}
+
+// upload сохраняет содержимое каталога dir в хранилище под префиксом video
+// и возвращает общий размер файлов.
+func (p *Pipeline) upload(ctx context.Context, dir, video string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return fmt.Errorf("не удалось открыть результат перекодирования: %w", err)
+		}
+		defer f.Close()
+		n, err := p.store.Save(ctx, path.Join(video, filepath.ToSlash(rel)), f)
+		size += n
+		return err
+	})
+	return size, err
+}
+
+// progress возвращает функцию, которая сохраняет и рассылает ход задания не чаще progressInterval.
+func (p *Pipeline) progress(ctx context.Context, job *database.TranscodeJob) func(float64) {
+	var last time.Time
+	return func(progress float64) {
+		if time.Since(last) < progressInterval || progress <= job.Progress {
+			return
+		}
+		last = time.Now()
+		job.Progress = progress
+		if err := p.db.SetTranscodeProgress(ctx, job.ID, progress); err != nil && !errors.Is(err, context.Canceled) {
+			p.logger.Warn("Не удалось сохранить ход перекодирования", "video", job.Video, logging.KeyError, err)
+		}
+		p.notifier.TranscodeUpdate(*job)
+	}
+}
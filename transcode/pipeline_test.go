@@ -0,0 +1,287 @@
+package transcode
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"room/database"
+	"room/storage"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeTranscoder создаёт плейлисты вместо перекодирования и считает вызовы.
+type fakeTranscoder struct {
+	calls atomic.Int32
+	// err — ошибка, которую возвращает Transcode.
+	err error
+	// block — если задан, Transcode ждёт отмены ctx.
+	block bool
+}
+
+func (f *fakeTranscoder) Transcode(ctx context.Context, input, outDir string, ladder []Rendition, progress func(float64)) error {
+	f.calls.Add(1)
+	if f.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if f.err != nil {
+		return f.err
+	}
+	progress(0.5)
+	if err := os.Mkdir(filepath.Join(outDir, ladder[0].Name), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(outDir, ladder[0].Name, "index.m3u8"), []byte("#EXTM3U\n"), 0o644); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(outDir, MasterPlaylist), []byte("#EXTM3U\n"), 0o644)
+}
+
+type fakeProber struct{}
+
+func (fakeProber) Probe(ctx context.Context, input string) (*database.VideoInfo, error) {
+	return &database.VideoInfo{Duration: 10, Width: 640, Height: 360}, nil
+}
+
+type nopNotifier struct{}
+
+func (nopNotifier) TranscodeUpdate(database.TranscodeJob) {}
+
+// newTestPipeline создаёт очередь с базой и хранилищем во временном каталоге.
+func newTestPipeline(t *testing.T, transcoder Transcoder) (*Pipeline, *database.DB, *storage.Local) {
+	t.Helper()
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dir := t.TempDir()
+
+	db, err := database.New(filepath.Join(dir, "test.db"), logger)
+	if err != nil {
+		t.Fatalf("database.New: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.CreateTable(ctx); err != nil {
+		t.Fatalf("CreateTable: %v", err)
+	}
+	store, err := storage.NewLocal(filepath.Join(dir, "media"))
+	if err != nil {
+		t.Fatalf("storage.NewLocal: %v", err)
+	}
+	p, err := New(db, store, transcoder, fakeProber{}, nil, nopNotifier{}, logger, Options{Workers: 1, TempDir: dir})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return p, db, store
+}
+
+// enqueue сохраняет исходный файл видео name и ставит его в очередь с блобом blob.
+func enqueue(t *testing.T, p *Pipeline, store storage.Storage, name, blob string) *database.TranscodeJob {
+	t.Helper()
+	ctx := context.Background()
+	source := "uploads/" + name
+	size, err := store.Save(ctx, source, strings.NewReader("video "+name))
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	job, err := p.Enqueue(ctx, database.TranscodeJob{
+		Video:      "videos/" + name,
+		Source:     source,
+		Title:      name,
+		SourceSize: size,
+		Blob:       blob,
+		CreatedBy:  1,
+	})
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	return job
+}
+
+// claim берёт следующее задание из очереди и проверяет, что это want; пустой want — очередь пуста.
+func claim(t *testing.T, db *database.DB, want string) *database.TranscodeJob {
+	t.Helper()
+	job, err := db.ClaimTranscodeJob(context.Background())
+	if err != nil {
+		t.Fatalf("ClaimTranscodeJob: %v", err)
+	}
+	switch {
+	case want == "" && job != nil:
+		t.Fatalf("получено задание %s, ожидалась пустая очередь", job.Video)
+	case want != "" && job == nil:
+		t.Fatalf("очередь пуста, ожидалось задание %s", want)
+	case want != "" && job.Video != want:
+		t.Fatalf("получено задание %s, ожидалось %s", job.Video, want)
+	}
+	return job
+}
+
+// drain выполняет задания из очереди, пока она не опустеет.
+func drain(t *testing.T, p *Pipeline, db *database.DB) {
+	t.Helper()
+	for {
+		job, err := db.ClaimTranscodeJob(context.Background())
+		if err != nil {
+			t.Fatalf("ClaimTranscodeJob: %v", err)
+		}
+		if job == nil {
+			return
+		}
+		p.run(context.Background(), job)
+	}
+}
+
+func status(t *testing.T, db *database.DB, video string) string {
+	t.Helper()
+	job, err := db.GetTranscodeJob(context.Background(), video)
+	if err != nil {
+		t.Fatalf("GetTranscodeJob(%s): %v", video, err)
+	}
+	return job.Status
+}
+
+func TestClaimWaitsForSameBlob(t *testing.T) {
+	p, db, store := newTestPipeline(t, &fakeTranscoder{})
+	enqueue(t, p, store, "a", "aa")
+	enqueue(t, p, store, "b", "aa")
+	enqueue(t, p, store, "c", "cc")
+	enqueue(t, p, store, "legacy1", "")
+	enqueue(t, p, store, "legacy2", "")
+
+	first := claim(t, db, "videos/a")
+	if first.Status != database.TranscodeRunning || first.StartedAt == nil {
+		t.Errorf("задание в состоянии %s, ожидалось %s со временем начала", first.Status, database.TranscodeRunning)
+	}
+	// Задание b ждёт, пока перекодируется блоб aa; остальные берутся по порядку
+	claim(t, db, "videos/c")
+	claim(t, db, "videos/legacy1")
+	claim(t, db, "videos/legacy2")
+	claim(t, db, "")
+
+	if _, err := db.FinishTranscodeJob(context.Background(), first, ""); err != nil {
+		t.Fatalf("FinishTranscodeJob: %v", err)
+	}
+	claim(t, db, "videos/b")
+}
+
+func TestRequeueInterruptedJob(t *testing.T) {
+	transcoder := &fakeTranscoder{block: true}
+	p, db, store := newTestPipeline(t, transcoder)
+	enqueue(t, p, store, "a", "aa")
+
+	// Остановка сервиса посреди перекодирования оставляет задание в работе
+	ctx, cancel := context.WithCancel(context.Background())
+	job := claim(t, db, "videos/a")
+	done := make(chan struct{})
+	go func() {
+		p.run(ctx, job)
+		close(done)
+	}()
+	cancel()
+	<-done
+	if got := status(t, db, "videos/a"); got != database.TranscodeRunning {
+		t.Fatalf("задание в состоянии %s, ожидалось %s", got, database.TranscodeRunning)
+	}
+	claim(t, db, "")
+
+	n, err := db.RequeueTranscodeJobs(context.Background())
+	if err != nil {
+		t.Fatalf("RequeueTranscodeJobs: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("в очередь возвращено %d заданий, ожидалось 1", n)
+	}
+	if got := status(t, db, "videos/a"); got != database.TranscodeQueued {
+		t.Fatalf("задание в состоянии %s, ожидалось %s", got, database.TranscodeQueued)
+	}
+
+	transcoder.block = false
+	drain(t, p, db)
+	if got := status(t, db, "videos/a"); got != database.TranscodeDone {
+		t.Errorf("задание в состоянии %s, ожидалось %s", got, database.TranscodeDone)
+	}
+}
+
+func TestSameBlobTranscodedOnce(t *testing.T) {
+	transcoder := &fakeTranscoder{}
+	p, db, store := newTestPipeline(t, transcoder)
+	ctx := context.Background()
+	enqueue(t, p, store, "a", "aa")
+	enqueue(t, p, store, "b", "aa")
+	drain(t, p, db)
+
+	if got := transcoder.calls.Load(); got != 1 {
+		t.Errorf("перекодировщик вызван %d раз, ожидался 1", got)
+	}
+	blob, err := db.GetBlob(ctx, "aa")
+	if err != nil {
+		t.Fatalf("GetBlob: %v", err)
+	}
+	if !blob.Ready || blob.Refs != 2 || blob.Size == 0 {
+		t.Errorf("блоб %+v, ожидался готовый, с двумя ссылками и размером", blob)
+	}
+	for _, name := range []string{"videos/a", "videos/b"} {
+		if got := status(t, db, name); got != database.TranscodeDone {
+			t.Errorf("%s в состоянии %s, ожидалось %s", name, got, database.TranscodeDone)
+		}
+		video, err := db.GetVideoByName(ctx, name)
+		if err != nil {
+			t.Fatalf("GetVideoByName(%s): %v", name, err)
+		}
+		if video.Dir() != database.BlobDir("aa") || video.Size != blob.Size || video.Duration != 10 {
+			t.Errorf("видео %s: каталог %s, размер %d, длительность %v", name, video.Dir(), video.Size, video.Duration)
+		}
+	}
+	if _, err := store.Open(ctx, database.BlobDir("aa")+"/"+MasterPlaylist); err != nil {
+		t.Errorf("нет плейлиста блоба: %v", err)
+	}
+	for _, source := range []string{"uploads/a", "uploads/b"} {
+		if _, err := store.Open(ctx, source); err == nil {
+			t.Errorf("исходный файл %s не удалён", source)
+		}
+	}
+}
+
+func TestFailedJobReleasesBlob(t *testing.T) {
+	transcoder := &fakeTranscoder{err: errors.New("битый файл")}
+	p, db, store := newTestPipeline(t, transcoder)
+	ctx := context.Background()
+	enqueue(t, p, store, "a", "aa")
+	enqueue(t, p, store, "b", "bb")
+	enqueue(t, p, store, "c", "bb")
+
+	job := claim(t, db, "videos/a")
+	p.run(ctx, job)
+	if job.Status != database.TranscodeFailed || job.Error == "" {
+		t.Errorf("задание в состоянии %s с ошибкой %q, ожидалось %s", job.Status, job.Error, database.TranscodeFailed)
+	}
+	if _, err := db.GetBlob(ctx, "aa"); !errors.Is(err, database.ErrBlobNotFound) {
+		t.Errorf("GetBlob после неудачи: %v, ожидалось %v", err, database.ErrBlobNotFound)
+	}
+	if _, err := store.Open(ctx, "uploads/a"); err == nil {
+		t.Error("исходный файл неудачного задания не удалён")
+	}
+
+	// Блоб остаётся, пока на него ссылается другое задание
+	p.run(ctx, claim(t, db, "videos/b"))
+	blob, err := db.GetBlob(ctx, "bb")
+	if err != nil {
+		t.Fatalf("GetBlob: %v", err)
+	}
+	if blob.Refs != 1 || blob.Ready {
+		t.Errorf("блоб %+v, ожидалась одна ссылка без готового результата", blob)
+	}
+
+	// Следующее задание с тем же содержимым перекодирует его заново
+	transcoder.err = nil
+	drain(t, p, db)
+	if got := status(t, db, "videos/c"); got != database.TranscodeDone {
+		t.Errorf("задание в состоянии %s, ожидалось %s", got, database.TranscodeDone)
+	}
+	if got := transcoder.calls.Load(); got != 3 {
+		t.Errorf("перекодировщик вызван %d раз, ожидалось 3", got)
+	}
+}
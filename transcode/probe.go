@@ -0,0 +1,107 @@
+package transcode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"room/database"
+	"strconv"
+)
+
+// Prober определяет параметры видеофайла.
+type Prober interface {
+	// Probe читает длительность, кодеки, разрешение, звуковые дорожки
+	// и формат файла input.
+	Probe(ctx context.Context, input string) (*database.VideoInfo, error)
+}
+
+// FFprobe определяет параметры видео локальным бинарником ffprobe.
+type FFprobe struct {
+	// Binary — путь к ffprobe; пустой — искать в PATH.
+	Binary string
+}
+
+// NewFFprobe создаёт анализатор, использующий бинарник binary.
+func NewFFprobe(binary string) *FFprobe {
+	return &FFprobe{Binary: binary}
+}
+
+func (f *FFprobe) binary() string {
+	if f.Binary == "" {
+		return "ffprobe"
+	}
+	return f.Binary
+}
+
+// probeOutput — нужная часть вывода ffprobe -print_format json.
+type probeOutput struct {
+	Streams []struct {
+		Index     int               `json:"index"`
+		CodecType string            `json:"codec_type"`
+		CodecName string            `json:"codec_name"`
+		Width     int               `json:"width"`
+		Height    int               `json:"height"`
+		Channels  int               `json:"channels"`
+		Tags      map[string]string `json:"tags"`
+	} `json:"streams"`
+	Format struct {
+		FormatName string `json:"format_name"`
+		// ffprobe печатает числа в формате как строки
+		Duration string `json:"duration"`
+	} `json:"format"`
+}
+
+// Probe запускает ffprobe и разбирает его вывод. Файл без видеопотока считается ошибкой.
+func (f *FFprobe) Probe(ctx context.Context, input string) (*database.VideoInfo, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, f.binary(),
+		"-v", "error",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		input,
+	)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("ffprobe завершился с ошибкой: %w: %s", err, lastLines(stderr.String(), 3))
+	}
+
+	var out probeOutput
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		return nil, fmt.Errorf("не удалось разобрать вывод ffprobe: %w", err)
+	}
+
+	info := &database.VideoInfo{
+		Container:   out.Format.FormatName,
+		AudioTracks: []database.AudioTrack{},
+	}
+	info.Duration, _ = strconv.ParseFloat(out.Format.Duration, 64)
+	for _, stream := range out.Streams {
+		switch stream.CodecType {
+		case "video":
+			// Обложки и превью тоже бывают видеопотоками, берём первый
+			if info.VideoCodec == "" {
+				info.VideoCodec = stream.CodecName
+				info.Width = stream.Width
+				info.Height = stream.Height
+			}
+		case "audio":
+			info.AudioTracks = append(info.AudioTracks, database.AudioTrack{
+				Index:    stream.Index,
+				Codec:    stream.CodecName,
+				Language: stream.Tags["language"],
+				Channels: stream.Channels,
+			})
+		}
+	}
+	if info.VideoCodec == "" {
+		return nil, fmt.Errorf("в файле нет видеопотока")
+	}
+	return info, nil
+}
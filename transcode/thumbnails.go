@@ -0,0 +1,210 @@
+package transcode
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"room/subtitles"
+	"strconv"
+	"time"
+)
+
+// Файлы превью в каталоге видео.
+const (
+	// Poster — кадр, который показывается до начала воспроизведения.
+	Poster = "poster.jpg"
+	// ThumbnailsTrack — дорожка WebVTT с миниатюрами для полосы перемотки.
+	ThumbnailsTrack = "thumbnails.vtt"
+	// thumbnailsDir — подкаталог листов миниатюр.
+	thumbnailsDir = "thumbnails"
+)
+
+// Раскладка листа миниатюр.
+const (
+	spriteColumns = 10
+	spriteRows    = 10
+	// posterWidth — наибольшая ширина постера.
+	posterWidth = 1280
+	// posterPosition — в какой доле длительности берётся кадр для постера.
+	posterPosition = 0.1
+)
+
+// PosterURL возвращает ссылку на постер перекодированного видео video.
+// mediaURL — адрес раздела медиафайлов вместе с подписью ссылки.
+func PosterURL(mediaURL, video string) string {
+	return mediaURL + "/" + video + "/" + Poster
+}
+
+// ThumbnailsURL возвращает ссылку на дорожку миниатюр перекодированного видео video.
+// mediaURL — адрес раздела медиафайлов вместе с подписью ссылки.
+func ThumbnailsURL(mediaURL, video string) string {
+	return mediaURL + "/" + video + "/" + ThumbnailsTrack
+}
+
+// FrameExtractor извлекает кадры из видеофайла в JPEG.
+type FrameExtractor interface {
+	// Poster сохраняет в output кадр файла input в момент at секунд,
+	// уменьшенный до ширины не больше width.
+	Poster(ctx context.Context, input, output string, at float64, width int) error
+	// Frames сохраняет в каталог outDir кадры файла input каждые interval
+	// секунд, уменьшенные до ширины width, и возвращает пути к ним по порядку.
+	Frames(ctx context.Context, input, outDir string, interval time.Duration, width int) ([]string, error)
+}
+
+// Poster извлекает один кадр, перематывая ко времени at до открытия потока.
+func (f *FFmpeg) Poster(ctx context.Context, input, output string, at float64, width int) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, f.binary(), "-hide_banner", "-nostats", "-y",
+		"-ss", strconv.FormatFloat(at, 'f', 3, 64),
+		"-i", input,
+		"-frames:v", "1",
+		"-vf", fmt.Sprintf("scale='min(%d,iw)':-2", width),
+		"-q:v", "3",
+		output,
+	)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("ffmpeg не извлёк постер: %w: %s", err, lastLines(stderr.String(), 3))
+	}
+	return nil
+}
+
+// Frames извлекает кадры фильтром fps за один проход по файлу.
+func (f *FFmpeg) Frames(ctx context.Context, input, outDir string, interval time.Duration, width int) ([]string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, f.binary(), "-hide_banner", "-nostats", "-y",
+		"-i", input,
+		"-vf", fmt.Sprintf("fps=1/%s,scale=%d:-2", strconv.FormatFloat(interval.Seconds(), 'f', -1, 64), width),
+		"-q:v", "5",
+		filepath.Join(outDir, "frame_%05d.jpg"),
+	)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("ffmpeg не извлёк кадры: %w: %s", err, lastLines(stderr.String(), 3))
+	}
+	// Имена с нулями впереди, поэтому порядок Glob совпадает с порядком кадров
+	return filepath.Glob(filepath.Join(outDir, "frame_*.jpg"))
+}
+
+// thumbnails создаёт в каталоге видео outDir постер, листы миниатюр и дорожку
+// ThumbnailsTrack, в которой каждому промежутку ThumbnailInterval соответствует своя миниатюра.
+// При ошибке созданные файлы превью удаляются.
+func (p *Pipeline) thumbnails(ctx context.Context, input, outDir string, duration float64) (err error) {
+	defer func() {
+		if err != nil {
+			os.Remove(filepath.Join(outDir, Poster))
+			os.Remove(filepath.Join(outDir, ThumbnailsTrack))
+			os.RemoveAll(filepath.Join(outDir, thumbnailsDir))
+		}
+	}()
+
+	if err := p.extractor.Poster(ctx, input, filepath.Join(outDir, Poster), duration*posterPosition, posterWidth); err != nil {
+		return err
+	}
+
+	framesDir, err := os.MkdirTemp(p.opts.TempDir, "frames-*")
+	if err != nil {
+		return fmt.Errorf("не удалось создать каталог кадров: %w", err)
+	}
+	defer os.RemoveAll(framesDir)
+	interval := p.opts.ThumbnailInterval
+	frames, err := p.extractor.Frames(ctx, input, framesDir, interval, p.opts.ThumbnailWidth)
+	if err != nil {
+		return err
+	}
+	if len(frames) == 0 {
+		return fmt.Errorf("не извлечено ни одного кадра")
+	}
+
+	if err := os.Mkdir(filepath.Join(outDir, thumbnailsDir), 0o755); err != nil {
+		return fmt.Errorf("не удалось создать каталог миниатюр: %w", err)
+	}
+	end := time.Duration(duration * float64(time.Second))
+	var cues []subtitles.Cue
+	perSheet := spriteColumns * spriteRows
+	for sheet := 0; sheet*perSheet < len(frames); sheet++ {
+		batch := frames[sheet*perSheet : min((sheet+1)*perSheet, len(frames))]
+		name := path.Join(thumbnailsDir, fmt.Sprintf("sprite_%03d.jpg", sheet))
+		tiles, err := writeSprite(filepath.Join(outDir, filepath.FromSlash(name)), batch)
+		if err != nil {
+			return err
+		}
+		for i, tile := range tiles {
+			start := time.Duration(sheet*perSheet+i) * interval
+			cueEnd := start + interval
+			if end > start && end < cueEnd {
+				cueEnd = end
+			}
+			cues = append(cues, subtitles.Cue{
+				Start: start,
+				End:   cueEnd,
+				Text:  fmt.Sprintf("%s#xywh=%d,%d,%d,%d", name, tile.Min.X, tile.Min.Y, tile.Dx(), tile.Dy()),
+			})
+		}
+	}
+	if err := os.WriteFile(filepath.Join(outDir, ThumbnailsTrack), subtitles.Write(cues), 0o644); err != nil {
+		return fmt.Errorf("не удалось сохранить дорожку миниатюр: %w", err)
+	}
+	return nil
+}
+
+// writeSprite собирает кадры frames в лист по spriteColumns в ряд и сохраняет его
+// в JPEG output. Размер ячейки берётся по первому кадру. Возвращает место каждого кадра на листе.
+func writeSprite(output string, frames []string) ([]image.Rectangle, error) {
+	var sheet *image.RGBA
+	var tile image.Rectangle
+	tiles := make([]image.Rectangle, 0, len(frames))
+	for i, frame := range frames {
+		img, err := decodeJPEG(frame)
+		if err != nil {
+			return nil, err
+		}
+		if sheet == nil {
+			tile = image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy())
+			rows := (len(frames) + spriteColumns - 1) / spriteColumns
+			sheet = image.NewRGBA(image.Rect(0, 0, tile.Dx()*min(len(frames), spriteColumns), tile.Dy()*rows))
+		}
+		cell := tile.Add(image.Pt(i%spriteColumns*tile.Dx(), i/spriteColumns*tile.Dy()))
+		draw.Draw(sheet, cell, img, img.Bounds().Min, draw.Src)
+		tiles = append(tiles, cell)
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать лист миниатюр: %w", err)
+	}
+	err = jpeg.Encode(f, sheet, &jpeg.Options{Quality: 75})
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось сохранить лист миниатюр: %w", err)
+	}
+	return tiles, nil
+}
+
+func decodeJPEG(name string) (image.Image, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть кадр: %w", err)
+	}
+	defer f.Close()
+	img, err := jpeg.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать кадр %s: %w", filepath.Base(name), err)
+	}
+	return img, nil
+}
@@ -0,0 +1,200 @@
+package transcode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"room/subtitles"
+	"testing"
+	"time"
+)
+
+// fakeExtractor сохраняет однотонные кадры заданного размера вместо извлечения из видео.
+type fakeExtractor struct {
+	frames        int
+	width, height int
+	// err — ошибка, которую возвращает Frames.
+	err error
+}
+
+func (f *fakeExtractor) Poster(ctx context.Context, input, output string, at float64, width int) error {
+	return writeFrame(output, f.width, f.height)
+}
+
+func (f *fakeExtractor) Frames(ctx context.Context, input, outDir string, interval time.Duration, width int) ([]string, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	frames := make([]string, 0, f.frames)
+	for i := range f.frames {
+		name := filepath.Join(outDir, fmt.Sprintf("frame_%05d.jpg", i+1))
+		if err := writeFrame(name, f.width, f.height); err != nil {
+			return nil, err
+		}
+		frames = append(frames, name)
+	}
+	return frames, nil
+}
+
+func writeFrame(name string, width, height int) error {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 0x80
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return jpeg.Encode(f, img, nil)
+}
+
+func newThumbnailsPipeline(t *testing.T, extractor FrameExtractor) *Pipeline {
+	t.Helper()
+	return &Pipeline{
+		extractor: extractor,
+		opts:      Options{TempDir: t.TempDir(), ThumbnailInterval: 2 * time.Second, ThumbnailWidth: 16},
+	}
+}
+
+// imageSize возвращает размеры JPEG name.
+func imageSize(t *testing.T, name string) (int, int) {
+	t.Helper()
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatalf("Open(%s): %v", name, err)
+	}
+	defer f.Close()
+	config, err := jpeg.DecodeConfig(f)
+	if err != nil {
+		t.Fatalf("DecodeConfig(%s): %v", name, err)
+	}
+	return config.Width, config.Height
+}
+
+func TestWriteSprite(t *testing.T) {
+	dir := t.TempDir()
+	extractor := &fakeExtractor{frames: 23, width: 16, height: 9}
+	frames, err := extractor.Frames(context.Background(), "", dir, time.Second, 16)
+	if err != nil {
+		t.Fatalf("Frames: %v", err)
+	}
+
+	output := filepath.Join(dir, "sprite.jpg")
+	tiles, err := writeSprite(output, frames)
+	if err != nil {
+		t.Fatalf("writeSprite: %v", err)
+	}
+	if len(tiles) != len(frames) {
+		t.Fatalf("получено %d ячеек, ожидалось %d", len(tiles), len(frames))
+	}
+	tests := []struct {
+		frame int
+		want  image.Rectangle
+	}{
+		{0, image.Rect(0, 0, 16, 9)},
+		{9, image.Rect(144, 0, 160, 9)},
+		{10, image.Rect(0, 9, 16, 18)},
+		{22, image.Rect(32, 18, 48, 27)},
+	}
+	for _, tt := range tests {
+		if tiles[tt.frame] != tt.want {
+			t.Errorf("ячейка кадра %d = %v, ожидалось %v", tt.frame, tiles[tt.frame], tt.want)
+		}
+	}
+	// Десять кадров в ряд, неполный последний ряд занимает целую строку
+	if w, h := imageSize(t, output); w != 160 || h != 27 {
+		t.Errorf("лист %dx%d, ожидалось 160x27", w, h)
+	}
+
+	// Лист из одного ряда не шире своих кадров
+	tiles, err = writeSprite(output, frames[:3])
+	if err != nil {
+		t.Fatalf("writeSprite: %v", err)
+	}
+	if w, h := imageSize(t, output); w != 48 || h != 9 || len(tiles) != 3 {
+		t.Errorf("лист %dx%d с %d ячейками, ожидалось 48x9 с 3", w, h, len(tiles))
+	}
+}
+
+func TestThumbnailsTrack(t *testing.T) {
+	p := newThumbnailsPipeline(t, &fakeExtractor{frames: 105, width: 16, height: 9})
+	out := t.TempDir()
+	if err := p.thumbnails(context.Background(), "input", out, 209.5); err != nil {
+		t.Fatalf("thumbnails: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(out, Poster)); err != nil {
+		t.Errorf("нет постера: %v", err)
+	}
+	// Сто кадров на первом листе и пять на втором
+	if w, h := imageSize(t, filepath.Join(out, thumbnailsDir, "sprite_000.jpg")); w != 160 || h != 90 {
+		t.Errorf("первый лист %dx%d, ожидалось 160x90", w, h)
+	}
+	if w, h := imageSize(t, filepath.Join(out, thumbnailsDir, "sprite_001.jpg")); w != 80 || h != 9 {
+		t.Errorf("второй лист %dx%d, ожидалось 80x9", w, h)
+	}
+
+	data, err := os.ReadFile(filepath.Join(out, ThumbnailsTrack))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	cues, err := subtitles.Parse(data, subtitles.FormatVTT)
+	if err != nil {
+		t.Fatalf("дорожка миниатюр не разбирается как WebVTT: %v", err)
+	}
+	if len(cues) != 105 {
+		t.Fatalf("в дорожке %d реплик, ожидалось 105", len(cues))
+	}
+	tests := []struct {
+		cue        int
+		start, end time.Duration
+		text       string
+	}{
+		{0, 0, 2 * time.Second, "thumbnails/sprite_000.jpg#xywh=0,0,16,9"},
+		{9, 18 * time.Second, 20 * time.Second, "thumbnails/sprite_000.jpg#xywh=144,0,16,9"},
+		{10, 20 * time.Second, 22 * time.Second, "thumbnails/sprite_000.jpg#xywh=0,9,16,9"},
+		{99, 198 * time.Second, 200 * time.Second, "thumbnails/sprite_000.jpg#xywh=144,81,16,9"},
+		{100, 200 * time.Second, 202 * time.Second, "thumbnails/sprite_001.jpg#xywh=0,0,16,9"},
+		// Последняя реплика заканчивается вместе с видео
+		{104, 208 * time.Second, 209500 * time.Millisecond, "thumbnails/sprite_001.jpg#xywh=64,0,16,9"},
+	}
+	for _, tt := range tests {
+		cue := cues[tt.cue]
+		if cue.Start != tt.start || cue.End != tt.end || cue.Text != tt.text {
+			t.Errorf("реплика %d = %v --> %v %q, ожидалось %v --> %v %q", tt.cue, cue.Start, cue.End, cue.Text, tt.start, tt.end, tt.text)
+		}
+	}
+}
+
+func TestThumbnailsCleanupOnError(t *testing.T) {
+	tests := []struct {
+		name      string
+		extractor *fakeExtractor
+	}{
+		{"ошибка извлечения", &fakeExtractor{width: 16, height: 9, err: errors.New("битый файл")}},
+		{"нет кадров", &fakeExtractor{width: 16, height: 9}},
+	}
+	for _, tt := range tests {
+		p := newThumbnailsPipeline(t, tt.extractor)
+		out := t.TempDir()
+		if err := p.thumbnails(context.Background(), "input", out, 10); err == nil {
+			t.Errorf("%s: ожидалась ошибка", tt.name)
+		}
+		entries, err := os.ReadDir(out)
+		if err != nil {
+			t.Fatalf("ReadDir: %v", err)
+		}
+		for _, entry := range entries {
+			t.Errorf("%s: после ошибки остался %s", tt.name, entry.Name())
+		}
+		// Временные кадры тоже удаляются
+		if entries, _ := os.ReadDir(p.opts.TempDir); len(entries) != 0 {
+			t.Errorf("%s: во временном каталоге осталось %d файлов", tt.name, len(entries))
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// Package transcode перекодирует загруженные видео в HLS с несколькими качествами,
+// чтобы их можно было смотреть в браузере с адаптивным битрейтом.
+package transcode
+
+import "context"
+
+// MasterPlaylist — имя основного плейлиста в каталоге видео.
+const MasterPlaylist = "master.m3u8"
+
+// PlaylistURL возвращает ссылку на основной плейлист перекодированного видео video.
+// mediaURL — адрес раздела медиафайлов вместе с подписью ссылки.
+func PlaylistURL(mediaURL, video string) string {
+	return mediaURL + "/" + video + "/" + MasterPlaylist
+}
+
+// Rendition — одно качество HLS-лесенки.
+type Rendition struct {
+	// Name — имя подкаталога и варианта в плейлисте, например "720p".
+	Name string
+	// Height — высота кадра; ширина подбирается по пропорциям исходника.
+	Height int
+	// VideoBitrate и AudioBitrate — битрейты в формате ffmpeg, например "2800k".
+	VideoBitrate string
+	AudioBitrate string
+}
+
+// DefaultLadder — качества по умолчанию от худшего к лучшему.
+var DefaultLadder = []Rendition{
+	{Name: "360p", Height: 360, VideoBitrate: "800k", AudioBitrate: "96k"},
+	{Name: "480p", Height: 480, VideoBitrate: "1400k", AudioBitrate: "128k"},
+	{Name: "720p", Height: 720, VideoBitrate: "2800k", AudioBitrate: "128k"},
+	{Name: "1080p", Height: 1080, VideoBitrate: "5000k", AudioBitrate: "192k"},
+}
+
+// Transcoder перекодирует файл в HLS.
+type Transcoder interface {
+	// Transcode перекодирует файл input в каталог outDir: MasterPlaylist
+	// и по подкаталогу на каждое качество. progress получает долю
+	// выполненной работы от 0 до 1 и может не вызываться вовсе.
+	Transcode(ctx context.Context, input, outDir string, ladder []Rendition, progress func(float64)) error
+}